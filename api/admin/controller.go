@@ -0,0 +1,198 @@
+package admin
+
+import (
+	userBusiness "roby-backend-golang/business/user"
+	"roby-backend-golang/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service userBusiness.Service
+}
+
+func NewController(service userBusiness.Service) *Controller {
+	return &Controller{
+		service: service,
+	}
+}
+
+func (Controller *Controller) SetRoles(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	var input userBusiness.SetRoles
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	err := Controller.service.SetRoles(id, c.Params("id"), input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success set roles",
+	})
+}
+
+func (Controller *Controller) GetAllPackages(c *fiber.Ctx) error {
+	res, err := Controller.service.GetAllPackages()
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success get data",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) CreatePackage(c *fiber.Ctx) error {
+	var input userBusiness.PackageInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.CreatePackage(input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success create package",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) UpdatePackage(c *fiber.Ctx) error {
+	var input userBusiness.PackageInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.UpdatePackage(c.Params("id"), input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success update package",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) ArchivePackage(c *fiber.Ctx) error {
+	err := Controller.service.ArchivePackage(c.Params("id"))
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success archive package",
+	})
+}
+
+func (Controller *Controller) ReorderPackages(c *fiber.Ctx) error {
+	var input userBusiness.ReorderPackages
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	err := Controller.service.ReorderPackages(input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success reorder packages",
+	})
+}
+
+func (Controller *Controller) RefundPayment(c *fiber.Ctx) error {
+	err := Controller.service.RefundPayment(c.Params("id"))
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success refund payment",
+	})
+}
+
+func (Controller *Controller) GetPurchaseHistory(c *fiber.Ctx) error {
+	var page userBusiness.Pagination
+	if err := c.QueryParser(&page); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.GetPurchaseHistory(c.Params("id"), page)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success get data",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) GetInvoice(c *fiber.Ctx) error {
+	res, err := Controller.service.GetInvoice(c.Params("id"), c.Params("payment_id"))
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	c.Type("html", "utf-8")
+	return c.Status(200).Send(res)
+}
+
+func (Controller *Controller) CreatePromoCode(c *fiber.Ctx) error {
+	var input userBusiness.PromoCodeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.CreatePromoCode(input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success create promo code",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) GetPromoCodes(c *fiber.Ctx) error {
+	res, err := Controller.service.GetPromoCodes()
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success get data",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) GetPromoCodeUsage(c *fiber.Ctx) error {
+	res, err := Controller.service.GetPromoCodeUsage(c.Params("id"))
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success get data",
+		"result":  res,
+	})
+}
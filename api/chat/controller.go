@@ -0,0 +1,108 @@
+package chat
+
+import (
+	chatBusiness "roby-backend-golang/business/chat"
+	"roby-backend-golang/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+type Controller struct {
+	service chatBusiness.Service
+	hub     *chatBusiness.Hub
+}
+
+func NewController(service chatBusiness.Service, hub *chatBusiness.Hub) *Controller {
+	return &Controller{
+		service: service,
+		hub:     hub,
+	}
+}
+
+func (Controller *Controller) GetMessages(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	var query chatBusiness.HistoryQuery
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.GetMessages(id, c.Params("match_id"), query)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success get data",
+		"result":  res,
+	})
+}
+
+// Websocket keeps a chat connection open, it is registered to the hub so
+// messages for this user are pushed to it while it reads messages to send.
+func (Controller *Controller) Websocket(c *websocket.Conn) {
+	id := c.Locals("id").(string)
+	client := Controller.hub.Register(id, c)
+	defer Controller.hub.Unregister(id, client)
+
+	_ = Controller.service.Connect(id)
+	defer func() {
+		_ = Controller.service.Disconnect(id)
+	}()
+
+	for {
+		var input chatBusiness.ClientEvent
+		if err := c.ReadJSON(&input); err != nil {
+			return
+		}
+
+		switch input.Type {
+		case chatBusiness.EventMessage:
+			_, err := Controller.service.SendMessage(id, chatBusiness.SendMessage{
+				MatchID: input.MatchID,
+				Body:    input.Body,
+			})
+			if err != nil {
+				_ = client.Send(chatBusiness.Event{Type: chatBusiness.EventError, Error: err.Error()})
+			}
+		case chatBusiness.EventTyping:
+			err := Controller.service.Typing(id, input.MatchID)
+			if err != nil {
+				_ = client.Send(chatBusiness.Event{Type: chatBusiness.EventError, Error: err.Error()})
+			}
+		case chatBusiness.EventDelivered:
+			err := Controller.service.MarkDelivered(id, input.MatchID, chatBusiness.MarkMessage{MessageID: input.MessageID})
+			if err != nil {
+				_ = client.Send(chatBusiness.Event{Type: chatBusiness.EventError, Error: err.Error()})
+			}
+		case chatBusiness.EventRead:
+			err := Controller.service.MarkRead(id, input.MatchID, chatBusiness.MarkMessage{MessageID: input.MessageID})
+			if err != nil {
+				_ = client.Send(chatBusiness.Event{Type: chatBusiness.EventError, Error: err.Error()})
+			}
+		default:
+			_ = client.Send(chatBusiness.Event{Type: chatBusiness.EventError, Error: "unknown event type"})
+		}
+	}
+}
+
+func (Controller *Controller) MarkRead(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	var input chatBusiness.MarkMessage
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	err := Controller.service.MarkRead(id, c.Params("match_id"), input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success read",
+	})
+}
@@ -2,16 +2,33 @@ package middlewares
 
 import (
 	"encoding/json"
-	"os"
+	"errors"
 	"roby-backend-golang/utils"
 	"strings"
 	"time"
 
-	jose "github.com/dvsekhvalnov/jose2go"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 )
 
-func MiddleJWT(c *fiber.Ctx) error {
+// TokenValidator decides whether a well signed access token was revoked.
+type TokenValidator interface {
+	ValidateAccessToken(claims utils.JwtTokenClaimsUser) error
+}
+
+type Auth struct {
+	validator TokenValidator
+	keys      *utils.KeySet
+}
+
+func NewAuth(validator TokenValidator, keys *utils.KeySet) *Auth {
+	return &Auth{
+		validator: validator,
+		keys:      keys,
+	}
+}
+
+func (a *Auth) MiddleJWT(c *fiber.Ctx) error {
 	authorizationHeader := c.Get("Authorization")
 
 	if !strings.Contains(authorizationHeader, "Bearer") {
@@ -22,8 +39,7 @@ func MiddleJWT(c *fiber.Ctx) error {
 	}
 
 	tokenString := strings.Replace(authorizationHeader, "Bearer ", "", -1)
-	secret := os.Getenv("JWT_SECRET")
-	key, err := utils.Decode(secret)
+	str, err := a.authenticate(tokenString)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"code":    fiber.StatusUnauthorized,
@@ -31,30 +47,113 @@ func MiddleJWT(c *fiber.Ctx) error {
 		})
 	}
 
-	Header, _, err := jose.Decode(tokenString, key)
+	c.Locals("id", str.Sub)
+	c.Locals("claims", str)
+	return c.Next()
+}
+
+// MiddleWebsocketJWT guards websocket upgrades. Browsers can not set headers on
+// a websocket handshake, so the token may also be sent in the token query.
+func (a *Auth) MiddleWebsocketJWT(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(fiber.Map{
+			"code":    fiber.StatusUpgradeRequired,
+			"message": "websocket upgrade required",
+		})
+	}
+
+	tokenString := strings.Replace(c.Get("Authorization"), "Bearer ", "", -1)
+	if tokenString == "" {
+		tokenString = c.Query("token")
+	}
+
+	str, err := a.authenticate(tokenString)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"code":    fiber.StatusUnauthorized,
 			"message": "invalid token",
 		})
 	}
+
+	c.Locals("id", str.Sub)
+	c.Locals("claims", str)
+	return c.Next()
+}
+
+// RequireRoles lets the request through when the user holds one of the roles,
+// it runs after MiddleJWT.
+func (a *Auth) RequireRoles(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := c.Locals("claims").(utils.JwtTokenClaimsUser)
+		if ok {
+			for _, role := range roles {
+				if utils.HasRole(claims.Roles, role) {
+					return c.Next()
+				}
+			}
+		}
+		return forbidden(c)
+	}
+}
+
+// RequirePermissions lets the request through when the roles of the user grant
+// every permission, it runs after MiddleJWT.
+func (a *Auth) RequirePermissions(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := c.Locals("claims").(utils.JwtTokenClaimsUser)
+		if !ok {
+			return forbidden(c)
+		}
+		for _, permission := range permissions {
+			if !utils.HasPermission(claims.Roles, permission) {
+				return forbidden(c)
+			}
+		}
+		return c.Next()
+	}
+}
+
+func forbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"code":    fiber.StatusForbidden,
+		"message": "forbidden",
+	})
+}
+
+func (a *Auth) authenticate(tokenString string) (utils.JwtTokenClaimsUser, error) {
+	str, err := ParseToken(tokenString, a.keys)
+	if err != nil {
+		return str, err
+	}
+
+	err = a.validator.ValidateAccessToken(str)
+	if err != nil {
+		return str, err
+	}
+
+	return str, nil
+}
+
+func ParseToken(tokenString string, keys *utils.KeySet) (utils.JwtTokenClaimsUser, error) {
 	var str utils.JwtTokenClaimsUser
+
+	Header, err := keys.Verify(tokenString)
+	if err != nil {
+		return str, err
+	}
 	err = json.Unmarshal([]byte(Header), &str)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"code":    fiber.StatusUnauthorized,
-			"message": "invalid token",
-		})
+		return str, err
+	}
+	// refresh, verify email and mfa challenge tokens are only accepted by their
+	// own endpoint
+	if str.AuthorizationRefresh || !str.Authorization {
+		return str, errors.New("not an access token")
 	}
 	timenow := time.Unix(str.Exp, 0)
 	if timenow.Before(time.Now()) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"code":    fiber.StatusUnauthorized,
-			"message": "invalid token",
-		})
-
+		return str, errors.New("token expired")
 	}
 
-	c.Locals("id", str.Sub)
-	return c.Next()
+	return str, nil
 }
@@ -0,0 +1,164 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	idempotencyHeader   = "Idempotency-Key"
+	idempotencyReplayed = "Idempotent-Replayed"
+	idempotencyMaxKey   = 255
+
+	// a request that never finishes, because its instance crashed, holds the
+	// key no longer than this
+	idempotencyLockTTL = time.Minute
+)
+
+// reserveScript returns the stored response of the key, or claims the key for
+// the request when there is none, in one step so only one request runs.
+var reserveScript = redis.NewScript(`
+local stored = redis.call("GET", KEYS[1])
+if stored then
+	return stored
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return false
+`)
+
+// idempotentResponse is what is stored under a key, Status is zero while the
+// first request is still running.
+type idempotentResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency replays the response of a request sent again with the same
+// Idempotency-Key header, so a client can retry a request whose response got
+// lost without it taking effect twice.
+type Idempotency struct {
+	redis  *redis.Client
+	window time.Duration
+}
+
+func NewIdempotency(client *redis.Client, window time.Duration) *Idempotency {
+	return &Idempotency{
+		redis:  client,
+		window: window,
+	}
+}
+
+// Handle runs after MiddleJWT, keys are scoped to the user and the route.
+// Requests without the header are let through untouched.
+func (i *Idempotency) Handle(c *fiber.Ctx) error {
+	key := c.Get(idempotencyHeader)
+	if key == "" {
+		return c.Next()
+	}
+	if len(key) > idempotencyMaxKey {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"code":    fiber.StatusBadRequest,
+			"message": "invalid idempotency key",
+		})
+	}
+
+	userID, _ := c.Locals("id").(string)
+	redisKey := fmt.Sprintf("apptinder:idempotency:%s:%s %s:%s", userID, c.Method(), c.Path(), key)
+	sum := sha256.Sum256(c.Body())
+	fingerprint := hex.EncodeToString(sum[:])
+
+	stored, reserved, err := i.reserve(redisKey, fingerprint)
+	if err != nil {
+		// without redis the request runs as if it had no key
+		fmt.Println("idempotency:", err)
+		return c.Next()
+	}
+
+	if !reserved {
+		if stored.Fingerprint != fingerprint {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"code":    fiber.StatusUnprocessableEntity,
+				"message": "idempotency key was used for another request",
+			})
+		}
+		if stored.Status == 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"code":    fiber.StatusConflict,
+				"message": "request with this idempotency key is in progress",
+			})
+		}
+		c.Set(idempotencyReplayed, "true")
+		c.Set(fiber.HeaderContentType, stored.ContentType)
+		return c.Status(stored.Status).Send(stored.Body)
+	}
+
+	err = c.Next()
+	status := c.Response().StatusCode()
+	if err != nil || status >= fiber.StatusInternalServerError {
+		// a failure on our side may pass, the retry runs the request again
+		i.release(redisKey)
+		return err
+	}
+
+	i.save(redisKey, idempotentResponse{
+		Fingerprint: fingerprint,
+		Status:      status,
+		ContentType: string(c.Response().Header.ContentType()),
+		Body:        append([]byte(nil), c.Response().Body()...),
+	})
+	return nil
+}
+
+func (i *Idempotency) reserve(key, fingerprint string) (idempotentResponse, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pending, err := json.Marshal(idempotentResponse{Fingerprint: fingerprint})
+	if err != nil {
+		return idempotentResponse{}, false, err
+	}
+
+	val, err := reserveScript.Run(ctx, i.redis, []string{key}, pending, idempotencyLockTTL.Milliseconds()).Text()
+	if err == redis.Nil {
+		return idempotentResponse{}, true, nil
+	}
+	if err != nil {
+		return idempotentResponse{}, false, err
+	}
+
+	var stored idempotentResponse
+	err = json.Unmarshal([]byte(val), &stored)
+	return stored, false, err
+}
+
+func (i *Idempotency) save(key string, res idempotentResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	val, err := json.Marshal(res)
+	if err == nil {
+		err = i.redis.Set(ctx, key, val, i.window).Err()
+	}
+	if err != nil {
+		fmt.Println("idempotency:", err)
+	}
+}
+
+func (i *Idempotency) release(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := i.redis.Del(ctx, key).Err()
+	if err != nil {
+		fmt.Println("idempotency:", err)
+	}
+}
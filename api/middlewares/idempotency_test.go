@@ -0,0 +1,154 @@
+package middlewares_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"roby-backend-golang/api/middlewares"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// newIdempotentApp serves POST /purchase behind the idempotency middleware,
+// the user id comes from the User header in place of a token.
+func newIdempotentApp(t *testing.T, handler fiber.Handler) *fiber.App {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	idempotency := middlewares.NewIdempotency(client, time.Hour)
+	app := fiber.New()
+	app.Post("/purchase", func(c *fiber.Ctx) error {
+		c.Locals("id", c.Get("User"))
+		return c.Next()
+	}, idempotency.Handle, handler)
+	return app
+}
+
+func send(t *testing.T, app *fiber.App, user, key, body string) (*http.Response, string) {
+	req := httptest.NewRequest("POST", "/purchase", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User", user)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	res, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	resBody, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return res, string(resBody)
+}
+
+func TestIdempotency(t *testing.T) {
+	t.Run("Replay Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		var calls int32
+		app := newIdempotentApp(t, func(c *fiber.Ctx) error {
+			n := atomic.AddInt32(&calls, 1)
+			if n > 1 {
+				return c.Status(400).JSON(fiber.Map{"code": 400, "message": "already purchase package"})
+			}
+			return c.Status(200).JSON(fiber.Map{"code": 200, "message": "success purchase"})
+		})
+
+		res, body := send(t, app, "123", "key-1", `{"id":"1"}`)
+		asserting.Equal(200, res.StatusCode)
+		asserting.Empty(res.Header.Get("Idempotent-Replayed"))
+
+		res, replayed := send(t, app, "123", "key-1", `{"id":"1"}`)
+		asserting.Equal(200, res.StatusCode)
+		asserting.Equal(body, replayed)
+		asserting.Equal("true", res.Header.Get("Idempotent-Replayed"))
+		asserting.Equal(int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Different Body Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		var calls int32
+		app := newIdempotentApp(t, func(c *fiber.Ctx) error {
+			atomic.AddInt32(&calls, 1)
+			return c.Status(200).JSON(fiber.Map{"code": 200})
+		})
+
+		res, _ := send(t, app, "123", "key-1", `{"id":"1"}`)
+		asserting.Equal(200, res.StatusCode)
+		res, _ = send(t, app, "123", "key-1", `{"id":"2"}`)
+		asserting.Equal(422, res.StatusCode)
+		asserting.Equal(int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Scoped To User Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		var calls int32
+		app := newIdempotentApp(t, func(c *fiber.Ctx) error {
+			atomic.AddInt32(&calls, 1)
+			return c.Status(200).JSON(fiber.Map{"code": 200})
+		})
+
+		send(t, app, "123", "key-1", `{"id":"1"}`)
+		res, _ := send(t, app, "456", "key-1", `{"id":"1"}`)
+		asserting.Empty(res.Header.Get("Idempotent-Replayed"))
+		asserting.Equal(int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Without Key Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		var calls int32
+		app := newIdempotentApp(t, func(c *fiber.Ctx) error {
+			atomic.AddInt32(&calls, 1)
+			return c.Status(200).JSON(fiber.Map{"code": 200})
+		})
+
+		send(t, app, "123", "", `{"id":"1"}`)
+		send(t, app, "123", "", `{"id":"1"}`)
+		asserting.Equal(int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Server Error Not Stored Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		var calls int32
+		app := newIdempotentApp(t, func(c *fiber.Ctx) error {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return c.Status(500).JSON(fiber.Map{"code": 500})
+			}
+			return c.Status(200).JSON(fiber.Map{"code": 200})
+		})
+
+		res, _ := send(t, app, "123", "key-1", `{"id":"1"}`)
+		asserting.Equal(500, res.StatusCode)
+		res, _ = send(t, app, "123", "key-1", `{"id":"1"}`)
+		asserting.Equal(200, res.StatusCode)
+		asserting.Equal(int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("In Progress Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		app := newIdempotentApp(t, func(c *fiber.Ctx) error {
+			close(started)
+			<-release
+			return c.Status(200).JSON(fiber.Map{"code": 200})
+		})
+
+		done := make(chan int)
+		go func() {
+			res, _ := send(t, app, "123", "key-1", `{"id":"1"}`)
+			done <- res.StatusCode
+		}()
+		<-started
+
+		res, _ := send(t, app, "123", "key-1", `{"id":"1"}`)
+		asserting.Equal(409, res.StatusCode)
+
+		close(release)
+		asserting.Equal(200, <-done)
+	})
+}
@@ -1,29 +1,93 @@
 package api
 
 import (
+	"roby-backend-golang/api/admin"
+	"roby-backend-golang/api/chat"
 	"roby-backend-golang/api/middlewares"
 	"roby-backend-golang/api/user"
+	"roby-backend-golang/api/wellknown"
+	"roby-backend-golang/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 )
 
 type Controller struct {
-	UserController *user.Controller
+	UserController      *user.Controller
+	ChatController      *chat.Controller
+	WellKnownController *wellknown.Controller
+	AdminController     *admin.Controller
+	Auth                *middlewares.Auth
+	Idempotency         *middlewares.Idempotency
 }
 
 func RegistrationPath(e *fiber.App, controller Controller) {
+	e.Get("/.well-known/jwks.json", controller.WellKnownController.JWKS)
+
 	route := e.Group("/v1")
 	routeUser := route.Group("/user")
 	routeUser.Post("/login", controller.UserController.Login)
+	routeUser.Post("/login/mfa", controller.UserController.LoginMFA)
+	routeUser.Get("/oauth/:provider", controller.UserController.StartOAuth)
+	routeUser.Get("/oauth/:provider/callback", controller.UserController.OAuthCallback)
+	routeUser.Post("/oauth/:provider/callback", controller.UserController.OAuthCallback)
 	routeUser.Post("/register", controller.UserController.Register)
+	routeUser.Post("/refresh", controller.UserController.RefreshToken)
+	routeUser.Post("/verify-email", controller.UserController.VerifyEmail)
+	routeUser.Post("/verify-email/resend", controller.UserController.ResendVerifyEmail)
+	routeUser.Post("/forgot-password", controller.UserController.ForgotPassword)
+	routeUser.Post("/reset-password", controller.UserController.ResetPassword)
 
-	routeUser.Use(middlewares.MiddleJWT)
+	routeUser.Use(controller.Auth.MiddleJWT)
 	routeUser.Delete("/logout", controller.UserController.Logout)
+	routeUser.Delete("/logout-all", controller.UserController.LogoutAll)
+	routeUser.Get("/sessions", controller.UserController.GetSessions)
+	routeUser.Delete("/sessions/:id", controller.UserController.RevokeSession)
+	routeUser.Post("/mfa/enroll", controller.UserController.EnrollMFA)
+	routeUser.Post("/mfa/confirm", controller.UserController.ConfirmMFA)
+	routeUser.Post("/mfa/disable", controller.UserController.DisableMFA)
+	routeUser.Post("/mfa/recovery-codes", controller.UserController.GenerateRecoveryCodes)
 	routeUser.Get("/me", controller.UserController.GetMe)
+	routeUser.Put("/profile", controller.UserController.CompleteProfile)
 	routeUser.Get("/find-random", controller.UserController.GetRandomUser)
-	routeUser.Post("/swipe", controller.UserController.SwipeUser)
+	routeUser.Post("/swipe", controller.Idempotency.Handle, controller.UserController.SwipeUser)
 
 	routePackage := route.Group("/package")
 	routePackage.Get("/list", controller.UserController.GetListPackage)
-	routePackage.Post("/purchase", middlewares.MiddleJWT, controller.UserController.PurchasePackage)
+	routePackage.Post("/purchase", controller.Auth.MiddleJWT, controller.Idempotency.Handle, controller.UserController.PurchasePackage)
+	routePackage.Get("/history", controller.Auth.MiddleJWT, controller.UserController.GetPurchaseHistory)
+	routePackage.Get("/history/:id/invoice", controller.Auth.MiddleJWT, controller.UserController.GetInvoice)
+
+	routePayment := route.Group("/payment")
+	routePayment.Post("/webhook", controller.UserController.PaymentWebhook)
+
+	routeMatch := route.Group("/match")
+	routeMatch.Use(controller.Auth.MiddleJWT)
+	routeMatch.Get("/list", controller.UserController.GetMatches)
+	routeMatch.Delete("/:id", controller.UserController.Unmatch)
+
+	routeAdmin := route.Group("/admin")
+	routeAdmin.Use(controller.Auth.MiddleJWT, controller.Auth.RequireRoles(utils.RoleAdmin))
+	routeAdmin.Put("/users/:id/roles", controller.Auth.RequirePermissions(utils.PermissionManageUsers), controller.AdminController.SetRoles)
+
+	routeAdmin.Post("/payments/:id/refund", controller.Auth.RequirePermissions(utils.PermissionManagePayments), controller.AdminController.RefundPayment)
+	routeAdmin.Get("/users/:id/purchases", controller.Auth.RequirePermissions(utils.PermissionManagePayments), controller.AdminController.GetPurchaseHistory)
+	routeAdmin.Get("/users/:id/purchases/:payment_id/invoice", controller.Auth.RequirePermissions(utils.PermissionManagePayments), controller.AdminController.GetInvoice)
+
+	routeAdminPackage := routeAdmin.Group("/packages", controller.Auth.RequirePermissions(utils.PermissionManagePackages))
+	routeAdminPackage.Get("/", controller.AdminController.GetAllPackages)
+	routeAdminPackage.Post("/", controller.AdminController.CreatePackage)
+	routeAdminPackage.Put("/order", controller.AdminController.ReorderPackages)
+	routeAdminPackage.Put("/:id", controller.AdminController.UpdatePackage)
+	routeAdminPackage.Delete("/:id", controller.AdminController.ArchivePackage)
+
+	routeAdminPromo := routeAdmin.Group("/promo-codes", controller.Auth.RequirePermissions(utils.PermissionManagePromos))
+	routeAdminPromo.Get("/", controller.AdminController.GetPromoCodes)
+	routeAdminPromo.Post("/", controller.AdminController.CreatePromoCode)
+	routeAdminPromo.Get("/:id/usage", controller.AdminController.GetPromoCodeUsage)
+
+	routeChat := route.Group("/chat")
+	routeChat.Get("/ws", controller.Auth.MiddleWebsocketJWT, websocket.New(controller.ChatController.Websocket))
+	routeChat.Get("/:match_id/messages", controller.Auth.MiddleJWT, controller.ChatController.GetMessages)
+	routeChat.Post("/:match_id/read", controller.Auth.MiddleJWT, controller.ChatController.MarkRead)
 }
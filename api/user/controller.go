@@ -33,19 +33,42 @@ func (Controller *Controller) Login(c *fiber.Ctx) error {
 	if err := c.BodyParser(&auth); err != nil {
 		return err
 	}
+	auth.IP = c.IP()
+	auth.UserAgent = c.Get(fiber.HeaderUserAgent)
 	res, err := Controller.service.Login(auth)
 	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	if res.MFARequired {
+		return c.JSON(fiber.Map{
+			"code":    200,
+			"message": "two-factor code required",
+			"result":  res,
+		})
+	}
+	setTokenCookie(c, res.Token)
+	return c.JSON(fiber.Map{
+		"code":    200,
+		"message": "success login",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) LoginMFA(c *fiber.Ctx) error {
+	var input userBusiness.LoginMFA
+	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
 			"message": err.Error(),
 		})
 	}
-	c.Cookie(&fiber.Cookie{
-		Name:     "token",
-		Value:    res.Token.AccessToken,
-		MaxAge:   res.Token.AccessTokenExpired,
-		HTTPOnly: true,
-		Secure:   true,
-	})
+	input.IP = c.IP()
+	input.UserAgent = c.Get(fiber.HeaderUserAgent)
+	res, err := Controller.service.LoginMFA(input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	setTokenCookie(c, res.Token)
 	return c.JSON(fiber.Map{
 		"code":    200,
 		"message": "success login",
@@ -53,6 +76,16 @@ func (Controller *Controller) Login(c *fiber.Ctx) error {
 	})
 }
 
+func setTokenCookie(c *fiber.Ctx, token *utils.Token) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "token",
+		Value:    token.AccessToken,
+		MaxAge:   token.AccessTokenExpired,
+		HTTPOnly: true,
+		Secure:   true,
+	})
+}
+
 func (Controller *Controller) Register(c *fiber.Ctx) error {
 	var data userBusiness.Register
 	if err := c.BodyParser(&data); err != nil {
@@ -81,7 +114,83 @@ func (Controller *Controller) Register(c *fiber.Ctx) error {
 	})
 }
 
+func (Controller *Controller) StartOAuth(c *fiber.Ctx) error {
+	res, err := Controller.service.StartOAuth(c.Params("provider"))
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success get data",
+		"result":  res,
+	})
+}
+
+// OAuthCallback takes the code and state the identity provider redirected
+// back with, as query or body.
+func (Controller *Controller) OAuthCallback(c *fiber.Ctx) error {
+	var input userBusiness.OAuthCallback
+	if err := c.QueryParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	if c.Method() == fiber.MethodPost {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"code":    400,
+				"message": err.Error(),
+			})
+		}
+	}
+	input.IP = c.IP()
+	input.UserAgent = c.Get(fiber.HeaderUserAgent)
+	res, err := Controller.service.OAuthCallback(c.Params("provider"), input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	if res.MFARequired {
+		return c.JSON(fiber.Map{
+			"code":    200,
+			"message": "two-factor code required",
+			"result":  res,
+		})
+	}
+	setTokenCookie(c, res.Token)
+	return c.JSON(fiber.Map{
+		"code":    200,
+		"message": "success login",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) CompleteProfile(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	var input userBusiness.CompleteProfile
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	input.File, _ = c.FormFile("file")
+	err := Controller.service.CompleteProfile(id, input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success update profile",
+	})
+}
+
 func (Controller *Controller) Logout(c *fiber.Ctx) error {
+	claims := c.Locals("claims").(utils.JwtTokenClaimsUser)
+	err := Controller.service.Logout(claims)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
 	c.ClearCookie()
 	return c.Status(200).JSON(fiber.Map{
 		"code":    200,
@@ -89,6 +198,19 @@ func (Controller *Controller) Logout(c *fiber.Ctx) error {
 	})
 }
 
+func (Controller *Controller) LogoutAll(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	err := Controller.service.LogoutAll(id)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	c.ClearCookie()
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success logout all sessions",
+	})
+}
+
 func (Controller *Controller) GetMe(c *fiber.Ctx) error {
 	id := c.Locals("id").(string)
 	res, err := Controller.service.GetMe(id)
@@ -127,18 +249,26 @@ func (Controller *Controller) SwipeUser(c *fiber.Ctx) error {
 			"message": err.Error(),
 		})
 	}
-	err := Controller.service.SwipeUser(id, input)
+	res, err := Controller.service.SwipeUser(id, input)
 	if err != nil {
 		return c.Status(utils.GetStatusCode(err)).JSON(err)
 	}
 	return c.Status(200).JSON(fiber.Map{
 		"code":    200,
 		"message": "success swipe",
+		"result":  res,
 	})
 }
 
 func (Controller *Controller) GetListPackage(c *fiber.Ctx) error {
-	res, err := Controller.service.GetListPackage()
+	var query userBusiness.PackageQuery
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.GetListPackage(query)
 	if err != nil {
 		return c.Status(utils.GetStatusCode(err)).JSON(err)
 	}
@@ -158,13 +288,55 @@ func (Controller *Controller) PurchasePackage(c *fiber.Ctx) error {
 			"message": err.Error(),
 		})
 	}
-	err := Controller.service.PurchasePackage(id, input.ID)
+	res, err := Controller.service.PurchasePackage(id, input)
 	if err != nil {
 		return c.Status(utils.GetStatusCode(err)).JSON(err)
 	}
 	return c.Status(200).JSON(fiber.Map{
 		"code":    200,
 		"message": "success purchase",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) GetPurchaseHistory(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	var page userBusiness.Pagination
+	if err := c.QueryParser(&page); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.GetPurchaseHistory(id, page)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success get data",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) GetInvoice(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	res, err := Controller.service.GetInvoice(id, c.Params("id"))
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	c.Type("html", "utf-8")
+	return c.Status(200).Send(res)
+}
+
+func (Controller *Controller) PaymentWebhook(c *fiber.Ctx) error {
+	err := Controller.service.HandlePaymentWebhook(c.Body(), c.Get("Payment-Signature"))
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success handle webhook",
 	})
 }
 
@@ -180,3 +352,225 @@ func (Controller *Controller) GetPackageByID(c *fiber.Ctx) error {
 		"result":  res,
 	})
 }
+
+func (Controller *Controller) GetMatches(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	var page userBusiness.Pagination
+	if err := c.QueryParser(&page); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.GetMatches(id, page)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success get data",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) Unmatch(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	err := Controller.service.Unmatch(id, c.Params("id"))
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success unmatch",
+	})
+}
+
+func (Controller *Controller) RefreshToken(c *fiber.Ctx) error {
+	var input userBusiness.RefreshToken
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.RefreshToken(input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	setTokenCookie(c, res)
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success refresh token",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) GetSessions(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	claims := c.Locals("claims").(utils.JwtTokenClaimsUser)
+	res, err := Controller.service.GetSessions(id, claims.Family)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success get data",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) RevokeSession(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	err := Controller.service.RevokeSession(id, c.Params("id"))
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success revoke session",
+	})
+}
+
+func (Controller *Controller) VerifyEmail(c *fiber.Ctx) error {
+	var input userBusiness.VerifyEmail
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	err := Controller.service.VerifyEmail(input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success verify email",
+	})
+}
+
+func (Controller *Controller) ResendVerifyEmail(c *fiber.Ctx) error {
+	var input userBusiness.ResendVerifyEmail
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	err := Controller.service.ResendVerifyEmail(input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "if the email belongs to an unverified account a new link was sent",
+	})
+}
+
+func (Controller *Controller) ForgotPassword(c *fiber.Ctx) error {
+	var input userBusiness.ForgotPassword
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	err := Controller.service.ForgotPassword(input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "if the email is registered a reset code was sent",
+	})
+}
+
+func (Controller *Controller) ResetPassword(c *fiber.Ctx) error {
+	var input userBusiness.ResetPassword
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	err := Controller.service.ResetPassword(input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success reset password",
+	})
+}
+
+func (Controller *Controller) EnrollMFA(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	res, err := Controller.service.EnrollMFA(id)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "scan the uri with an authenticator app and confirm a code",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) ConfirmMFA(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	var input userBusiness.MFACode
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.ConfirmMFA(id, input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success enable two-factor authentication",
+		"result":  res,
+	})
+}
+
+func (Controller *Controller) DisableMFA(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	var input userBusiness.MFACode
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	err := Controller.service.DisableMFA(id, input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success disable two-factor authentication",
+	})
+}
+
+func (Controller *Controller) GenerateRecoveryCodes(c *fiber.Ctx) error {
+	id := c.Locals("id").(string)
+	var input userBusiness.MFACode
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+		})
+	}
+	res, err := Controller.service.GenerateRecoveryCodes(id, input)
+	if err != nil {
+		return c.Status(utils.GetStatusCode(err)).JSON(err)
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"code":    200,
+		"message": "success generate recovery codes",
+		"result":  res,
+	})
+}
@@ -0,0 +1,24 @@
+package wellknown
+
+import (
+	"roby-backend-golang/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	keys *utils.KeySet
+}
+
+func NewController(keys *utils.KeySet) *Controller {
+	return &Controller{
+		keys: keys,
+	}
+}
+
+// JWKS publishes the public keys other services use to verify our tokens, it
+// is served as a plain jwk set so standard jwt libraries can read it.
+func (Controller *Controller) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(200).JSON(Controller.keys.JWKS())
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"roby-backend-golang/app/modules"
+	userBusiness "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/utils"
+)
+
+// RunCommand runs a maintenance command instead of the api, args start with
+// the name of the command.
+func RunCommand(conf *config.AppConfig, dbCon *utils.DatabaseConnection, args []string) error {
+	switch args[0] {
+	case "create-admin":
+		return createAdmin(conf, dbCon, args[1:])
+	}
+	return fmt.Errorf("unknown command %q, available: create-admin", args[0])
+}
+
+// createAdmin creates the first admin. The password is read from
+// ADMIN_PASSWORD so it does not end up in the shell history.
+func createAdmin(conf *config.AppConfig, dbCon *utils.DatabaseConnection, args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the admin, an existing user is promoted")
+	fullname := flags.String("fullname", "Admin", "fullname of a new admin")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	password := os.Getenv("ADMIN_PASSWORD")
+	if *email == "" || password == "" {
+		return errors.New("usage: ADMIN_PASSWORD=... create-admin -email admin@mail.com [-fullname Admin]")
+	}
+
+	service := modules.UserService(dbCon, conf)
+	id, err := service.BootstrapAdmin(userBusiness.BootstrapAdmin{
+		Email:    *email,
+		Password: password,
+		FullName: *fullname,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("admin %s ready with id %s\n", *email, id)
+	return nil
+}
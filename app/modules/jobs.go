@@ -0,0 +1,41 @@
+package modules
+
+import (
+	"fmt"
+	userBusiness "roby-backend-golang/business/user"
+	"time"
+)
+
+const (
+	entitlementExpiryInterval = time.Minute
+	paymentExpiryInterval     = 5 * time.Minute
+)
+
+// runEntitlementExpiry expires lapsed entitlements on every tick for as long
+// as the api runs. Every instance runs it, an entitlement expires only once.
+func runEntitlementExpiry(service userBusiness.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, err := service.ExpireEntitlements()
+		if err != nil {
+			fmt.Println("Error expiring entitlements: ", err)
+		}
+	}
+}
+
+// runPaymentExpiry expires the checkouts left unpaid on every tick, which
+// gives their promo code redemptions back. Every instance runs it, a payment
+// expires only once.
+func runPaymentExpiry(service userBusiness.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, err := service.ExpirePendingPayments()
+		if err != nil {
+			fmt.Println("Error expiring pending payments: ", err)
+		}
+	}
+}
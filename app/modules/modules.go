@@ -2,21 +2,98 @@ package modules
 
 import (
 	"roby-backend-golang/api"
+	adminController "roby-backend-golang/api/admin"
+	chatController "roby-backend-golang/api/chat"
+	"roby-backend-golang/api/middlewares"
 	userController "roby-backend-golang/api/user"
+	wellknownController "roby-backend-golang/api/wellknown"
+	chatBusiness "roby-backend-golang/business/chat"
+	entitlementBusiness "roby-backend-golang/business/entitlement"
 	userBusiness "roby-backend-golang/business/user"
 	"roby-backend-golang/config"
+	auditRepository "roby-backend-golang/repository/audit"
+	chatRepository "roby-backend-golang/repository/chat"
+	entitlementRepository "roby-backend-golang/repository/entitlement"
+	identityRepository "roby-backend-golang/repository/identity"
+	mailerRepository "roby-backend-golang/repository/mailer"
+	matchRepository "roby-backend-golang/repository/match"
+	paymentRepository "roby-backend-golang/repository/payment"
+	promoRepository "roby-backend-golang/repository/promo"
+	sessionRepository "roby-backend-golang/repository/session"
+	swipeRepository "roby-backend-golang/repository/swipe"
 	userRepository "roby-backend-golang/repository/user"
 	"roby-backend-golang/utils"
+	"time"
 )
 
+// idempotencyWindow is how long the response of a request sent with an
+// Idempotency-Key header is replayed.
+const idempotencyWindow = 24 * time.Hour
+
 func RegistrationModules(dbCon *utils.DatabaseConnection, conf *config.AppConfig) api.Controller {
-	userPermitRepository := userRepository.RepositoryFactory(dbCon, conf)
-	userPermitService := userBusiness.NewService(userPermitRepository, conf)
+	keySet := newKeySet(conf)
+	entitlementPermitService := newEntitlementService(dbCon, conf)
+	userPermitService := newUserService(dbCon, conf, keySet, entitlementPermitService)
 	userPermitController := userController.NewController(userPermitService)
+	go runEntitlementExpiry(userPermitService, entitlementExpiryInterval)
+	go runPaymentExpiry(userPermitService, paymentExpiryInterval)
+
+	chatHub := chatBusiness.NewHub()
+	chatPermitRepository := chatRepository.RepositoryFactory(dbCon, conf)
+	chatRelay := chatRepository.NewRedisRelay(dbCon.Redis, chatHub)
+	chatPermitService := chatBusiness.NewService(chatPermitRepository, chatRelay, chatRelay, entitlementPermitService, conf)
+	chatPermitController := chatController.NewController(chatPermitService, chatHub)
 	// Register controller
 	controller := api.Controller{
-		UserController: userPermitController,
+		UserController:      userPermitController,
+		ChatController:      chatPermitController,
+		WellKnownController: wellknownController.NewController(keySet),
+		AdminController:     adminController.NewController(userPermitService),
+		Auth:                middlewares.NewAuth(userPermitService, keySet),
+		Idempotency:         middlewares.NewIdempotency(dbCon.Redis, idempotencyWindow),
 	}
 
 	return controller
 }
+
+// UserService builds the user service alone, for commands that run next to
+// the api such as the admin bootstrap.
+func UserService(dbCon *utils.DatabaseConnection, conf *config.AppConfig) userBusiness.Service {
+	return newUserService(dbCon, conf, newKeySet(conf), newEntitlementService(dbCon, conf))
+}
+
+func newKeySet(conf *config.AppConfig) *utils.KeySet {
+	keySet, err := utils.NewKeySet(conf.Secrettoken.Token, conf.Secrettoken.KeysFile)
+	if err != nil {
+		panic(err)
+	}
+	return keySet
+}
+
+func newPaymentProvider(conf *config.AppConfig) userBusiness.PaymentProvider {
+	provider, err := paymentRepository.ProviderFactory(conf)
+	if err != nil {
+		panic(err)
+	}
+	return provider
+}
+
+func newEntitlementService(dbCon *utils.DatabaseConnection, conf *config.AppConfig) entitlementBusiness.Service {
+	featurePermitRepository := entitlementRepository.FeatureRepositoryFactory(dbCon, conf)
+	return entitlementBusiness.NewService(featurePermitRepository, conf)
+}
+
+func newUserService(dbCon *utils.DatabaseConnection, conf *config.AppConfig, keySet *utils.KeySet, entitlements entitlementBusiness.Service) userBusiness.Service {
+	userPermitRepository := userRepository.RepositoryFactory(dbCon, conf, keySet)
+	swipePermitRepository := swipeRepository.RepositoryFactory(dbCon, conf)
+	matchPermitRepository := matchRepository.RepositoryFactory(dbCon, conf)
+	sessionPermitRepository := sessionRepository.RepositoryFactory(dbCon, conf)
+	mailer := mailerRepository.MailerFactory(conf)
+	auditPermitRepository := auditRepository.RepositoryFactory(dbCon, conf)
+	entitlementPermitRepository := entitlementRepository.RepositoryFactory(dbCon, conf)
+	paymentPermitRepository := paymentRepository.RepositoryFactory(dbCon, conf)
+	promoPermitRepository := promoRepository.RepositoryFactory(dbCon, conf)
+	paymentProvider := newPaymentProvider(conf)
+	identityProviders := identityRepository.ProviderFactory(conf)
+	return userBusiness.NewService(userPermitRepository, swipePermitRepository, matchPermitRepository, sessionPermitRepository, mailer, auditPermitRepository, entitlementPermitRepository, entitlements, paymentPermitRepository, promoPermitRepository, paymentProvider, identityProviders, keySet, conf)
+}
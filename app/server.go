@@ -15,8 +15,19 @@ import (
 	fiberSwagger "github.com/swaggo/fiber-swagger"
 )
 
+// fiberConfig makes c.IP() the client behind the reverse proxy. The proxy
+// header is only believed from the trusted proxies, anyone else could forge
+// it to dodge the login lockout.
+func fiberConfig(conf *config.AppConfig) fiber.Config {
+	return fiber.Config{
+		ProxyHeader:             conf.App.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          conf.App.TrustedProxies,
+	}
+}
+
 func Run(config *config.AppConfig, dbCon *utils.DatabaseConnection) (*fiber.App, string) {
-	app := fiber.New()
+	app := fiber.New(fiberConfig(config))
 	app.Use(logger.New(logger.Config{
 		Format:     "[${time}] [${ip}:${port}] ${status} - ${latency} ${method} ${path}\n",
 		TimeFormat: "2 Jan 2006 15:04:05",
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"errors"
+	"net/http/httptest"
+	userController "roby-backend-golang/api/user"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	repoAudit "roby-backend-golang/repository/audit"
+	repoEntitlement "roby-backend-golang/repository/entitlement"
+	repoMailer "roby-backend-golang/repository/mailer"
+	repoMatch "roby-backend-golang/repository/match"
+	repoPayment "roby-backend-golang/repository/payment"
+	repoPromo "roby-backend-golang/repository/promo"
+	repoSession "roby-backend-golang/repository/session"
+	repoSwipe "roby-backend-golang/repository/swipe"
+	repoUser "roby-backend-golang/repository/user"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// failLogin posts a wrong password through the login route from behind a
+// proxy forwarding 203.0.113.7 and returns the mocked user repository.
+func failLogin(t *testing.T, conf *config.AppConfig) *repoUser.UserMock {
+	repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+	swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+	matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+	sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+	mailer := repoMailer.NewMemoryMailer()
+	auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+	entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+	paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+	promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+	service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, nil, paymentMock, promoMock, nil, nil, nil, conf)
+	repoMock.On("Get", mock.Anything).Return("", errors.New("redis: nil"))
+	repoMock.On("FindUserByEmail", "test@mail.com").Return(businessUser.User{}, errors.New("wrong email"))
+	repoMock.On("Incr", mock.Anything, 24*time.Hour).Return(int64(1), nil)
+
+	app := fiber.New(fiberConfig(conf))
+	app.Post("/login", userController.NewController(service).Login)
+
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"email": "test@mail.com", "password": "wrong-password"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderXForwardedFor, "203.0.113.7")
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 401, res.StatusCode)
+	return repoMock
+}
+
+func TestFiberConfig(t *testing.T) {
+	t.Run("Trusted Proxy Test", func(t *testing.T) {
+		conf := &config.AppConfig{}
+		conf.App.ProxyHeader = fiber.HeaderXForwardedFor
+		// the in-memory listener of app.Test connects from 0.0.0.0
+		conf.App.TrustedProxies = []string{"0.0.0.0"}
+
+		repoMock := failLogin(t, conf)
+		repoMock.AssertCalled(t, "Incr", "apptinder:loginfail:ip:203.0.113.7", 24*time.Hour)
+	})
+
+	t.Run("Untrusted Proxy Test", func(t *testing.T) {
+		conf := &config.AppConfig{}
+		conf.App.ProxyHeader = fiber.HeaderXForwardedFor
+		conf.App.TrustedProxies = []string{"10.0.0.1"}
+
+		// a client can not pick the ip its failures are counted on
+		repoMock := failLogin(t, conf)
+		repoMock.AssertNotCalled(t, "Incr", "apptinder:loginfail:ip:203.0.113.7", 24*time.Hour)
+		repoMock.AssertCalled(t, "Incr", "apptinder:loginfail:ip:0.0.0.0", 24*time.Hour)
+	})
+}
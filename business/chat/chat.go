@@ -0,0 +1,61 @@
+package chat
+
+import "time"
+
+type Message struct {
+	ID          string     `json:"id"`
+	MatchID     string     `json:"match_id"`
+	SenderID    string     `json:"sender_id"`
+	RecipientID string     `json:"recipient_id"`
+	Body        string     `json:"body"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+	ReadAt      *time.Time `json:"read_at"`
+}
+
+type Match struct {
+	ID      string   `json:"id"`
+	UserIDs []string `json:"user_ids"`
+	Status  string   `json:"status"`
+}
+
+// Other returns the party of the match that is not userID.
+func (m Match) Other(userID string) string {
+	if m.UserIDs[0] == userID {
+		return m.UserIDs[1]
+	}
+	return m.UserIDs[0]
+}
+
+type SendMessage struct {
+	MatchID string `json:"match_id" validate:"required"`
+	Body    string `json:"body" validate:"required"`
+}
+
+type MarkMessage struct {
+	MessageID string `json:"message_id" validate:"required"`
+}
+
+type HistoryQuery struct {
+	Before string `query:"before" json:"before"`
+	Limit  int    `query:"limit" json:"limit"`
+}
+
+// ClientEvent is a frame sent by a client over the websocket.
+type ClientEvent struct {
+	Type      string `json:"type"`
+	MatchID   string `json:"match_id"`
+	MessageID string `json:"message_id"`
+	Body      string `json:"body"`
+}
+
+// Event is a frame pushed to a client over the websocket.
+type Event struct {
+	Type      string   `json:"type"`
+	Message   *Message `json:"message,omitempty"`
+	MatchID   string   `json:"match_id,omitempty"`
+	MessageID string   `json:"message_id,omitempty"`
+	UserID    string   `json:"user_id,omitempty"`
+	Presence  string   `json:"presence,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
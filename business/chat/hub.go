@@ -0,0 +1,69 @@
+package chat
+
+import "sync"
+
+// Conn is the part of a websocket connection the hub writes to.
+type Conn interface {
+	WriteJSON(v interface{}) error
+}
+
+// Client serializes writes to one connection, a websocket connection only
+// supports a single concurrent writer.
+type Client struct {
+	conn Conn
+	mu   sync.Mutex
+}
+
+func (c *Client) Send(event Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(event)
+}
+
+// Hub keeps the websocket connections opened on this instance by user id.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[string]map[*Client]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		clients: make(map[string]map[*Client]struct{}),
+	}
+}
+
+func (h *Hub) Register(userID string, conn Conn) *Client {
+	client := &Client{conn: conn}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[*Client]struct{})
+	}
+	h.clients[userID][client] = struct{}{}
+
+	return client
+}
+
+func (h *Hub) Unregister(userID string, client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients[userID], client)
+	if len(h.clients[userID]) == 0 {
+		delete(h.clients, userID)
+	}
+}
+
+func (h *Hub) Notify(userID string, event Event) {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients[userID]))
+	for client := range h.clients[userID] {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		// a broken connection is dropped by its read loop
+		_ = client.Send(event)
+	}
+}
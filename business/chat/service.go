@@ -0,0 +1,271 @@
+package chat
+
+import (
+	"roby-backend-golang/business/entitlement"
+	"roby-backend-golang/config"
+	"roby-backend-golang/utils"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/exp/slices"
+)
+
+type Repository interface {
+	FindMatchByID(id string) (Match, error)
+	CreateMessage(data Message) (Message, error)
+	GetMessages(matchID string, query HistoryQuery) ([]Message, error)
+	GetActiveMatches(userID string) ([]Match, error)
+	MarkMessages(matchID, recipientID, messageID, state string, at time.Time) error
+}
+
+// Notifier delivers an event to every connection of a user.
+type Notifier interface {
+	Notify(userID string, event Event)
+}
+
+// Entitlements tells whether a user holds a feature, see the entitlement
+// service.
+type Entitlements interface {
+	Has(userID, feature string) (bool, error)
+}
+
+// Presence counts the chat connections of a user across every instance.
+type Presence interface {
+	// Connect reports true when it is the first connection of the user.
+	Connect(userID string) (bool, error)
+	// Disconnect reports true when the user has no connection left.
+	Disconnect(userID string) (bool, error)
+}
+
+const (
+	EventMessage   = "message"
+	EventPresence  = "presence"
+	EventTyping    = "typing"
+	EventDelivered = "delivered"
+	EventRead      = "read"
+	EventError     = "error"
+
+	PresenceOnline  = "online"
+	PresenceOffline = "offline"
+
+	// matches are stored by the user module with the same status values
+	matchStatusActive = "active"
+
+	maxBodyLength    = 2000
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+type Service interface {
+	SendMessage(senderID string, input SendMessage) (Message, error)
+	GetMessages(userID, matchID string, query HistoryQuery) ([]Message, error)
+	Connect(userID string) error
+	Disconnect(userID string) error
+	Typing(userID, matchID string) error
+	MarkDelivered(userID, matchID string, input MarkMessage) error
+	MarkRead(userID, matchID string, input MarkMessage) error
+}
+
+type service struct {
+	repository   Repository
+	notifier     Notifier
+	presence     Presence
+	entitlements Entitlements
+	validate     *validator.Validate
+	conf         *config.AppConfig
+}
+
+func NewService(repository Repository, notifier Notifier, presence Presence, entitlements Entitlements, conf *config.AppConfig) Service {
+	return &service{
+		repository:   repository,
+		notifier:     notifier,
+		presence:     presence,
+		entitlements: entitlements,
+		validate:     validator.New(),
+		conf:         conf,
+	}
+}
+
+// activeMatch returns the match when the user is one of its two parties and
+// the match has not been dissolved.
+func (s *service) activeMatch(userID, matchID string) (Match, error) {
+	match, err := s.repository.FindMatchByID(matchID)
+	if err != nil {
+		return Match{}, err
+	}
+
+	if !slices.Contains(match.UserIDs, userID) {
+		return Match{}, utils.HandleError(404, "match not found")
+	}
+
+	if match.Status != matchStatusActive {
+		return Match{}, utils.HandleError(403, "match is no longer active")
+	}
+
+	return match, nil
+}
+
+func (s *service) SendMessage(senderID string, input SendMessage) (Message, error) {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return Message{}, utils.HandleErrorValidator(err)
+	}
+
+	if utf8.RuneCountInString(input.Body) > maxBodyLength {
+		return Message{}, utils.HandleError(400, "body max 2000 character")
+	}
+
+	match, err := s.activeMatch(senderID, input.MatchID)
+	if err != nil {
+		return Message{}, err
+	}
+
+	recipientID := match.Other(senderID)
+
+	msg, err := s.repository.CreateMessage(Message{
+		MatchID:     match.ID,
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		Body:        input.Body,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return Message{}, utils.HandleError(500, err.Error())
+	}
+
+	// the sender gets the stored message back so other devices stay in sync
+	event := Event{Type: EventMessage, Message: &msg}
+	s.notifier.Notify(recipientID, event)
+	s.notifier.Notify(senderID, event)
+
+	return msg, nil
+}
+
+func (s *service) GetMessages(userID, matchID string, query HistoryQuery) ([]Message, error) {
+	_, err := s.activeMatch(userID, matchID)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Limit < 1 {
+		query.Limit = defaultPageLimit
+	}
+	if query.Limit > maxPageLimit {
+		query.Limit = maxPageLimit
+	}
+
+	messages, err := s.repository.GetMessages(matchID, query)
+	if err != nil {
+		return nil, utils.HandleError(500, err.Error())
+	}
+
+	receipts, err := s.entitlements.Has(userID, entitlement.FeatureReadReceipts)
+	if err != nil {
+		return nil, err
+	}
+	if !receipts {
+		for i := range messages {
+			if messages[i].SenderID == userID {
+				messages[i].ReadAt = nil
+			}
+		}
+	}
+
+	return messages, nil
+}
+
+func (s *service) Typing(userID, matchID string) error {
+	match, err := s.activeMatch(userID, matchID)
+	if err != nil {
+		return err
+	}
+
+	other := match.Other(userID)
+	allowed, err := s.entitlements.Has(other, entitlement.FeatureTypingIndicator)
+	if err != nil {
+		return err
+	}
+	if allowed {
+		s.notifier.Notify(other, Event{Type: EventTyping, MatchID: match.ID, UserID: userID})
+	}
+
+	return nil
+}
+
+func (s *service) MarkDelivered(userID, matchID string, input MarkMessage) error {
+	return s.markMessages(userID, matchID, input, EventDelivered)
+}
+
+func (s *service) MarkRead(userID, matchID string, input MarkMessage) error {
+	return s.markMessages(userID, matchID, input, EventRead)
+}
+
+// markMessages marks every message the user received in the match up to and
+// including input.MessageID, then tells the sender.
+func (s *service) markMessages(userID, matchID string, input MarkMessage, state string) error {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return utils.HandleErrorValidator(err)
+	}
+
+	match, err := s.activeMatch(userID, matchID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.MarkMessages(match.ID, userID, input.MessageID, state, time.Now())
+	if err != nil {
+		return err
+	}
+
+	sender := match.Other(userID)
+	if state == EventRead {
+		receipts, err := s.entitlements.Has(sender, entitlement.FeatureReadReceipts)
+		if err != nil {
+			return err
+		}
+		if !receipts {
+			return nil
+		}
+	}
+
+	s.notifier.Notify(sender, Event{Type: state, MatchID: match.ID, MessageID: input.MessageID, UserID: userID})
+	return nil
+}
+
+func (s *service) Connect(userID string) error {
+	first, err := s.presence.Connect(userID)
+	if err != nil {
+		return err
+	}
+	if first {
+		s.notifyPresence(userID, PresenceOnline)
+	}
+	return nil
+}
+
+func (s *service) Disconnect(userID string) error {
+	last, err := s.presence.Disconnect(userID)
+	if err != nil {
+		return err
+	}
+	if last {
+		s.notifyPresence(userID, PresenceOffline)
+	}
+	return nil
+}
+
+// notifyPresence tells every active match of the user that the user came
+// online or went offline.
+func (s *service) notifyPresence(userID, presence string) {
+	matches, err := s.repository.GetActiveMatches(userID)
+	if err != nil {
+		return
+	}
+
+	event := Event{Type: EventPresence, UserID: userID, Presence: presence}
+	for _, match := range matches {
+		s.notifier.Notify(match.Other(userID), event)
+	}
+}
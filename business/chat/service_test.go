@@ -0,0 +1,467 @@
+package chat_test
+
+import (
+	"errors"
+	businessChat "roby-backend-golang/business/chat"
+	"roby-backend-golang/business/entitlement"
+	"roby-backend-golang/config"
+	repoChat "roby-backend-golang/repository/chat"
+	"roby-backend-golang/utils"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type fakeConn struct {
+	mu     sync.Mutex
+	events []businessChat.Event
+}
+
+func (c *fakeConn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, v.(businessChat.Event))
+	return nil
+}
+
+func (c *fakeConn) Events() []businessChat.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.events
+}
+
+type entitlementsMock struct {
+	*mock.Mock
+}
+
+func (m *entitlementsMock) Has(userID, feature string) (bool, error) {
+	args := m.Called(userID, feature)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestSendMessage(t *testing.T) {
+	match := businessChat.Match{
+		ID:      "999",
+		UserIDs: []string{"123", "1234"},
+		Status:  "active",
+	}
+
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		input := businessChat.SendMessage{
+			MatchID: "999",
+			Body:    "hello",
+		}
+		stored := businessChat.Message{
+			ID:          "1",
+			MatchID:     "999",
+			SenderID:    "123",
+			RecipientID: "1234",
+			Body:        "hello",
+		}
+		hub := businessChat.NewHub()
+		recipient := &fakeConn{}
+		hub.Register("1234", recipient)
+		stranger := &fakeConn{}
+		hub.Register("555", stranger)
+
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, hub, presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		repoMock.On("CreateMessage", mock.MatchedBy(func(data businessChat.Message) bool {
+			return data.SenderID == "123" && data.RecipientID == "1234" && data.Body == "hello"
+		})).Return(stored, nil)
+
+		res, err := service.SendMessage("123", input)
+		asserting.NoError(err)
+		asserting.Equal("1", res.ID)
+		asserting.Len(recipient.Events(), 1)
+		asserting.Equal(businessChat.EventMessage, recipient.Events()[0].Type)
+		asserting.Empty(stranger.Events())
+	})
+
+	t.Run("Validation Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+
+		_, err := service.SendMessage("123", businessChat.SendMessage{MatchID: "999"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+
+	t.Run("Body Too Long Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+
+		_, err := service.SendMessage("123", businessChat.SendMessage{MatchID: "999", Body: strings.Repeat("a", 2001)})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+
+	t.Run("Not Member Of Match Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+
+		_, err := service.SendMessage("555", businessChat.SendMessage{MatchID: "999", Body: "hello"})
+		asserting.Error(err)
+		asserting.Equal(404, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "CreateMessage", mock.Anything)
+	})
+
+	t.Run("Unmatched Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		unmatched := match
+		unmatched.Status = "unmatched"
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(unmatched, nil)
+
+		_, err := service.SendMessage("123", businessChat.SendMessage{MatchID: "999", Body: "hello"})
+		asserting.Error(err)
+		asserting.Equal(403, utils.GetStatusCode(err))
+	})
+
+	t.Run("Error Create Message Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		hub := businessChat.NewHub()
+		recipient := &fakeConn{}
+		hub.Register("1234", recipient)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, hub, presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		repoMock.On("CreateMessage", mock.Anything).Return(businessChat.Message{}, errors.New("error create message"))
+
+		_, err := service.SendMessage("123", businessChat.SendMessage{MatchID: "999", Body: "hello"})
+		asserting.Error(err)
+		asserting.Empty(recipient.Events())
+	})
+}
+
+func TestGetMessages(t *testing.T) {
+	match := businessChat.Match{
+		ID:      "999",
+		UserIDs: []string{"123", "1234"},
+		Status:  "active",
+	}
+
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		messages := []businessChat.Message{{ID: "2"}, {ID: "1"}}
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		repoMock.On("GetMessages", "999", businessChat.HistoryQuery{Before: "3", Limit: 20}).Return(messages, nil)
+		featureMock.On("Has", "1234", entitlement.FeatureReadReceipts).Return(false, nil)
+
+		res, err := service.GetMessages("1234", "999", businessChat.HistoryQuery{Before: "3"})
+		asserting.NoError(err)
+		asserting.Len(res, 2)
+	})
+
+	t.Run("Max Limit Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		repoMock.On("GetMessages", "999", businessChat.HistoryQuery{Limit: 100}).Return([]businessChat.Message{}, nil)
+		featureMock.On("Has", "123", entitlement.FeatureReadReceipts).Return(false, nil)
+
+		_, err := service.GetMessages("123", "999", businessChat.HistoryQuery{Limit: 1000})
+		asserting.NoError(err)
+	})
+
+	t.Run("Not Member Of Match Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+
+		_, err := service.GetMessages("555", "999", businessChat.HistoryQuery{})
+		asserting.Error(err)
+		asserting.Equal(404, utils.GetStatusCode(err))
+	})
+
+	t.Run("Error Get Messages Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		repoMock.On("GetMessages", "999", mock.Anything).Return([]businessChat.Message{}, errors.New("error get messages"))
+
+		_, err := service.GetMessages("123", "999", businessChat.HistoryQuery{})
+		asserting.Error(err)
+	})
+
+	t.Run("Read Receipts Hidden Without Feature Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		readAt := time.Now()
+		messages := []businessChat.Message{
+			{ID: "2", SenderID: "123", RecipientID: "1234", ReadAt: &readAt},
+			{ID: "1", SenderID: "1234", RecipientID: "123", ReadAt: &readAt},
+		}
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		repoMock.On("GetMessages", "999", mock.Anything).Return(messages, nil)
+		featureMock.On("Has", "123", entitlement.FeatureReadReceipts).Return(false, nil)
+
+		res, err := service.GetMessages("123", "999", businessChat.HistoryQuery{})
+		asserting.NoError(err)
+		asserting.Nil(res[0].ReadAt)
+		asserting.NotNil(res[1].ReadAt)
+	})
+
+	t.Run("Read Receipts Shown With Feature Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		readAt := time.Now()
+		messages := []businessChat.Message{
+			{ID: "2", SenderID: "1234", RecipientID: "123", ReadAt: &readAt},
+		}
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		repoMock.On("GetMessages", "999", mock.Anything).Return(messages, nil)
+		featureMock.On("Has", "1234", entitlement.FeatureReadReceipts).Return(true, nil)
+
+		res, err := service.GetMessages("1234", "999", businessChat.HistoryQuery{})
+		asserting.NoError(err)
+		asserting.NotNil(res[0].ReadAt)
+	})
+}
+
+func TestMarkRead(t *testing.T) {
+	match := businessChat.Match{
+		ID:      "999",
+		UserIDs: []string{"123", "1234"},
+		Status:  "active",
+	}
+
+	t.Run("Receipt Sent To Premium Sender Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		hub := businessChat.NewHub()
+		sender := &fakeConn{}
+		hub.Register("1234", sender)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, hub, presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		repoMock.On("MarkMessages", "999", "123", "5", businessChat.EventRead, mock.Anything).Return(nil)
+		featureMock.On("Has", "1234", entitlement.FeatureReadReceipts).Return(true, nil)
+
+		err := service.MarkRead("123", "999", businessChat.MarkMessage{MessageID: "5"})
+		asserting.NoError(err)
+		asserting.Len(sender.Events(), 1)
+		asserting.Equal(businessChat.EventRead, sender.Events()[0].Type)
+		asserting.Equal("5", sender.Events()[0].MessageID)
+	})
+
+	t.Run("Receipt Withheld From Free Sender Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		hub := businessChat.NewHub()
+		sender := &fakeConn{}
+		hub.Register("1234", sender)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, hub, presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		repoMock.On("MarkMessages", "999", "123", "5", businessChat.EventRead, mock.Anything).Return(nil)
+		featureMock.On("Has", "1234", entitlement.FeatureReadReceipts).Return(false, nil)
+
+		err := service.MarkRead("123", "999", businessChat.MarkMessage{MessageID: "5"})
+		asserting.NoError(err)
+		asserting.Empty(sender.Events())
+		repoMock.AssertCalled(t, "MarkMessages", "999", "123", "5", businessChat.EventRead, mock.Anything)
+	})
+
+	t.Run("Delivered Always Sent Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		hub := businessChat.NewHub()
+		sender := &fakeConn{}
+		hub.Register("1234", sender)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, hub, presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		repoMock.On("MarkMessages", "999", "123", "5", businessChat.EventDelivered, mock.Anything).Return(nil)
+
+		err := service.MarkDelivered("123", "999", businessChat.MarkMessage{MessageID: "5"})
+		asserting.NoError(err)
+		asserting.Equal(businessChat.EventDelivered, sender.Events()[0].Type)
+		featureMock.AssertNotCalled(t, "Has", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Validation Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+
+		err := service.MarkRead("123", "999", businessChat.MarkMessage{})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+}
+
+func TestTyping(t *testing.T) {
+	match := businessChat.Match{
+		ID:      "999",
+		UserIDs: []string{"123", "1234"},
+		Status:  "active",
+	}
+
+	t.Run("Typing Shown With Feature Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		hub := businessChat.NewHub()
+		other := &fakeConn{}
+		hub.Register("1234", other)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, hub, presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		featureMock.On("Has", "1234", entitlement.FeatureTypingIndicator).Return(true, nil)
+
+		err := service.Typing("123", "999")
+		asserting.NoError(err)
+		asserting.Equal(businessChat.EventTyping, other.Events()[0].Type)
+	})
+
+	t.Run("Typing Hidden Without Feature Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		hub := businessChat.NewHub()
+		other := &fakeConn{}
+		hub.Register("1234", other)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, hub, presenceMock, featureMock, &config.AppConfig{})
+		repoMock.On("FindMatchByID", "999").Return(match, nil)
+		featureMock.On("Has", "1234", entitlement.FeatureTypingIndicator).Return(false, nil)
+
+		err := service.Typing("123", "999")
+		asserting.NoError(err)
+		asserting.Empty(other.Events())
+	})
+}
+
+func TestPresence(t *testing.T) {
+	matches := []businessChat.Match{
+		{ID: "999", UserIDs: []string{"123", "1234"}, Status: "active"},
+		{ID: "888", UserIDs: []string{"4321", "123"}, Status: "active"},
+	}
+
+	t.Run("First Connection Notifies Matches Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		hub := businessChat.NewHub()
+		first := &fakeConn{}
+		hub.Register("1234", first)
+		second := &fakeConn{}
+		hub.Register("4321", second)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, hub, presenceMock, featureMock, &config.AppConfig{})
+		presenceMock.On("Connect", "123").Return(true, nil)
+		repoMock.On("GetActiveMatches", "123").Return(matches, nil)
+
+		err := service.Connect("123")
+		asserting.NoError(err)
+		asserting.Len(first.Events(), 1)
+		asserting.Equal(businessChat.PresenceOnline, first.Events()[0].Presence)
+		asserting.Equal("123", second.Events()[0].UserID)
+	})
+
+	t.Run("Second Connection Is Silent Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+		presenceMock.On("Connect", "123").Return(false, nil)
+
+		err := service.Connect("123")
+		asserting.NoError(err)
+		repoMock.AssertNotCalled(t, "GetActiveMatches", mock.Anything)
+	})
+
+	t.Run("Last Disconnect Notifies Matches Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		hub := businessChat.NewHub()
+		first := &fakeConn{}
+		hub.Register("1234", first)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, hub, presenceMock, featureMock, &config.AppConfig{})
+		presenceMock.On("Disconnect", "123").Return(true, nil)
+		repoMock.On("GetActiveMatches", "123").Return(matches, nil)
+
+		err := service.Disconnect("123")
+		asserting.NoError(err)
+		asserting.Equal(businessChat.PresenceOffline, first.Events()[0].Presence)
+	})
+
+	t.Run("Error Presence Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoChat.ChatMock{Mock: &mock.Mock{}}
+		presenceMock := &repoChat.PresenceMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		service := businessChat.NewService(repoMock, businessChat.NewHub(), presenceMock, featureMock, &config.AppConfig{})
+		presenceMock.On("Connect", "123").Return(false, errors.New("error presence"))
+
+		err := service.Connect("123")
+		asserting.Error(err)
+	})
+}
+
+func TestHub(t *testing.T) {
+	t.Run("Unregister Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		hub := businessChat.NewHub()
+		first := &fakeConn{}
+		second := &fakeConn{}
+		client := hub.Register("123", first)
+		hub.Register("123", second)
+		hub.Unregister("123", client)
+
+		hub.Notify("123", businessChat.Event{Type: businessChat.EventMessage})
+		asserting.Empty(first.Events())
+		asserting.Len(second.Events(), 1)
+	})
+}
@@ -0,0 +1,28 @@
+package entitlement
+
+// Features a package can grant. Business logic gates on these, never on the
+// name of a package. A feature is only added here together with the check
+// that enforces it.
+const (
+	FeatureUnlimitedSwipes = "unlimited_swipes"
+	// the other party sees read receipts and the typing indicator only when
+	// the viewer holds these
+	FeatureReadReceipts    = "read_receipts"
+	FeatureTypingIndicator = "typing_indicator"
+)
+
+var features = []string{
+	FeatureUnlimitedSwipes,
+	FeatureReadReceipts,
+	FeatureTypingIndicator,
+}
+
+// IsFeature reports whether the feature exists.
+func IsFeature(feature string) bool {
+	for _, f := range features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package entitlement
+
+import (
+	"roby-backend-golang/config"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+type Repository interface {
+	// GetFeatures lists the features granted by the packages the user holds
+	// at the time.
+	GetFeatures(userID string, at time.Time) ([]string, error)
+}
+
+// Service answers what a user is entitled to, every feature gate goes through
+// it.
+type Service interface {
+	Has(userID, feature string) (bool, error)
+	Features(userID string) ([]string, error)
+}
+
+type service struct {
+	repository Repository
+	conf       *config.AppConfig
+}
+
+func NewService(repository Repository, conf *config.AppConfig) Service {
+	return &service{
+		repository: repository,
+		conf:       conf,
+	}
+}
+
+func (s *service) Has(userID, feature string) (bool, error) {
+	features, err := s.Features(userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(features, feature), nil
+}
+
+func (s *service) Features(userID string) ([]string, error) {
+	return s.repository.GetFeatures(userID, time.Now())
+}
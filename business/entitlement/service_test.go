@@ -0,0 +1,58 @@
+package entitlement_test
+
+import (
+	"errors"
+	businessEntitlement "roby-backend-golang/business/entitlement"
+	"roby-backend-golang/config"
+	repoEntitlement "roby-backend-golang/repository/entitlement"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHas(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		service := businessEntitlement.NewService(repoMock, &config.AppConfig{})
+		repoMock.On("GetFeatures", "123", mock.AnythingOfType("time.Time")).Return([]string{businessEntitlement.FeatureUnlimitedSwipes}, nil)
+
+		has, err := service.Has("123", businessEntitlement.FeatureUnlimitedSwipes)
+		asserting.NoError(err)
+		asserting.True(has)
+
+		has, err = service.Has("123", businessEntitlement.FeatureReadReceipts)
+		asserting.NoError(err)
+		asserting.False(has)
+	})
+
+	t.Run("No Package Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		service := businessEntitlement.NewService(repoMock, &config.AppConfig{})
+		repoMock.On("GetFeatures", "123", mock.AnythingOfType("time.Time")).Return([]string(nil), nil)
+
+		has, err := service.Has("123", businessEntitlement.FeatureTypingIndicator)
+		asserting.NoError(err)
+		asserting.False(has)
+	})
+
+	t.Run("Repository Error Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		service := businessEntitlement.NewService(repoMock, &config.AppConfig{})
+		repoMock.On("GetFeatures", "123", mock.AnythingOfType("time.Time")).Return([]string(nil), errors.New("error get features"))
+
+		_, err := service.Has("123", businessEntitlement.FeatureUnlimitedSwipes)
+		asserting.Error(err)
+	})
+}
+
+func TestIsFeature(t *testing.T) {
+	asserting := assert.New(t)
+	asserting.True(businessEntitlement.IsFeature(businessEntitlement.FeatureReadReceipts))
+	asserting.False(businessEntitlement.IsFeature("premium"))
+	// nothing enforces these yet, so they can not be sold
+	asserting.False(businessEntitlement.IsFeature("rewind"))
+}
@@ -0,0 +1,111 @@
+package user
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+var invoiceTemplate = template.Must(template.New("invoice").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: sans-serif; max-width: 640px; margin: 40px auto; color: #222; }
+table { width: 100%; border-collapse: collapse; }
+th, td { text-align: left; padding: 8px 0; border-bottom: 1px solid #ddd; }
+td.amount { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Tinder App</h1>
+<p>
+Invoice {{.Number}}<br>
+Date {{.Date}}<br>
+Status {{.Status}}{{if .Reference}}<br>
+Reference {{.Reference}}{{end}}
+</p>
+<p>
+Billed to<br>
+{{.FullName}}<br>
+{{.Email}}
+</p>
+<table>
+<tr><th>Package</th><th>Billing</th><th class="amount">Amount</th></tr>
+<tr><td>{{.PackageName}}</td><td>{{.BillingPeriod}}</td><td class="amount">{{.Price}}</td></tr>{{if .PromoCode}}
+<tr><td colspan="2">Promo code {{.PromoCode}}</td><td class="amount">-{{.Discount}}</td></tr>{{end}}
+<tr><th colspan="2">Total</th><th class="amount">{{.Amount}}</th></tr>
+</table>
+</body>
+</html>
+`))
+
+type invoiceData struct {
+	Number        string
+	Date          string
+	Status        string
+	Reference     string
+	FullName      string
+	Email         string
+	PackageName   string
+	BillingPeriod string
+	Price         string
+	PromoCode     string
+	Discount      string
+	Amount        string
+}
+
+func renderInvoice(user User, payment Payment) ([]byte, error) {
+	billingPeriod := payment.Package.BillingPeriod
+	if billingPeriod == "" {
+		billingPeriod = BillingOneOff
+	}
+
+	var buf bytes.Buffer
+	err := invoiceTemplate.Execute(&buf, invoiceData{
+		Number:        strings.ToUpper(payment.ID),
+		Date:          payment.CreatedAt.UTC().Format("2 January 2006"),
+		Status:        payment.Status,
+		Reference:     payment.Reference,
+		FullName:      user.FullName,
+		Email:         user.Email,
+		PackageName:   payment.Package.PackageName,
+		BillingPeriod: strings.ReplaceAll(billingPeriod, "_", "-"),
+		Price:         formatAmount(payment.Amount+payment.Discount, payment.Currency),
+		PromoCode:     payment.PromoCode,
+		Discount:      formatAmount(payment.Discount, payment.Currency),
+		Amount:        formatAmount(payment.Amount, payment.Currency),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// zeroDecimalCurrencies have no minor unit, their amounts are whole units.
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "ISK": true,
+	"JPY": true, "KMF": true, "KRW": true, "PYG": true, "RWF": true,
+	"UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true,
+	"XPF": true,
+}
+
+// formatAmount writes an amount in the minor unit of the currency in major
+// units, 999 USD is "USD 9.99".
+func formatAmount(amount int64, currency string) string {
+	if currency == "" {
+		return "free"
+	}
+	if zeroDecimalCurrencies[currency] {
+		return fmt.Sprintf("%s %d", currency, amount)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s %s%d.%02d", currency, sign, amount/100, amount%100)
+}
@@ -1,9 +1,12 @@
 package user
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"mime/multipart"
+	"net/url"
+	"roby-backend-golang/business/entitlement"
 	"roby-backend-golang/config"
 	"roby-backend-golang/utils"
 	"strings"
@@ -16,43 +19,299 @@ import (
 type Repository interface {
 	FindUserByID(id string) (User, error)
 	FindUserByEmail(email string) (User, error)
-	CreateUser(data Register) error
+	CreateUser(data Register) (string, error)
 	GetRandomUser(id []string) (ResponseRandomUser, error)
 	UploadImageS3(file *multipart.FileHeader) (string, error)
-	PurchasePackage(id string, packages []string) error
 	GetListPackage() ([]Package, error)
 	GetMe(id string) (User, error)
-	UpdatePackageUser(id string, idPackage []string) error
 	GetPackageByID(id string) (Package, error)
+	GetAllPackages() ([]Package, error)
+	CreatePackage(data PackageInput) (Package, error)
+	UpdatePackage(id string, data PackageInput) error
+	ArchivePackage(id string, at time.Time) error
+	ReorderPackages(ids []string) error
 	GenerateTokenAuth(id, email string) (*utils.Token, error)
+	GenerateTokenFamily(id, email, family string, roles []string) (*utils.Token, error)
+	GetTokenVersion(id string) (int64, error)
+	IncrTokenVersion(id string) error
+	GenerateVerifyEmailToken(id, email string) (string, error)
+	SetEmailVerified(id string, at time.Time) error
+	UpdatePassword(id, password string) error
+	GenerateMFAChallenge(id, email string) (string, error)
+	SetMFAPendingSecret(id, secret string) error
+	EnableMFA(id, secret string, recoveryCodes []string) error
+	SetRecoveryCodes(id string, recoveryCodes []string) error
+	UseRecoveryCode(id, hashedCode string) (bool, error)
+	DisableMFA(id string) error
+	FindUserByIdentity(provider, subject string) (User, error)
+	LinkIdentity(id string, identity Identity, at time.Time) error
+	CreateOAuthUser(identity Identity) (string, error)
+	CompleteProfile(id, fullName, photoUrl string) error
+	SetRoles(id string, roles []string) error
+	CountUsersByRole(role string) (int64, error)
 	// Redis
 	Set(key string, value interface{}, expiration time.Duration) error
 	Get(key string) (string, error)
+	GetDel(key string) (string, error)
+	Incr(key string, expiration time.Duration) (int64, error)
+	IncrBy(key string, value int64, expiration time.Duration) (int64, error)
 	Del(keys string) error
 }
+
+type SwipeRepository interface {
+	CreateSwipe(data Swipe) error
+	CountSwipeSince(swiperID string, since time.Time) (int64, error)
+	GetSwipedUserIDs(swiperID string) ([]string, error)
+	IsLiked(swiperID, targetID string) (bool, error)
+}
+
+type MatchRepository interface {
+	CreateMatch(data Match) (Match, error)
+	GetMatches(userID string, page Pagination) ([]ResponseMatch, int64, error)
+	FindMatchByID(id string) (Match, error)
+	Unmatch(id, userID string, at time.Time) error
+	GetUnmatchedUserIDs(userID string) ([]string, error)
+}
+
+// Mailer delivers transactional email such as the verification link.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// IdentityProvider signs users in with an external account through the
+// authorization code flow with PKCE.
+type IdentityProvider interface {
+	AuthCodeURL(state, codeChallenge, nonce string) (string, error)
+	Exchange(code, codeVerifier string) (Identity, error)
+}
+
+type AuditRepository interface {
+	CreateAuditEvent(data AuditEvent) error
+}
+
+type EntitlementRepository interface {
+	CreateEntitlement(data Entitlement) (Entitlement, error)
+	// RevokeEntitlement ends the entitlement granted by the payment.
+	RevokeEntitlement(paymentID string, at time.Time) error
+	// ExpireEntitlements marks up to limit active entitlements that ended by
+	// at as expired and returns them, each one is returned to one caller only.
+	ExpireEntitlements(at time.Time, limit int) ([]Entitlement, error)
+	// ExpireLapsedEntitlements does the same for the active entitlements of
+	// the user to the package, so a renewal is not refused.
+	ExpireLapsedEntitlements(userID, packageID string, at time.Time) ([]Entitlement, error)
+}
+
+type PaymentRepository interface {
+	CreatePayment(data Payment) (Payment, error)
+	FindPaymentByID(id string) (Payment, error)
+	FindPaymentByReference(reference string) (Payment, error)
+	SetPaymentReference(id, reference string) error
+	// UpdatePaymentStatus moves the payment from one status to another, it
+	// reports false when the payment was not in the from status anymore.
+	UpdatePaymentStatus(id, from, to string, at time.Time) (bool, error)
+	// GetPaymentsByUser pages through the payments of the user, newest first.
+	GetPaymentsByUser(userID string, page Pagination) ([]Payment, int64, error)
+	// ExpirePendingPayments marks up to limit payments still pending since
+	// before as expired and returns them, each to one caller only.
+	ExpirePendingPayments(before, at time.Time, limit int) ([]Payment, error)
+}
+
+type PromoRepository interface {
+	CreatePromoCode(data PromoCode) (PromoCode, error)
+	GetPromoCodes() ([]PromoCode, error)
+	FindPromoCodeByID(id string) (PromoCode, error)
+	FindPromoCodeByCode(code string) (PromoCode, error)
+	// RedeemPromoCode records the redemption when neither limit of the code is
+	// reached, in a way concurrent purchases can not exceed them.
+	RedeemPromoCode(promo PromoCode, data PromoRedemption) error
+	// ReleasePromoRedemption gives the redemption of the payment back, a
+	// second call changes nothing.
+	ReleasePromoRedemption(paymentID string) error
+	// ConfirmPromoRedemption keeps the redemption of a paid payment, a second
+	// call changes nothing.
+	ConfirmPromoRedemption(paymentID string) error
+	GetPromoCodeUsage(id string) (PromoCodeUsage, error)
+}
+
+// PaymentProvider takes the payments. The outcome of a checkout is only
+// learned from a webhook of the provider.
+type PaymentProvider interface {
+	CreateCheckout(payment Payment) (Checkout, error)
+	VerifyWebhook(payload []byte, signature string) (PaymentEvent, error)
+	Refund(reference string, amount int64, currency string) error
+}
+
+// Entitlements tells whether a user holds a feature, see the entitlement
+// service.
+type Entitlements interface {
+	Has(userID, feature string) (bool, error)
+}
+
+type SessionRepository interface {
+	CreateSession(data Session) (Session, error)
+	GetSessions(userID string, at time.Time) ([]Session, error)
+	TouchSession(id string, at time.Time) error
+	RevokeSession(id, userID string, at time.Time) error
+	RevokeSessions(userID string, at time.Time) error
+}
+
+const (
+	dailySwipeLimit = 10
+
+	MatchStatusActive    = "active"
+	MatchStatusUnmatched = "unmatched"
+
+	defaultPageLimit = 10
+	maxPageLimit     = 50
+
+	resetCodeLength      = 6
+	resetCodeExpired     = 15 * time.Minute
+	resetCodeMaxAttempts = 5
+	resetCodeMaxRequests = 3
+
+	loginFailureWindow    = 24 * time.Hour
+	loginAccountThreshold = 5
+	loginIPThreshold      = 20
+	loginLockBase         = time.Minute
+	loginLockMax          = time.Hour
+
+	mfaIssuer               = "Tinder App"
+	mfaCodeLength           = 6
+	mfaRecoveryCodes        = 10
+	mfaRecoveryCodeLength   = 10
+	mfaChallengeMaxAttempts = 5
+	// a totp code stays valid for up to three periods with the allowed drift
+	mfaCodeReuseWindow = 90 * time.Second
+
+	oauthStateExpired = 10 * time.Minute
+
+	EntitlementActive  = "active"
+	EntitlementExpired = "expired"
+	EntitlementRevoked = "revoked"
+	// entitlements expired per run of the expiry job, the rest waits for the
+	// next run
+	entitlementExpiryBatch = 500
+
+	PaymentPending  = "pending"
+	PaymentPaid     = "paid"
+	PaymentFailed   = "failed"
+	PaymentRefunded = "refunded"
+	// PaymentExpired is a checkout left unpaid for pendingPaymentTTL
+	PaymentExpired = "expired"
+	// pendingPaymentTTL outlasts the checkout of the payment provider, a
+	// payment still arriving later is refunded
+	pendingPaymentTTL = 24 * time.Hour
+	// pending payments expired per run of the expiry job
+	paymentExpiryBatch = 500
+
+	PromoPercent = "percent"
+	PromoFixed   = "fixed"
+
+	// PromoRedeemed reserves the redemption while the payment is pending,
+	// PromoPaid keeps it for good once the payment is paid
+	PromoRedeemed = "redeemed"
+	PromoPaid     = "paid"
+	PromoReleased = "released"
+
+	BillingOneOff  = "one_off"
+	BillingMonthly = "monthly"
+	BillingYearly  = "yearly"
+
+	AuditLoginLockout   = "login_lockout"
+	AuditIdentityLinked = "identity_linked"
+	AuditRolesChanged   = "roles_changed"
+	// AuditEntitlementExpired is recorded by the expiry job for every lapsed
+	// entitlement
+	AuditEntitlementExpired = "entitlement_expired"
+)
+
+// dummyPassword is compared when the email is unknown so a failed login takes
+// as long whether or not the account exists.
+const dummyPassword = "$2a$10$FAZjzG8zWMhzEEp1FqiyCeYoYWlFX0kfb/2bI5M3k6/w8mZgxADNS"
+
 type Service interface {
 	Login(auth AuthLogin) (*ResponseLogin, error)
 	RegisterUser(data Register) error
 	GetUserByID(id string) (User, error)
 	GetRandomUser(id string) (ResponseRandomUser, error)
-	SwipeUser(id string, input SwipeUser) error
-	PurchasePackage(id, packages string) error
-	GetListPackage() ([]Package, error)
+	SwipeUser(id string, input SwipeUser) (ResponseSwipe, error)
+	PurchasePackage(id string, input Purchase) (ResponsePurchase, error)
+	HandlePaymentWebhook(payload []byte, signature string) error
+	RefundPayment(id string) error
+	GetPurchaseHistory(id string, page Pagination) (ResponseListPurchase, error)
+	GetInvoice(id, paymentID string) ([]byte, error)
+	GetListPackage(query PackageQuery) ([]Package, error)
 	GetMe(id string) (User, error)
 	GetPackageByID(id string) (Package, error)
+	GetAllPackages() ([]Package, error)
+	CreatePackage(input PackageInput) (Package, error)
+	UpdatePackage(id string, input PackageInput) (Package, error)
+	ArchivePackage(id string) error
+	ReorderPackages(input ReorderPackages) error
+	GetMatches(id string, page Pagination) (ResponseListMatch, error)
+	Unmatch(id, matchID string) error
+	RefreshToken(input RefreshToken) (*utils.Token, error)
+	ValidateAccessToken(claims utils.JwtTokenClaimsUser) error
+	Logout(claims utils.JwtTokenClaimsUser) error
+	LogoutAll(id string) error
+	GetSessions(id, current string) ([]Session, error)
+	RevokeSession(id, sessionID string) error
+	VerifyEmail(input VerifyEmail) error
+	ResendVerifyEmail(input ResendVerifyEmail) error
+	ForgotPassword(input ForgotPassword) error
+	ResetPassword(input ResetPassword) error
+	LoginMFA(input LoginMFA) (*ResponseLogin, error)
+	EnrollMFA(id string) (ResponseEnrollMFA, error)
+	ConfirmMFA(id string, input MFACode) (ResponseRecoveryCodes, error)
+	DisableMFA(id string, input MFACode) error
+	GenerateRecoveryCodes(id string, input MFACode) (ResponseRecoveryCodes, error)
+	StartOAuth(provider string) (ResponseOAuthStart, error)
+	OAuthCallback(provider string, input OAuthCallback) (*ResponseLogin, error)
+	CompleteProfile(id string, input CompleteProfile) error
+	SetRoles(actorID, id string, input SetRoles) error
+	BootstrapAdmin(input BootstrapAdmin) (string, error)
+	ExpireEntitlements() (int, error)
+	ExpirePendingPayments() (int, error)
+	CreatePromoCode(input PromoCodeInput) (PromoCode, error)
+	GetPromoCodes() ([]PromoCode, error)
+	GetPromoCodeUsage(id string) (PromoCodeUsage, error)
 }
 
 type service struct {
-	repository Repository
-	validate   *validator.Validate
-	conf       *config.AppConfig
+	repository            Repository
+	swipeRepository       SwipeRepository
+	matchRepository       MatchRepository
+	sessionRepository     SessionRepository
+	mailer                Mailer
+	auditRepository       AuditRepository
+	entitlementRepository EntitlementRepository
+	entitlements          Entitlements
+	paymentRepository     PaymentRepository
+	promoRepository       PromoRepository
+	paymentProvider       PaymentProvider
+	providers             map[string]IdentityProvider
+	keys                  *utils.KeySet
+	validate              *validator.Validate
+	conf                  *config.AppConfig
 }
 
-func NewService(repository Repository, conf *config.AppConfig) Service {
+func NewService(repository Repository, swipeRepository SwipeRepository, matchRepository MatchRepository, sessionRepository SessionRepository, mailer Mailer, auditRepository AuditRepository, entitlementRepository EntitlementRepository, entitlements Entitlements, paymentRepository PaymentRepository, promoRepository PromoRepository, paymentProvider PaymentProvider, providers map[string]IdentityProvider, keys *utils.KeySet, conf *config.AppConfig) Service {
 	return &service{
-		repository: repository,
-		validate:   validator.New(),
-		conf:       conf,
+		repository:            repository,
+		swipeRepository:       swipeRepository,
+		matchRepository:       matchRepository,
+		sessionRepository:     sessionRepository,
+		mailer:                mailer,
+		auditRepository:       auditRepository,
+		entitlementRepository: entitlementRepository,
+		entitlements:          entitlements,
+		paymentRepository:     paymentRepository,
+		promoRepository:       promoRepository,
+		paymentProvider:       paymentProvider,
+		providers:             providers,
+		keys:                  keys,
+		validate:              validator.New(),
+		conf:                  conf,
 	}
 }
 
@@ -62,29 +321,381 @@ func (s *service) Login(auth AuthLogin) (*ResponseLogin, error) {
 		return nil, utils.HandleErrorValidator(err)
 	}
 
+	limits := newLoginLimits(auth)
+	for _, limit := range limits {
+		locked, _ := s.repository.Get(limit.lockKey())
+		if locked != "" {
+			return nil, utils.HandleError(429, "too many failed login attempts, try again later")
+		}
+	}
+
+	// unknown email and wrong password answer the same so accounts can not be
+	// discovered through the login form
 	user, err := s.repository.FindUserByEmail(auth.Email)
 	if err != nil {
-		return nil, err
+		_ = utils.VerifyPassword(dummyPassword, auth.Password)
+		s.loginFailed(limits, auth)
+		return nil, utils.HandleError(401, "invalid email or password")
 	}
 
 	err = utils.VerifyPassword(user.Password, auth.Password)
 	if err != nil {
-		fmt.Println(user.Password)
-		return nil, errors.New("wrong password")
+		s.loginFailed(limits, auth)
+		return nil, utils.HandleError(401, "invalid email or password")
+	}
+
+	return s.completeLogin(user, limits, auth.Device, auth.IP, auth.UserAgent)
+}
+
+// completeLogin starts the session of a user who proved the first factor, or
+// hands out the mfa token when a code is still needed. The failures are only
+// cleared once the second factor passed too, else a known password would reset
+// the count between guesses of the code.
+func (s *service) completeLogin(user User, limits []loginLimit, device, ip, userAgent string) (*ResponseLogin, error) {
+	if user.MFAEnabled {
+		challenge, err := s.repository.GenerateMFAChallenge(user.ID, user.Email)
+		if err != nil {
+			return nil, utils.HandleError(500, err.Error())
+		}
+		return &ResponseLogin{
+			Email:       user.Email,
+			MFARequired: true,
+			MFAToken:    challenge,
+		}, nil
+	}
+
+	return s.startSession(user, limits, device, ip, userAgent)
+}
+
+// startSession finishes a login, the session id doubles as the refresh token
+// family of this login.
+func (s *service) startSession(user User, limits []loginLimit, device, ip, userAgent string) (*ResponseLogin, error) {
+	_ = s.repository.Del(limits[0].failKey())
+
+	if device == "" {
+		device = userAgent
+	}
+	session, err := s.sessionRepository.CreateSession(Session{
+		UserID:    user.ID,
+		Device:    device,
+		IP:        ip,
+		UserAgent: userAgent,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	restoken, err := s.repository.GenerateTokenAuth(user.ID, user.Email)
+	restoken, err := s.repository.GenerateTokenFamily(user.ID, user.Email, session.ID, user.Roles)
 	if err != nil {
 		return nil, err
 	}
 
 	return &ResponseLogin{
-		Email:   user.Email,
-		Package: user.Packages,
-		Token:   *restoken,
+		Email:             user.Email,
+		Package:           user.Packages,
+		Token:             restoken,
+		ProfileIncomplete: !user.ProfileCompleted,
+	}, nil
+}
+
+// LoginMFA trades the mfa token of a login for a token pair. A wrong code
+// counts as a failed login and the mfa token dies after a few of them.
+func (s *service) LoginMFA(input LoginMFA) (*ResponseLogin, error) {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return nil, utils.HandleErrorValidator(err)
+	}
+
+	invalid := utils.HandleError(401, "invalid or expired mfa token")
+
+	claims, err := utils.ParseMFAChallengeToken(input.MFAToken, s.keys)
+	if err != nil {
+		return nil, invalid
+	}
+
+	auth := AuthLogin{Email: claims.Email, IP: input.IP}
+	limits := newLoginLimits(auth)
+	for _, limit := range limits {
+		locked, _ := s.repository.Get(limit.lockKey())
+		if locked != "" {
+			return nil, utils.HandleError(429, "too many failed login attempts, try again later")
+		}
+	}
+
+	keyChallenge := fmt.Sprintf("apptinder:mfachallenge:%s", claims.Jti)
+	owner, err := s.repository.Get(keyChallenge)
+	if err != nil || owner != claims.Sub {
+		return nil, invalid
+	}
+
+	attempts, err := s.repository.Incr(fmt.Sprintf("apptinder:mfaattempts:%s", claims.Jti), utils.MFAChallengeExpired*time.Second)
+	if err != nil {
+		return nil, utils.HandleError(500, err.Error())
+	}
+	if attempts > mfaChallengeMaxAttempts {
+		_ = s.repository.Del(keyChallenge)
+		return nil, invalid
+	}
+
+	user, err := s.repository.GetMe(claims.Sub)
+	if err != nil || !user.MFAEnabled {
+		return nil, invalid
+	}
+
+	ok, err := s.verifyMFACode(user, input.Code, true)
+	if err != nil {
+		return nil, utils.HandleError(500, err.Error())
+	}
+	if !ok {
+		s.loginFailed(limits, auth)
+		return nil, utils.HandleError(401, "invalid two-factor code")
+	}
+
+	// GetDel makes sure one mfa token starts one session
+	owner, err = s.repository.GetDel(keyChallenge)
+	if err != nil || owner != claims.Sub {
+		return nil, invalid
+	}
+	_ = s.repository.Del(fmt.Sprintf("apptinder:mfaattempts:%s", claims.Jti))
+
+	return s.startSession(user, limits, input.Device, input.IP, input.UserAgent)
+}
+
+// EnrollMFA starts two-factor enrollment with a new secret, two-factor is only
+// on once ConfirmMFA received a code of it.
+func (s *service) EnrollMFA(id string) (ResponseEnrollMFA, error) {
+	user, err := s.repository.GetMe(id)
+	if err != nil {
+		return ResponseEnrollMFA{}, err
+	}
+	if user.MFAEnabled {
+		return ResponseEnrollMFA{}, utils.HandleError(400, "two-factor authentication already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return ResponseEnrollMFA{}, utils.HandleError(500, err.Error())
+	}
+	err = s.repository.SetMFAPendingSecret(id, secret)
+	if err != nil {
+		return ResponseEnrollMFA{}, utils.HandleError(500, err.Error())
+	}
+
+	return ResponseEnrollMFA{
+		Secret: secret,
+		URI:    utils.TOTPURI(mfaIssuer, user.Email, secret),
 	}, nil
 }
 
+// ConfirmMFA enables two-factor with the enrolled secret and returns the
+// recovery codes, they are shown this one time.
+func (s *service) ConfirmMFA(id string, input MFACode) (ResponseRecoveryCodes, error) {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return ResponseRecoveryCodes{}, utils.HandleErrorValidator(err)
+	}
+
+	user, err := s.repository.GetMe(id)
+	if err != nil {
+		return ResponseRecoveryCodes{}, err
+	}
+	if user.MFAEnabled {
+		return ResponseRecoveryCodes{}, utils.HandleError(400, "two-factor authentication already enabled")
+	}
+	if user.MFAPendingSecret == "" {
+		return ResponseRecoveryCodes{}, utils.HandleError(400, "two-factor enrollment not started")
+	}
+
+	if !s.verifyTOTP(id, user.MFAPendingSecret, input.Code) {
+		return ResponseRecoveryCodes{}, utils.HandleError(400, "invalid two-factor code")
+	}
+
+	codes, hashed, err := newRecoveryCodes()
+	if err != nil {
+		return ResponseRecoveryCodes{}, utils.HandleError(500, err.Error())
+	}
+	err = s.repository.EnableMFA(id, user.MFAPendingSecret, hashed)
+	if err != nil {
+		return ResponseRecoveryCodes{}, utils.HandleError(500, err.Error())
+	}
+
+	return ResponseRecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// DisableMFA turns two-factor off, it takes a code so a stolen access token
+// alone can not remove the second factor.
+func (s *service) DisableMFA(id string, input MFACode) error {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return utils.HandleErrorValidator(err)
+	}
+
+	user, err := s.repository.GetMe(id)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled {
+		return utils.HandleError(400, "two-factor authentication not enabled")
+	}
+
+	ok, err := s.verifyMFACode(user, input.Code, true)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	if !ok {
+		return utils.HandleError(400, "invalid two-factor code")
+	}
+
+	err = s.repository.DisableMFA(id)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	return nil
+}
+
+// GenerateRecoveryCodes replaces every recovery code of the user, it needs a
+// code of the authenticator app.
+func (s *service) GenerateRecoveryCodes(id string, input MFACode) (ResponseRecoveryCodes, error) {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return ResponseRecoveryCodes{}, utils.HandleErrorValidator(err)
+	}
+
+	user, err := s.repository.GetMe(id)
+	if err != nil {
+		return ResponseRecoveryCodes{}, err
+	}
+	if !user.MFAEnabled {
+		return ResponseRecoveryCodes{}, utils.HandleError(400, "two-factor authentication not enabled")
+	}
+
+	ok, err := s.verifyMFACode(user, input.Code, false)
+	if err != nil {
+		return ResponseRecoveryCodes{}, utils.HandleError(500, err.Error())
+	}
+	if !ok {
+		return ResponseRecoveryCodes{}, utils.HandleError(400, "invalid two-factor code")
+	}
+
+	codes, hashed, err := newRecoveryCodes()
+	if err != nil {
+		return ResponseRecoveryCodes{}, utils.HandleError(500, err.Error())
+	}
+	err = s.repository.SetRecoveryCodes(id, hashed)
+	if err != nil {
+		return ResponseRecoveryCodes{}, utils.HandleError(500, err.Error())
+	}
+
+	return ResponseRecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// verifyMFACode checks a code of the authenticator app, or uses up a recovery
+// code when recovery is allowed. The two are told apart by their length.
+func (s *service) verifyMFACode(user User, code string, recovery bool) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == mfaCodeLength {
+		return s.verifyTOTP(user.ID, user.MFASecret, code), nil
+	}
+	if !recovery {
+		return false, nil
+	}
+
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	if len(code) != mfaRecoveryCodeLength {
+		return false, nil
+	}
+	return s.repository.UseRecoveryCode(user.ID, utils.HashCode(code))
+}
+
+// verifyTOTP accepts every code once, a code seen on the wire can not be
+// replayed while it is still valid.
+func (s *service) verifyTOTP(id, secret, code string) bool {
+	step, ok := utils.VerifyTOTP(secret, code, time.Now())
+	if !ok {
+		return false
+	}
+
+	used, err := s.repository.Incr(fmt.Sprintf("apptinder:totpused:%s:%d", id, step), mfaCodeReuseWindow)
+	return err == nil && used == 1
+}
+
+// newRecoveryCodes returns the codes to show the user and their hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, mfaRecoveryCodes)
+	hashed := make([]string, 0, mfaRecoveryCodes)
+	for i := 0; i < mfaRecoveryCodes; i++ {
+		code, err := utils.GenerateCode(mfaRecoveryCodeLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		half := mfaRecoveryCodeLength / 2
+		codes = append(codes, code[:half]+"-"+code[half:])
+		hashed = append(hashed, utils.HashCode(code))
+	}
+	return codes, hashed, nil
+}
+
+// loginLimit counts failed logins of one account or one ip, reaching the
+// threshold locks it out for a time that doubles with every further failure.
+type loginLimit struct {
+	scope     string
+	subject   string
+	threshold int64
+}
+
+func newLoginLimits(auth AuthLogin) []loginLimit {
+	limits := []loginLimit{
+		{scope: "account", subject: strings.ToLower(strings.TrimSpace(auth.Email)), threshold: loginAccountThreshold},
+	}
+	if auth.IP != "" {
+		limits = append(limits, loginLimit{scope: "ip", subject: auth.IP, threshold: loginIPThreshold})
+	}
+	return limits
+}
+
+func (l loginLimit) failKey() string {
+	return fmt.Sprintf("apptinder:loginfail:%s:%s", l.scope, l.subject)
+}
+
+func (l loginLimit) lockKey() string {
+	return fmt.Sprintf("apptinder:loginlock:%s:%s", l.scope, l.subject)
+}
+
+func (l loginLimit) lockDuration(failures int64) time.Duration {
+	exceeded := failures - l.threshold
+	if exceeded >= 6 {
+		return loginLockMax
+	}
+	lock := loginLockBase << exceeded
+	if lock > loginLockMax {
+		return loginLockMax
+	}
+	return lock
+}
+
+func (s *service) loginFailed(limits []loginLimit, auth AuthLogin) {
+	for _, limit := range limits {
+		failures, err := s.repository.Incr(limit.failKey(), loginFailureWindow)
+		if err != nil || failures < limit.threshold {
+			continue
+		}
+
+		lock := limit.lockDuration(failures)
+		err = s.repository.Set(limit.lockKey(), "locked", lock)
+		if err != nil {
+			continue
+		}
+		_ = s.auditRepository.CreateAuditEvent(AuditEvent{
+			Type:      AuditLoginLockout,
+			Email:     auth.Email,
+			IP:        auth.IP,
+			Detail:    fmt.Sprintf("%s locked for %s after %d failed logins", limit.scope, lock, failures),
+			CreatedAt: time.Now(),
+		})
+	}
+}
+
 func (s *service) RegisterUser(data Register) error {
 	err := s.validate.Struct(&data)
 	if err != nil {
@@ -101,44 +712,90 @@ func (s *service) RegisterUser(data Register) error {
 		return err
 	}
 
-	err = s.repository.CreateUser(data)
+	id, err := s.repository.CreateUser(data)
 	if err != nil {
 		return err
 	}
+
+	// the account exists at this point, a lost mail can be sent again
+	_ = s.sendVerifyEmail(id, data.Email)
 	return nil
 }
 
-func (s *service) GetUserByID(id string) (User, error) {
-	return s.repository.FindUserByID(id)
+func (s *service) sendVerifyEmail(id, email string) error {
+	token, err := s.repository.GenerateVerifyEmailToken(id, email)
+	if err != nil {
+		return err
+	}
+
+	link := token
+	if s.conf.Mail.VerifyURL != "" {
+		link = fmt.Sprintf("%s?token=%s", s.conf.Mail.VerifyURL, url.QueryEscape(token))
+	}
+	body := fmt.Sprintf("Welcome to Tinder App!\n\nConfirm your email address within 24 hours:\n%s\n", link)
+
+	return s.mailer.Send(email, "Verify your email", body)
 }
 
-func (s *service) GetRandomUser(id string) (ResponseRandomUser, error) {
+func (s *service) VerifyEmail(input VerifyEmail) error {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return utils.HandleErrorValidator(err)
+	}
+
+	claims, err := utils.ParseVerifyEmailToken(input.Token, s.keys)
+	if err != nil {
+		return utils.HandleError(400, "invalid verification token")
+	}
 
-	keyRedis := fmt.Sprintf("apptinder:allrandomuser:%s", id)
+	owner, err := s.repository.GetDel(fmt.Sprintf("apptinder:verifyemail:%s", claims.Jti))
+	if err != nil || owner != claims.Sub {
+		return utils.HandleError(400, "verification token already used")
+	}
 
-	// // first, check if data is exists in redis server get data from redis
-	val, _ := s.repository.Get(keyRedis)
+	err = s.repository.SetEmailVerified(claims.Sub, time.Now())
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
 
-	strArr := strings.Split(val, ",")
+	return nil
+}
 
-	resUser, err := s.repository.GetRandomUser(append(strArr, id))
+// ResendVerifyEmail answers the same way whether or not the email belongs to
+// an unverified account so it can not be used to look up accounts.
+func (s *service) ResendVerifyEmail(input ResendVerifyEmail) error {
+	err := s.validate.Struct(&input)
 	if err != nil {
-		return ResponseRandomUser{}, utils.HandleError(500, err.Error())
+		return utils.HandleErrorValidator(err)
 	}
 
-	// if data is not exists in redis server, get data from mongodb
-	now := time.Now()
-	time12am := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 59, time.Local)
+	user, err := s.repository.FindUserByEmail(input.Email)
+	if err != nil || user.EmailVerified {
+		return nil
+	}
 
-	duration := time12am.Sub(now)
+	_ = s.sendVerifyEmail(user.ID, user.Email)
+	return nil
+}
 
-	if val != "" {
-		val = fmt.Sprintf("%s,%s", val, resUser.ID)
-	} else {
-		val = resUser.ID
+func (s *service) GetUserByID(id string) (User, error) {
+	return s.repository.FindUserByID(id)
+}
+
+func (s *service) GetRandomUser(id string) (ResponseRandomUser, error) {
+	// users already swiped or unmatched never come back to the deck
+	swiped, err := s.swipeRepository.GetSwipedUserIDs(id)
+	if err != nil {
+		return ResponseRandomUser{}, utils.HandleError(500, err.Error())
+	}
+
+	unmatched, err := s.matchRepository.GetUnmatchedUserIDs(id)
+	if err != nil {
+		return ResponseRandomUser{}, utils.HandleError(500, err.Error())
 	}
 
-	err = s.repository.Set(keyRedis, val, duration)
+	exclude := append(append(swiped, unmatched...), id)
+	resUser, err := s.repository.GetRandomUser(exclude)
 	if err != nil {
 		return ResponseRandomUser{}, utils.HandleError(500, err.Error())
 	}
@@ -146,85 +803,670 @@ func (s *service) GetRandomUser(id string) (ResponseRandomUser, error) {
 	return resUser, nil
 }
 
-func (s *service) SwipeUser(id string, input SwipeUser) error {
+func (s *service) SwipeUser(id string, input SwipeUser) (ResponseSwipe, error) {
 	err := s.validate.Struct(&input)
 	if err != nil {
-		return utils.HandleErrorValidator(err)
+		return ResponseSwipe{}, utils.HandleErrorValidator(err)
+	}
+
+	if input.IDSwipe == id {
+		return ResponseSwipe{}, utils.HandleError(400, "cannot swipe yourself")
 	}
 
-	keyRedis := fmt.Sprintf("apptinder:allrandomid:%s", id)
+	unlimited, err := s.entitlements.Has(id, entitlement.FeatureUnlimitedSwipes)
+	if err != nil {
+		return ResponseSwipe{}, err
+	}
 
-	// // first, check if data is exists in redis server get data from redis
-	val, _ := s.repository.Get(keyRedis)
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	untilMidnight := startOfDay.AddDate(0, 0, 1).Sub(now)
+	keyRedis := fmt.Sprintf("apptinder:swipecount:%s", id)
 
-	res, err := s.repository.GetMe(id)
+	// the swipe is counted before it is stored, so two concurrent swipes can
+	// not both pass the limit
+	count, err := s.countSwipe(keyRedis, id, startOfDay, untilMidnight)
 	if err != nil {
-		return err
+		return ResponseSwipe{}, utils.HandleError(500, err.Error())
 	}
 
-	var premium bool
-	for _, v := range res.Packages {
-		if v.PackageName == "premium" {
-			premium = true
-		}
+	if !unlimited && count > dailySwipeLimit {
+		s.uncountSwipe(keyRedis, untilMidnight)
+		return ResponseSwipe{}, utils.HandleError(400, "please purchase premium packages that unlocks one premium feature")
 	}
 
-	var strArr []string
+	err = s.swipeRepository.CreateSwipe(Swipe{
+		SwiperID:  id,
+		TargetID:  input.IDSwipe,
+		Direction: input.Swipe,
+		CreatedAt: now,
+	})
+	if err != nil {
+		s.uncountSwipe(keyRedis, untilMidnight)
+		return ResponseSwipe{}, err
+	}
 
-	if val != "" {
-		strArr = strings.Split(val, ",")
-		if !premium {
-			if len(strArr) >= 10 {
-				return utils.HandleError(400, "please purchase premium packages that unlocks one premium feature")
-			}
-		}
-		if utils.CheckArray(strArr, input.IDSwipe) {
-			return utils.HandleError(400, "already swipe")
-		}
-	} else {
-		strArr = append(strArr, id)
+	if input.Swipe != "like" {
+		return ResponseSwipe{}, nil
 	}
 
-	if len(strArr) > 1 {
-		val = fmt.Sprintf("%s,%s", val, input.IDSwipe)
-	} else {
-		val = fmt.Sprintf("%s,%s", id, input.IDSwipe)
+	// a like back on someone who already liked us is a match
+	liked, err := s.swipeRepository.IsLiked(input.IDSwipe, id)
+	if err != nil {
+		return ResponseSwipe{}, utils.HandleError(500, err.Error())
+	}
+	if !liked {
+		return ResponseSwipe{}, nil
 	}
 
-	// if data is not exists in redis server, get data from mongodb
-	err = s.repository.Set(keyRedis, val, 24*time.Hour)
+	match, err := s.matchRepository.CreateMatch(Match{
+		UserIDs:   []string{id, input.IDSwipe},
+		Status:    MatchStatusActive,
+		CreatedAt: now,
+	})
 	if err != nil {
-		return utils.HandleError(500, err.Error())
+		return ResponseSwipe{}, utils.HandleError(500, err.Error())
 	}
 
-	return nil
+	return ResponseSwipe{
+		Match:   true,
+		MatchID: match.ID,
+	}, nil
 }
 
-func (s *service) PurchasePackage(id, packages string) error {
-	res, err := s.repository.GetMe(id)
+// countSwipe adds a swipe to today's counter in redis and returns the new
+// count. A counter started afresh, because the day began or redis lost it, is
+// brought up to the swipes of today in the swipe ledger.
+func (s *service) countSwipe(key, id string, since time.Time, expiration time.Duration) (int64, error) {
+	count, err := s.repository.Incr(key, expiration)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	if slices.Contains(res.Package, packages) {
-		return utils.HandleError(400, "already purchase package")
+	if count > 1 {
+		return count, nil
 	}
 
-	_, err = s.repository.GetPackageByID(packages)
+	prior, err := s.swipeRepository.CountSwipeSince(id, since)
 	if err != nil {
-		return utils.HandleError(400, "package not found")
+		s.uncountSwipe(key, expiration)
+		return 0, err
+	}
+	if prior == 0 {
+		return count, nil
 	}
 
-	res.Package = append(res.Package, packages)
+	return s.repository.IncrBy(key, prior, expiration)
+}
 
-	err = s.repository.UpdatePackageUser(id, res.Package)
+// uncountSwipe takes back a swipe counted by countSwipe that was not stored.
+func (s *service) uncountSwipe(key string, expiration time.Duration) {
+	_, err := s.repository.IncrBy(key, -1, expiration)
 	if err != nil {
-		return utils.HandleError(500, err.Error())
+		fmt.Println(err)
 	}
-	return nil
 }
 
-func (s *service) GetListPackage() ([]Package, error) {
-	return s.repository.GetListPackage()
+// PurchasePackage starts the checkout of the package, it is granted once the
+// payment provider confirms the payment. A free package is granted at once.
+func (s *service) PurchasePackage(id string, input Purchase) (ResponsePurchase, error) {
+	input.Currency = strings.ToUpper(input.Currency)
+	input.PromoCode = strings.ToUpper(strings.TrimSpace(input.PromoCode))
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return ResponsePurchase{}, utils.HandleErrorValidatorParams(err)
+	}
+
+	res, err := s.repository.GetMe(id)
+	if err != nil {
+		return ResponsePurchase{}, err
+	}
+	if slices.Contains(res.Package, input.ID) {
+		return ResponsePurchase{}, utils.HandleError(400, "already purchase package")
+	}
+
+	pack, err := s.repository.GetPackageByID(input.ID)
+	if err != nil || pack.ArchivedAt != nil {
+		return ResponsePurchase{}, utils.HandleError(400, "package not found")
+	}
+
+	var price PackagePrice
+	if localized := localizePackage(pack, PackageQuery{Currency: input.Currency}).Price; localized != nil {
+		price = *localized
+	}
+	// the first price is only the default when no currency is asked for
+	if input.Currency != "" && len(pack.Prices) > 0 && price.Currency != input.Currency {
+		return ResponsePurchase{}, utils.HandleError(400, fmt.Sprintf("package is not sold in %s", input.Currency))
+	}
+
+	now := time.Now()
+	var promo PromoCode
+	var discount int64
+	if input.PromoCode != "" {
+		promo, discount, err = s.applyPromoCode(input.PromoCode, pack, price, now)
+		if err != nil {
+			return ResponsePurchase{}, err
+		}
+	}
+
+	free := price.Amount-discount == 0
+	if !free && s.paymentProvider == nil {
+		return ResponsePurchase{}, utils.HandleError(503, "payments are not available")
+	}
+
+	// every purchase goes to the ledger, free ones included
+	payment, err := s.paymentRepository.CreatePayment(Payment{
+		UserID:    id,
+		PackageID: pack.ID,
+		Package: PackageSnapshot{
+			PackageName:   pack.PackageName,
+			Description:   pack.Description,
+			Features:      pack.Features,
+			BillingPeriod: pack.BillingPeriod,
+		},
+		Status:    PaymentPending,
+		Amount:    price.Amount - discount,
+		Currency:  price.Currency,
+		PromoCode: promo.Code,
+		Discount:  discount,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return ResponsePurchase{}, utils.HandleError(500, err.Error())
+	}
+
+	if promo.ID != "" {
+		err = s.promoRepository.RedeemPromoCode(promo, PromoRedemption{
+			PromoCodeID: promo.ID,
+			UserID:      id,
+			PaymentID:   payment.ID,
+			Discount:    discount,
+			Currency:    price.Currency,
+			Status:      PromoRedeemed,
+			CreatedAt:   now,
+		})
+		if err != nil {
+			_, _ = s.paymentRepository.UpdatePaymentStatus(payment.ID, PaymentPending, PaymentFailed, time.Now())
+			return ResponsePurchase{}, err
+		}
+	}
+
+	if free {
+		return ResponsePurchase{}, s.grantFreePackage(payment, pack)
+	}
+
+	checkout, err := s.paymentProvider.CreateCheckout(payment)
+	if err != nil {
+		err = s.failPayment(payment)
+		if err != nil {
+			return ResponsePurchase{}, utils.HandleError(500, err.Error())
+		}
+		return ResponsePurchase{}, utils.HandleError(502, "payment provider unavailable")
+	}
+	err = s.paymentRepository.SetPaymentReference(payment.ID, checkout.Reference)
+	if err != nil {
+		return ResponsePurchase{}, utils.HandleError(500, err.Error())
+	}
+	payment.Reference = checkout.Reference
+
+	return ResponsePurchase{
+		Payment:     &payment,
+		CheckoutURL: checkout.URL,
+	}, nil
+}
+
+// applyPromoCode checks the promo code can be used on the price of the
+// package and works out the discount. The limits of the code are checked once
+// it is redeemed.
+func (s *service) applyPromoCode(code string, pack Package, price PackagePrice, at time.Time) (PromoCode, int64, error) {
+	promo, err := s.promoRepository.FindPromoCodeByCode(code)
+	if err != nil {
+		return PromoCode{}, 0, utils.HandleError(400, "invalid promo code")
+	}
+	if (promo.StartsAt != nil && at.Before(*promo.StartsAt)) || (promo.EndsAt != nil && !at.Before(*promo.EndsAt)) {
+		return PromoCode{}, 0, utils.HandleError(400, "promo code is not valid at this time")
+	}
+	if len(promo.PackageIDs) > 0 && !slices.Contains(promo.PackageIDs, pack.ID) {
+		return PromoCode{}, 0, utils.HandleError(400, "promo code does not apply to this package")
+	}
+	if price.Amount == 0 {
+		return PromoCode{}, 0, utils.HandleError(400, "promo code does not apply to a free package")
+	}
+
+	var discount int64
+	switch promo.DiscountType {
+	case PromoPercent:
+		discount = price.Amount * int64(promo.PercentOff) / 100
+	case PromoFixed:
+		if promo.Currency != price.Currency {
+			return PromoCode{}, 0, utils.HandleError(400, fmt.Sprintf("promo code only applies to prices in %s", promo.Currency))
+		}
+		discount = promo.AmountOff
+		if discount > price.Amount {
+			discount = price.Amount
+		}
+	}
+	return promo, discount, nil
+}
+
+// failPayment marks a pending payment failed and gives its promo code
+// redemption back. Releasing is idempotent, so a failed payment whose release
+// did not go through is released again when the webhook is retried.
+func (s *service) failPayment(payment Payment) error {
+	failed, err := s.paymentRepository.UpdatePaymentStatus(payment.ID, PaymentPending, PaymentFailed, time.Now())
+	if err != nil {
+		return err
+	}
+	if !failed && payment.Status != PaymentFailed {
+		return nil
+	}
+	if payment.PromoCode == "" {
+		return nil
+	}
+
+	return s.promoRepository.ReleasePromoRedemption(payment.ID)
+}
+
+// grantFreePackage grants the package right away and settles the payment
+// recorded for it.
+func (s *service) grantFreePackage(payment Payment, pack Package) error {
+	grantErr := s.grantPackage(payment.UserID, pack, payment.ID)
+	if grantErr != nil {
+		err := s.failPayment(payment)
+		if err != nil {
+			return utils.HandleError(500, err.Error())
+		}
+		return grantErr
+	}
+
+	_, err := s.paymentRepository.UpdatePaymentStatus(payment.ID, PaymentPending, PaymentPaid, time.Now())
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	s.confirmPromoRedemption(payment)
+	return nil
+}
+
+// grantPackage creates the entitlement of a purchase. The repository refuses
+// a second active entitlement to the package with a 400, which is how the
+// loser of two concurrent purchases finds out.
+func (s *service) grantPackage(userID string, pack Package, paymentID string) error {
+	now := time.Now()
+	// a lapsed entitlement the expiry job did not reach yet would block the
+	// renewal
+	expired, err := s.entitlementRepository.ExpireLapsedEntitlements(userID, pack.ID, now)
+	s.auditExpiredEntitlements(expired, now)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+
+	_, err = s.entitlementRepository.CreateEntitlement(Entitlement{
+		UserID:    userID,
+		PackageID: pack.ID,
+		PaymentID: paymentID,
+		Status:    EntitlementActive,
+		StartsAt:  now,
+		EndsAt:    entitlementEnd(pack, now),
+	})
+	return err
+}
+
+// HandlePaymentWebhook applies the outcome of a payment sent by the payment
+// provider. Providers deliver a webhook at least once, a repeated one changes
+// nothing, an error makes the provider retry.
+func (s *service) HandlePaymentWebhook(payload []byte, signature string) error {
+	if s.paymentProvider == nil {
+		return utils.HandleError(404, "payments are not available")
+	}
+
+	event, err := s.paymentProvider.VerifyWebhook(payload, signature)
+	if err != nil {
+		return utils.HandleError(400, "invalid webhook")
+	}
+
+	payment, err := s.paymentRepository.FindPaymentByReference(event.Reference)
+	if err != nil {
+		return err
+	}
+
+	switch event.Status {
+	case PaymentPaid:
+		return s.completePayment(payment, event)
+	case PaymentFailed:
+		err = s.failPayment(payment)
+		if err != nil {
+			return utils.HandleError(500, err.Error())
+		}
+	case PaymentRefunded:
+		return s.refundPayment(payment)
+	}
+
+	return nil
+}
+
+// completePayment grants the package of a pending payment that was paid in
+// full.
+func (s *service) completePayment(payment Payment, event PaymentEvent) error {
+	if event.Amount != payment.Amount || !strings.EqualFold(event.Currency, payment.Currency) {
+		return utils.HandleError(400, "paid amount does not match the payment")
+	}
+
+	// only the delivery that moves the payment to paid grants the package
+	paid, err := s.paymentRepository.UpdatePaymentStatus(payment.ID, PaymentPending, PaymentPaid, time.Now())
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	if !paid {
+		return s.refundExpiredPayment(payment)
+	}
+
+	pack, err := s.repository.GetPackageByID(payment.PackageID)
+	if err == nil {
+		err = s.grantPackage(payment.UserID, pack, payment.ID)
+	}
+	if utils.GetStatusCode(err) == 400 {
+		// paid twice for the package through two checkouts, the later payment
+		// goes back to the user
+		err = s.paymentProvider.Refund(payment.Reference, payment.Amount, payment.Currency)
+		if err == nil {
+			return s.refundPayment(payment)
+		}
+	}
+	if err != nil {
+		// back to pending so the retry of the provider grants it
+		_, _ = s.paymentRepository.UpdatePaymentStatus(payment.ID, PaymentPaid, PaymentPending, time.Now())
+		return utils.HandleError(500, err.Error())
+	}
+	s.confirmPromoRedemption(payment)
+	return nil
+}
+
+// refundExpiredPayment gives the money back for a checkout paid after it
+// expired, its promo code redemption was already released. Other payments
+// were settled by an earlier delivery and are left alone.
+func (s *service) refundExpiredPayment(payment Payment) error {
+	refunded, err := s.paymentRepository.UpdatePaymentStatus(payment.ID, PaymentExpired, PaymentRefunded, time.Now())
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	if !refunded {
+		return nil
+	}
+
+	err = s.paymentProvider.Refund(payment.Reference, payment.Amount, payment.Currency)
+	if err != nil {
+		// back to expired so the retry of the provider refunds it
+		_, _ = s.paymentRepository.UpdatePaymentStatus(payment.ID, PaymentRefunded, PaymentExpired, time.Now())
+		return utils.HandleError(502, "payment provider unavailable")
+	}
+	return nil
+}
+
+// confirmPromoRedemption turns the reserved redemption of a paid payment into
+// a paid one. It only tells the usage report apart, a confirmation that does
+// not go through is made at the next start of the promo repository.
+func (s *service) confirmPromoRedemption(payment Payment) {
+	if payment.PromoCode == "" {
+		return
+	}
+	err := s.promoRepository.ConfirmPromoRedemption(payment.ID)
+	if err != nil {
+		fmt.Println("Error confirming promo code redemption: ", err)
+	}
+}
+
+// RefundPayment refunds a paid payment at the payment provider and takes the
+// package it granted back.
+func (s *service) RefundPayment(id string) error {
+	payment, err := s.paymentRepository.FindPaymentByID(id)
+	if err != nil {
+		return err
+	}
+	if payment.Status != PaymentPaid {
+		return utils.HandleError(400, "only a paid payment can be refunded")
+	}
+	// a free package was never charged, refunding it only takes it back
+	if payment.Amount == 0 {
+		return s.refundPayment(payment)
+	}
+	if s.paymentProvider == nil {
+		return utils.HandleError(503, "payments are not available")
+	}
+
+	err = s.paymentProvider.Refund(payment.Reference, payment.Amount, payment.Currency)
+	if err != nil {
+		return utils.HandleError(502, "payment provider unavailable")
+	}
+	return s.refundPayment(payment)
+}
+
+func (s *service) refundPayment(payment Payment) error {
+	now := time.Now()
+	refunded, err := s.paymentRepository.UpdatePaymentStatus(payment.ID, PaymentPaid, PaymentRefunded, now)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	if !refunded {
+		return nil
+	}
+
+	err = s.entitlementRepository.RevokeEntitlement(payment.ID, now)
+	if err != nil {
+		_, _ = s.paymentRepository.UpdatePaymentStatus(payment.ID, PaymentRefunded, PaymentPaid, time.Now())
+		return utils.HandleError(500, err.Error())
+	}
+	return nil
+}
+
+// GetPurchaseHistory lists the purchases of the user from the payment ledger,
+// purchases made before the ledger existed are not in it.
+func (s *service) GetPurchaseHistory(id string, page Pagination) (ResponseListPurchase, error) {
+	if page.Page < 1 {
+		page.Page = 1
+	}
+	if page.Limit < 1 {
+		page.Limit = defaultPageLimit
+	}
+	if page.Limit > maxPageLimit {
+		page.Limit = maxPageLimit
+	}
+
+	purchases, total, err := s.paymentRepository.GetPaymentsByUser(id, page)
+	if err != nil {
+		return ResponseListPurchase{}, utils.HandleError(500, err.Error())
+	}
+
+	return ResponseListPurchase{
+		Purchases:  purchases,
+		Pagination: page,
+		Total:      total,
+	}, nil
+}
+
+// GetInvoice renders the html receipt of a purchase of the user.
+func (s *service) GetInvoice(id, paymentID string) ([]byte, error) {
+	payment, err := s.paymentRepository.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.UserID != id {
+		return nil, utils.HandleError(404, "payment not found")
+	}
+	if payment.Status != PaymentPaid && payment.Status != PaymentRefunded {
+		return nil, utils.HandleError(400, "only a paid purchase has an invoice")
+	}
+
+	user, err := s.repository.GetMe(id)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice, err := renderInvoice(user, payment)
+	if err != nil {
+		return nil, utils.HandleError(500, err.Error())
+	}
+	return invoice, nil
+}
+
+func (s *service) CreatePromoCode(input PromoCodeInput) (PromoCode, error) {
+	input.Code = strings.ToUpper(input.Code)
+	input.Currency = strings.ToUpper(input.Currency)
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return PromoCode{}, utils.HandleErrorValidatorParams(err)
+	}
+	if input.StartsAt != nil && input.EndsAt != nil && !input.EndsAt.After(*input.StartsAt) {
+		return PromoCode{}, utils.HandleError(400, "ends_at must be after starts_at")
+	}
+	if input.DiscountType == PromoPercent {
+		input.AmountOff, input.Currency = 0, ""
+	} else {
+		input.PercentOff = 0
+	}
+	for _, packageID := range input.PackageIDs {
+		_, err = s.repository.GetPackageByID(packageID)
+		if err != nil {
+			return PromoCode{}, utils.HandleError(400, fmt.Sprintf("package %s not found", packageID))
+		}
+	}
+
+	return s.promoRepository.CreatePromoCode(PromoCode{
+		Code:           input.Code,
+		DiscountType:   input.DiscountType,
+		PercentOff:     input.PercentOff,
+		AmountOff:      input.AmountOff,
+		Currency:       input.Currency,
+		StartsAt:       input.StartsAt,
+		EndsAt:         input.EndsAt,
+		MaxRedemptions: input.MaxRedemptions,
+		MaxPerUser:     input.MaxPerUser,
+		PackageIDs:     input.PackageIDs,
+		CreatedAt:      time.Now(),
+	})
+}
+
+func (s *service) GetPromoCodes() ([]PromoCode, error) {
+	res, err := s.promoRepository.GetPromoCodes()
+	if err != nil {
+		return nil, utils.HandleError(500, err.Error())
+	}
+	return res, nil
+}
+
+func (s *service) GetPromoCodeUsage(id string) (PromoCodeUsage, error) {
+	return s.promoRepository.GetPromoCodeUsage(id)
+}
+
+// entitlementEnd is when a purchase made at start runs out, nil for a one-off
+// purchase which is kept for good. The billing period starts once the trial
+// days of the package are over.
+func entitlementEnd(pack Package, start time.Time) *time.Time {
+	billed := start.AddDate(0, 0, pack.TrialDays)
+	var end time.Time
+	switch pack.BillingPeriod {
+	case BillingMonthly:
+		end = billed.AddDate(0, 1, 0)
+	case BillingYearly:
+		end = billed.AddDate(1, 0, 0)
+	default:
+		return nil
+	}
+	return &end
+}
+
+// ExpireEntitlements marks the entitlements that ran out as expired and
+// records an event for each. It is safe to run on several instances at once.
+func (s *service) ExpireEntitlements() (int, error) {
+	now := time.Now()
+	// the entitlements expired before an error still get their event
+	expired, err := s.entitlementRepository.ExpireEntitlements(now, entitlementExpiryBatch)
+	s.auditExpiredEntitlements(expired, now)
+	return len(expired), err
+}
+
+// ExpirePendingPayments expires the checkouts left unpaid for
+// pendingPaymentTTL and gives their promo code redemptions back. A payment
+// whose release fails goes back to pending for the next run.
+func (s *service) ExpirePendingPayments() (int, error) {
+	now := time.Now()
+	expired, err := s.paymentRepository.ExpirePendingPayments(now.Add(-pendingPaymentTTL), now, paymentExpiryBatch)
+	for _, payment := range expired {
+		if payment.PromoCode == "" {
+			continue
+		}
+		releaseErr := s.promoRepository.ReleasePromoRedemption(payment.ID)
+		if releaseErr != nil {
+			_, _ = s.paymentRepository.UpdatePaymentStatus(payment.ID, PaymentExpired, PaymentPending, time.Now())
+			err = releaseErr
+		}
+	}
+	return len(expired), err
+}
+
+func (s *service) auditExpiredEntitlements(expired []Entitlement, at time.Time) {
+	for _, entitlement := range expired {
+		_ = s.auditRepository.CreateAuditEvent(AuditEvent{
+			Type:      AuditEntitlementExpired,
+			UserID:    entitlement.UserID,
+			Detail:    fmt.Sprintf("package %s expired", entitlement.PackageID),
+			CreatedAt: at,
+		})
+	}
+}
+
+// GetListPackage lists the packages on sale priced in the currency and named
+// in the language asked for.
+func (s *service) GetListPackage(query PackageQuery) ([]Package, error) {
+	query.Currency = strings.ToUpper(query.Currency)
+	err := s.validate.Struct(&query)
+	if err != nil {
+		return nil, utils.HandleErrorValidatorParams(err)
+	}
+
+	packages, err := s.repository.GetListPackage()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range packages {
+		packages[i] = localizePackage(packages[i], query)
+	}
+	return packages, nil
+}
+
+// localizePackage resolves the price and display name of the package from its
+// tables, which are left out of the result.
+func localizePackage(pack Package, query PackageQuery) Package {
+	if pack.BillingPeriod == "" {
+		// packages from before billing periods were granted for good
+		pack.BillingPeriod = BillingOneOff
+	}
+
+	for i, price := range pack.Prices {
+		if i == 0 || price.Currency == query.Currency {
+			pack.Price = &pack.Prices[i]
+		}
+		if price.Currency == query.Currency {
+			break
+		}
+	}
+
+	pack.DisplayName = pack.PackageName
+	language := strings.ToLower(query.Language)
+	base, _, _ := strings.Cut(language, "-")
+	for tag, name := range pack.DisplayNames {
+		tag = strings.ToLower(tag)
+		if tag == language {
+			pack.DisplayName = name
+			break
+		}
+		if tag == base {
+			pack.DisplayName = name
+		}
+	}
+
+	pack.Prices = nil
+	pack.DisplayNames = nil
+	return pack
 }
 
 func (s *service) GetMe(id string) (User, error) {
@@ -234,3 +1476,576 @@ func (s *service) GetMe(id string) (User, error) {
 func (s *service) GetPackageByID(id string) (Package, error) {
 	return s.repository.GetPackageByID(id)
 }
+
+// GetAllPackages lists every package for the admin, archived ones included.
+func (s *service) GetAllPackages() ([]Package, error) {
+	return s.repository.GetAllPackages()
+}
+
+func (s *service) CreatePackage(input PackageInput) (Package, error) {
+	err := s.validatePackageInput(&input)
+	if err != nil {
+		return Package{}, err
+	}
+
+	pack, err := s.repository.CreatePackage(input)
+	if err != nil {
+		return Package{}, utils.HandleError(500, err.Error())
+	}
+	return pack, nil
+}
+
+func (s *service) UpdatePackage(id string, input PackageInput) (Package, error) {
+	err := s.validatePackageInput(&input)
+	if err != nil {
+		return Package{}, err
+	}
+
+	err = s.repository.UpdatePackage(id, input)
+	if err != nil {
+		return Package{}, err
+	}
+	return s.repository.GetPackageByID(id)
+}
+
+// normalizePackageInput uppercases the currency codes, the price table is
+// looked up by exact code.
+func normalizePackageInput(input *PackageInput) {
+	for i := range input.Prices {
+		input.Prices[i].Currency = strings.ToUpper(input.Prices[i].Currency)
+	}
+}
+
+// validatePackageInput checks the input, a package can only grant features
+// the business logic knows.
+func (s *service) validatePackageInput(input *PackageInput) error {
+	normalizePackageInput(input)
+	err := s.validate.Struct(input)
+	if err != nil {
+		return utils.HandleErrorValidatorParams(err)
+	}
+	for _, feature := range input.Features {
+		if !entitlement.IsFeature(feature) {
+			return utils.HandleError(400, fmt.Sprintf("unknown feature %s", feature))
+		}
+	}
+	return nil
+}
+
+// ArchivePackage takes the package off the list and out of sale, users who
+// purchased it keep it.
+func (s *service) ArchivePackage(id string) error {
+	return s.repository.ArchivePackage(id, time.Now())
+}
+
+func (s *service) ReorderPackages(input ReorderPackages) error {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return utils.HandleErrorValidatorParams(err)
+	}
+
+	return s.repository.ReorderPackages(input.IDs)
+}
+
+func (s *service) GetMatches(id string, page Pagination) (ResponseListMatch, error) {
+	if page.Page < 1 {
+		page.Page = 1
+	}
+	if page.Limit < 1 {
+		page.Limit = defaultPageLimit
+	}
+	if page.Limit > maxPageLimit {
+		page.Limit = maxPageLimit
+	}
+
+	matches, total, err := s.matchRepository.GetMatches(id, page)
+	if err != nil {
+		return ResponseListMatch{}, utils.HandleError(500, err.Error())
+	}
+
+	return ResponseListMatch{
+		Matches:    matches,
+		Pagination: page,
+		Total:      total,
+	}, nil
+}
+
+func (s *service) Unmatch(id, matchID string) error {
+	match, err := s.matchRepository.FindMatchByID(matchID)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(match.UserIDs, id) {
+		return utils.HandleError(404, "match not found")
+	}
+
+	if match.Status != MatchStatusActive {
+		return utils.HandleError(400, "already unmatched")
+	}
+
+	err = s.matchRepository.Unmatch(match.ID, id, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RefreshToken rotates a refresh token. Every refresh token can be used once,
+// presenting one that was already rotated revokes the whole family because
+// the token has most likely been stolen.
+func (s *service) RefreshToken(input RefreshToken) (*utils.Token, error) {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return nil, utils.HandleErrorValidator(err)
+	}
+
+	claims, err := utils.ParseRefreshToken(input.RefreshToken, s.keys)
+	if err != nil {
+		return nil, utils.HandleError(401, "invalid refresh token")
+	}
+
+	keyFamily := fmt.Sprintf("apptinder:refreshfamily:%s", claims.Family)
+	revoked, _ := s.repository.Get(keyFamily)
+	if revoked != "" {
+		return nil, utils.HandleError(401, "invalid refresh token")
+	}
+
+	version, err := s.repository.GetTokenVersion(claims.Sub)
+	if err != nil {
+		return nil, utils.HandleError(500, err.Error())
+	}
+	if claims.Ver < version {
+		return nil, utils.HandleError(401, "invalid refresh token")
+	}
+
+	owner, err := s.repository.GetDel(fmt.Sprintf("apptinder:refresh:%s", claims.Jti))
+	if err != nil || owner != claims.Sub {
+		_ = s.repository.Set(keyFamily, "revoked", utils.RefreshTokenExpired*time.Second)
+		return nil, utils.HandleError(401, "refresh token reuse detected")
+	}
+
+	token, err := s.repository.GenerateTokenFamily(claims.Sub, claims.Email, claims.Family, claims.Roles)
+	if err != nil {
+		return nil, utils.HandleError(500, err.Error())
+	}
+	// last seen is informational, a failed update must not fail the refresh
+	_ = s.sessionRepository.TouchSession(claims.Family, time.Now())
+
+	return token, nil
+}
+
+// ValidateAccessToken rejects an access token that was logged out on its own,
+// belongs to a revoked session or was minted before the user logged out of
+// every session.
+func (s *service) ValidateAccessToken(claims utils.JwtTokenClaimsUser) error {
+	if claims.Jti != "" {
+		denied, _ := s.repository.Get(fmt.Sprintf("apptinder:denylist:%s", claims.Jti))
+		if denied != "" {
+			return utils.HandleError(401, "token revoked")
+		}
+	}
+
+	if claims.Family != "" {
+		revoked, _ := s.repository.Get(fmt.Sprintf("apptinder:refreshfamily:%s", claims.Family))
+		if revoked != "" {
+			return utils.HandleError(401, "session revoked")
+		}
+	}
+
+	version, err := s.repository.GetTokenVersion(claims.Sub)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	if claims.Ver < version {
+		return utils.HandleError(401, "token revoked")
+	}
+
+	return nil
+}
+
+func (s *service) Logout(claims utils.JwtTokenClaimsUser) error {
+	// the denylist entry only has to live as long as the token itself
+	remaining := time.Until(time.Unix(claims.Exp, 0))
+	if claims.Jti != "" && remaining > 0 {
+		err := s.repository.Set(fmt.Sprintf("apptinder:denylist:%s", claims.Jti), "revoked", remaining)
+		if err != nil {
+			return utils.HandleError(500, err.Error())
+		}
+	}
+
+	if claims.Family != "" {
+		err := s.repository.Set(fmt.Sprintf("apptinder:refreshfamily:%s", claims.Family), "revoked", utils.RefreshTokenExpired*time.Second)
+		if err != nil {
+			return utils.HandleError(500, err.Error())
+		}
+		// tokens issued before sessions existed have no session document
+		_ = s.sessionRepository.RevokeSession(claims.Family, claims.Sub, time.Now())
+	}
+
+	return nil
+}
+
+func (s *service) LogoutAll(id string) error {
+	err := s.repository.IncrTokenVersion(id)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+
+	err = s.sessionRepository.RevokeSessions(id, time.Now())
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	return nil
+}
+
+// GetSessions lists the active sessions of the user, current is the session
+// of the token making the request.
+func (s *service) GetSessions(id, current string) ([]Session, error) {
+	sessions, err := s.sessionRepository.GetSessions(id, time.Now())
+	if err != nil {
+		return nil, utils.HandleError(500, err.Error())
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs a device out. The refresh family is revoked so the
+// session can not be refreshed and its access tokens are rejected right away.
+func (s *service) RevokeSession(id, sessionID string) error {
+	err := s.sessionRepository.RevokeSession(sessionID, id, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.Set(fmt.Sprintf("apptinder:refreshfamily:%s", sessionID), "revoked", utils.RefreshTokenExpired*time.Second)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+
+	return nil
+}
+
+// ForgotPassword mails a reset code. It never tells whether the email is
+// registered, a limited number of codes is sent per hour.
+func (s *service) ForgotPassword(input ForgotPassword) error {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return utils.HandleErrorValidator(err)
+	}
+
+	user, err := s.repository.FindUserByEmail(input.Email)
+	if err != nil {
+		return nil
+	}
+
+	requests, err := s.repository.Incr(fmt.Sprintf("apptinder:resetrequests:%s", user.ID), time.Hour)
+	if err != nil || requests > resetCodeMaxRequests {
+		return nil
+	}
+
+	code, err := utils.GenerateCode(resetCodeLength)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	err = s.repository.Set(fmt.Sprintf("apptinder:resetcode:%s", user.ID), utils.HashCode(code), resetCodeExpired)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+
+	body := fmt.Sprintf("Your password reset code is %s\n\nIt expires in %d minutes. If you did not ask for it you can ignore this email.\n", code, int(resetCodeExpired.Minutes()))
+	_ = s.mailer.Send(user.Email, "Reset your password", body)
+	return nil
+}
+
+// ResetPassword sets a new password with a code from ForgotPassword. A code
+// works once and only a few wrong guesses are allowed while it lives, every
+// session of the user is signed out afterwards.
+func (s *service) ResetPassword(input ResetPassword) error {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return utils.HandleErrorValidator(err)
+	}
+
+	invalid := utils.HandleError(400, "invalid or expired reset code")
+
+	user, err := s.repository.FindUserByEmail(input.Email)
+	if err != nil {
+		return invalid
+	}
+
+	keyCode := fmt.Sprintf("apptinder:resetcode:%s", user.ID)
+	attempts, err := s.repository.Incr(fmt.Sprintf("apptinder:resetattempts:%s", user.ID), resetCodeExpired)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	if attempts > resetCodeMaxAttempts {
+		_ = s.repository.Del(keyCode)
+		return invalid
+	}
+
+	hashed, err := s.repository.Get(keyCode)
+	if err != nil || !utils.VerifyCode(hashed, input.Code) {
+		return invalid
+	}
+	// GetDel makes sure two requests racing with the same code can not both win
+	hashed, err = s.repository.GetDel(keyCode)
+	if err != nil || !utils.VerifyCode(hashed, input.Code) {
+		return invalid
+	}
+
+	passwd, err := utils.Hash(input.Password)
+	if err != nil {
+		return utils.HandleError(500, "failed to hash password")
+	}
+	err = s.repository.UpdatePassword(user.ID, string(passwd))
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+
+	_ = s.repository.Del(fmt.Sprintf("apptinder:resetattempts:%s", user.ID))
+	return s.LogoutAll(user.ID)
+}
+
+// oauthState is kept in redis between StartOAuth and OAuthCallback, the code
+// verifier never leaves the server.
+type oauthState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+// StartOAuth returns the url of the identity provider to send the user to.
+func (s *service) StartOAuth(provider string) (ResponseOAuthStart, error) {
+	idp, ok := s.providers[provider]
+	if !ok {
+		return ResponseOAuthStart{}, utils.HandleError(404, "identity provider not found")
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return ResponseOAuthStart{}, utils.HandleError(500, err.Error())
+	}
+	verifier, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return ResponseOAuthStart{}, utils.HandleError(500, err.Error())
+	}
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return ResponseOAuthStart{}, utils.HandleError(500, err.Error())
+	}
+
+	authURL, err := idp.AuthCodeURL(state, utils.PKCEChallenge(verifier), nonce)
+	if err != nil {
+		return ResponseOAuthStart{}, utils.HandleError(502, err.Error())
+	}
+
+	pending, err := json.Marshal(oauthState{Provider: provider, Verifier: verifier, Nonce: nonce})
+	if err != nil {
+		return ResponseOAuthStart{}, utils.HandleError(500, err.Error())
+	}
+	err = s.repository.Set(fmt.Sprintf("apptinder:oauthstate:%s", state), string(pending), oauthStateExpired)
+	if err != nil {
+		return ResponseOAuthStart{}, utils.HandleError(500, err.Error())
+	}
+
+	return ResponseOAuthStart{AuthorizationURL: authURL, State: state}, nil
+}
+
+// OAuthCallback signs in the user the identity provider vouches for. The state
+// works once and must come from StartOAuth for the same provider.
+func (s *service) OAuthCallback(provider string, input OAuthCallback) (*ResponseLogin, error) {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return nil, utils.HandleErrorValidator(err)
+	}
+
+	idp, ok := s.providers[provider]
+	if !ok {
+		return nil, utils.HandleError(404, "identity provider not found")
+	}
+
+	raw, err := s.repository.GetDel(fmt.Sprintf("apptinder:oauthstate:%s", input.State))
+	if err != nil {
+		return nil, utils.HandleError(400, "invalid or expired oauth state")
+	}
+	var pending oauthState
+	err = json.Unmarshal([]byte(raw), &pending)
+	if err != nil || pending.Provider != provider {
+		return nil, utils.HandleError(400, "invalid or expired oauth state")
+	}
+
+	identity, err := idp.Exchange(input.Code, pending.Verifier)
+	if err != nil || identity.Subject == "" || identity.Nonce != pending.Nonce {
+		return nil, utils.HandleError(401, "identity provider rejected the login")
+	}
+	identity.Provider = provider
+
+	user, err := s.oauthUser(identity, input.IP)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := newLoginLimits(AuthLogin{Email: user.Email, IP: input.IP})
+	return s.completeLogin(user, limits, input.Device, input.IP, input.UserAgent)
+}
+
+// oauthUser finds the user linked to the identity. An identity seen for the
+// first time is linked to the account with its email, both sides must have
+// verified the email so nobody can claim an account by registering its email
+// first. Without such account a new one is created.
+func (s *service) oauthUser(identity Identity, ip string) (User, error) {
+	user, err := s.repository.FindUserByIdentity(identity.Provider, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return User{}, utils.HandleError(403, "the identity provider did not verify the email address")
+	}
+
+	user, err = s.repository.FindUserByEmail(identity.Email)
+	if err == nil {
+		if !user.EmailVerified {
+			return User{}, utils.HandleError(409, "verify the email of your account before signing in with this provider")
+		}
+		err = s.repository.LinkIdentity(user.ID, identity, time.Now())
+		if err != nil {
+			return User{}, utils.HandleError(500, err.Error())
+		}
+		_ = s.auditRepository.CreateAuditEvent(AuditEvent{
+			Type:      AuditIdentityLinked,
+			UserID:    user.ID,
+			Email:     user.Email,
+			IP:        ip,
+			Detail:    fmt.Sprintf("%s account linked", identity.Provider),
+			CreatedAt: time.Now(),
+		})
+		return user, nil
+	}
+
+	id, err := s.repository.CreateOAuthUser(identity)
+	if err != nil {
+		return User{}, utils.HandleError(500, err.Error())
+	}
+
+	return User{
+		ID:            id,
+		FullName:      identity.FullName,
+		Email:         identity.Email,
+		PhotoUrl:      identity.PhotoUrl,
+		EmailVerified: true,
+	}, nil
+}
+
+// CompleteProfile sets the fullname and photo of the user, accounts created by
+// a social login are kept out of the deck until it is done.
+func (s *service) CompleteProfile(id string, input CompleteProfile) error {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return utils.HandleErrorValidator(err)
+	}
+	if input.File == nil {
+		return utils.HandleError(400, "please upload foto profile")
+	}
+
+	photoUrl, err := s.repository.UploadImageS3(input.File)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.CompleteProfile(id, input.FullName, photoUrl)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	return nil
+}
+
+// SetRoles replaces the roles of a user. Every session of the user is signed
+// out so no token keeps a role that was taken away.
+func (s *service) SetRoles(actorID, id string, input SetRoles) error {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return utils.HandleErrorValidator(err)
+	}
+	for _, role := range input.Roles {
+		if !utils.IsRole(role) {
+			return utils.HandleError(400, fmt.Sprintf("unknown role %s", role))
+		}
+	}
+	// an admin can not lock themselves out, another admin has to do it
+	if actorID == id && !slices.Contains(input.Roles, utils.RoleAdmin) {
+		return utils.HandleError(400, "can not remove your own admin role")
+	}
+
+	user, err := s.repository.FindUserByID(id)
+	if err != nil {
+		return utils.HandleError(404, "user not found")
+	}
+
+	err = s.repository.SetRoles(user.ID, input.Roles)
+	if err != nil {
+		return utils.HandleError(500, err.Error())
+	}
+	_ = s.auditRepository.CreateAuditEvent(AuditEvent{
+		Type:      AuditRolesChanged,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Detail:    fmt.Sprintf("roles %s set by %s", strings.Join(input.Roles, ","), actorID),
+		CreatedAt: time.Now(),
+	})
+
+	return s.LogoutAll(user.ID)
+}
+
+// BootstrapAdmin creates the first admin, or promotes the user with the email.
+// It refuses once an admin exists, further admins are appointed by an admin.
+func (s *service) BootstrapAdmin(input BootstrapAdmin) (string, error) {
+	err := s.validate.Struct(&input)
+	if err != nil {
+		return "", utils.HandleErrorValidator(err)
+	}
+
+	admins, err := s.repository.CountUsersByRole(utils.RoleAdmin)
+	if err != nil {
+		return "", err
+	}
+	if admins > 0 {
+		return "", utils.HandleError(409, "an admin already exists")
+	}
+
+	roles := []string{utils.RoleUser, utils.RoleAdmin}
+	user, err := s.repository.FindUserByEmail(input.Email)
+	if err == nil {
+		err = s.repository.SetRoles(user.ID, roles)
+		if err != nil {
+			return "", err
+		}
+		// tokens of the user were minted without the admin role
+		return user.ID, s.repository.IncrTokenVersion(user.ID)
+	}
+
+	id, err := s.repository.CreateUser(Register{
+		FullName: input.FullName,
+		Email:    input.Email,
+		Password: input.Password,
+	})
+	if err != nil {
+		return "", err
+	}
+	err = s.repository.SetRoles(id, roles)
+	if err != nil {
+		return "", err
+	}
+	err = s.repository.SetEmailVerified(id, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
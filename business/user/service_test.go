@@ -3,10 +3,21 @@ package user_test
 import (
 	"errors"
 	"mime/multipart"
+	"roby-backend-golang/business/entitlement"
 	businessUser "roby-backend-golang/business/user"
 	"roby-backend-golang/config"
+	repoAudit "roby-backend-golang/repository/audit"
+	repoEntitlement "roby-backend-golang/repository/entitlement"
+	repoIdentity "roby-backend-golang/repository/identity"
+	repoMailer "roby-backend-golang/repository/mailer"
+	repoMatch "roby-backend-golang/repository/match"
+	repoPayment "roby-backend-golang/repository/payment"
+	repoPromo "roby-backend-golang/repository/promo"
+	repoSession "roby-backend-golang/repository/session"
+	repoSwipe "roby-backend-golang/repository/swipe"
 	repoUser "roby-backend-golang/repository/user"
 	"roby-backend-golang/utils"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,12 +25,29 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+type entitlementsMock struct {
+	*mock.Mock
+}
+
+func (m *entitlementsMock) Has(userID, feature string) (bool, error) {
+	args := m.Called(userID, feature)
+	return args.Bool(0), args.Error(1)
+}
+
+func newPaymentProvider(t *testing.T, secret string) *repoPayment.FakeProvider {
+	provider, err := repoPayment.NewFakeProvider(secret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
 func TestLogin(t *testing.T) {
 	asserting := assert.New(t)
 	t.Run("Valid Test", func(t *testing.T) {
 		resSample := businessUser.ResponseLogin{
 			Email: "test@mail.com",
-			Token: utils.Token{
+			Token: &utils.Token{
 				AccessToken:         "123",
 				AccessTokenExpired:  123,
 				RefreshToken:        "123",
@@ -38,9 +66,22 @@ func TestLogin(t *testing.T) {
 		}
 		// mocking
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(1), nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
 		repoMock.On("FindUserByEmail", auth.Email).Return(user, nil)
-		repoMock.On("GenerateTokenAuth", user.ID, user.Email).Return(&resSample.Token, nil)
+		sessionMock.On("CreateSession", mock.AnythingOfType("user.Session")).Return(businessUser.Session{ID: "session"}, nil)
+		repoMock.On("GenerateTokenFamily", user.ID, user.Email, "session", mock.Anything).Return(resSample.Token, nil)
 		// repoMock.On("Login", mock.AnythingOfType("AuthLogin")).Return(resSample, nil)
 
 		res, err := service.Login(auth)
@@ -51,7 +92,7 @@ func TestLogin(t *testing.T) {
 	t.Run("Wrong Email Test", func(t *testing.T) {
 		resSample := businessUser.ResponseLogin{
 			Email: "test@mail.com",
-			Token: utils.Token{
+			Token: &utils.Token{
 				AccessToken:         "123",
 				AccessTokenExpired:  123,
 				RefreshToken:        "123",
@@ -70,9 +111,22 @@ func TestLogin(t *testing.T) {
 		}
 		// mocking
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(1), nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
 		repoMock.On("FindUserByEmail", auth.Email).Return(user, errors.New("wrong email"))
-		repoMock.On("GenerateTokenAuth", user.ID, user.Email).Return(&resSample.Token, nil)
+		sessionMock.On("CreateSession", mock.AnythingOfType("user.Session")).Return(businessUser.Session{ID: "session"}, nil)
+		repoMock.On("GenerateTokenFamily", user.ID, user.Email, "session", mock.Anything).Return(resSample.Token, nil)
 		// repoMock.On("Login", mock.AnythingOfType("AuthLogin")).Return(resSample, nil)
 
 		_, err := service.Login(auth)
@@ -93,9 +147,21 @@ func TestLogin(t *testing.T) {
 		}
 		// mocking
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(1), nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
 		repoMock.On("FindUserByEmail", auth.Email).Return(user, errors.New("wrong email"))
-		repoMock.On("GenerateTokenAuth", user.ID, user.Email).Return(nil, nil)
+		repoMock.On("GenerateTokenFamily", user.ID, user.Email, "session", mock.Anything).Return(nil, nil)
 
 		_, err := service.Login(auth)
 		asserting.Error(err)
@@ -115,9 +181,21 @@ func TestLogin(t *testing.T) {
 		}
 		// mocking
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(1), nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
 		repoMock.On("FindUserByEmail", auth.Email).Return(user, nil)
-		repoMock.On("GenerateTokenAuth", user.ID, user.Email).Return(nil, nil)
+		repoMock.On("GenerateTokenFamily", user.ID, user.Email, "session", mock.Anything).Return(nil, nil)
 
 		_, err := service.Login(auth)
 		asserting.Error(err)
@@ -137,12 +215,232 @@ func TestLogin(t *testing.T) {
 		}
 		// mocking
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(1), nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
+		repoMock.On("FindUserByEmail", auth.Email).Return(user, nil)
+		sessionMock.On("CreateSession", mock.AnythingOfType("user.Session")).Return(businessUser.Session{ID: "session"}, nil)
+		repoMock.On("GenerateTokenFamily", user.ID, user.Email, "session", mock.Anything).Return(&utils.Token{}, errors.New("error generate token"))
+
+		_, err := service.Login(auth)
+		asserting.Error(err)
+	})
+
+	t.Run("Records Session Test", func(t *testing.T) {
+		auth := businessUser.AuthLogin{
+			Email:     "test@mail.com",
+			Password:  "12345678",
+			IP:        "10.0.0.1",
+			UserAgent: "Mozilla/5.0",
+		}
+		user := businessUser.User{
+			ID:       "123",
+			Email:    "test@mail.com",
+			Password: "$2a$10$mfK4MlwOhHnvphtBNp0G0u/E6QjVHBk3ks0C.BnOMnRKI5Ue2J4SW",
+		}
+		// mocking
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:ip:10.0.0.1").Return("", errors.New("redis: nil"))
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(1), nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
+		repoMock.On("FindUserByEmail", auth.Email).Return(user, nil)
+		sessionMock.On("CreateSession", mock.MatchedBy(func(session businessUser.Session) bool {
+			return session.UserID == "123" && session.IP == "10.0.0.1" && session.Device == "Mozilla/5.0"
+		})).Return(businessUser.Session{ID: "session"}, nil)
+		repoMock.On("GenerateTokenFamily", user.ID, user.Email, "session", mock.Anything).Return(&utils.Token{}, nil)
+
+		_, err := service.Login(auth)
+		asserting.NoError(err)
+		sessionMock.AssertExpectations(t)
+	})
+
+	t.Run("Create Session Error Test", func(t *testing.T) {
+		auth := businessUser.AuthLogin{
+			Email:    "test@mail.com",
+			Password: "12345678",
+		}
+		user := businessUser.User{
+			ID:       "123",
+			Email:    "test@mail.com",
+			Password: "$2a$10$mfK4MlwOhHnvphtBNp0G0u/E6QjVHBk3ks0C.BnOMnRKI5Ue2J4SW",
+		}
+		// mocking
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(1), nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
 		repoMock.On("FindUserByEmail", auth.Email).Return(user, nil)
-		repoMock.On("GenerateTokenAuth", user.ID, user.Email).Return(&utils.Token{}, errors.New("error generate token"))
+		sessionMock.On("CreateSession", mock.Anything).Return(businessUser.Session{}, errors.New("error create session"))
 
 		_, err := service.Login(auth)
 		asserting.Error(err)
+		repoMock.AssertNotCalled(t, "GenerateTokenFamily", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Same Error For Unknown Email And Wrong Password Test", func(t *testing.T) {
+		auth := businessUser.AuthLogin{
+			Email:    "test@mail.com",
+			Password: "wrong-password",
+		}
+		user := businessUser.User{
+			ID:       "123",
+			Email:    "test@mail.com",
+			Password: "$2a$10$mfK4MlwOhHnvphtBNp0G0u/E6QjVHBk3ks0C.BnOMnRKI5Ue2J4SW",
+		}
+		var errs []error
+		for _, found := range []bool{true, false} {
+			repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+			swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+			matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+			sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+			mailer := repoMailer.NewMemoryMailer()
+			auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+			entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+			featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+			paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+			promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+			service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+			repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+			repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(1), nil)
+			if found {
+				repoMock.On("FindUserByEmail", auth.Email).Return(user, nil)
+			} else {
+				repoMock.On("FindUserByEmail", auth.Email).Return(businessUser.User{}, errors.New("wrong email"))
+			}
+
+			_, err := service.Login(auth)
+			errs = append(errs, err)
+		}
+		asserting.Equal(401, utils.GetStatusCode(errs[0]))
+		asserting.Equal(errs[0], errs[1])
+	})
+
+	t.Run("Lockout Test", func(t *testing.T) {
+		auth := businessUser.AuthLogin{
+			Email:    "Test@mail.com",
+			Password: "wrong-password",
+			IP:       "10.0.0.1",
+		}
+		// mocking
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Get", "apptinder:loginlock:ip:10.0.0.1").Return("", errors.New("redis: nil"))
+		repoMock.On("FindUserByEmail", auth.Email).Return(businessUser.User{}, errors.New("wrong email"))
+		repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(7), nil)
+		repoMock.On("Incr", "apptinder:loginfail:ip:10.0.0.1", 24*time.Hour).Return(int64(3), nil)
+		repoMock.On("Set", "apptinder:loginlock:account:test@mail.com", "locked", 4*time.Minute).Return(nil)
+		auditMock.On("CreateAuditEvent", mock.MatchedBy(func(event businessUser.AuditEvent) bool {
+			return event.Type == businessUser.AuditLoginLockout && event.IP == "10.0.0.1"
+		})).Return(nil)
+
+		_, err := service.Login(auth)
+		asserting.Equal(401, utils.GetStatusCode(err))
+		// the backoff doubles past the threshold of 5 failures
+		repoMock.AssertCalled(t, "Set", "apptinder:loginlock:account:test@mail.com", "locked", 4*time.Minute)
+		repoMock.AssertNumberOfCalls(t, "Set", 1)
+		auditMock.AssertNumberOfCalls(t, "CreateAuditEvent", 1)
+	})
+
+	t.Run("Locked Test", func(t *testing.T) {
+		auth := businessUser.AuthLogin{
+			Email:    "test@mail.com",
+			Password: "12345678",
+		}
+		// mocking
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("locked", nil)
+
+		_, err := service.Login(auth)
+		asserting.Equal(429, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "FindUserByEmail", mock.Anything)
+	})
+
+	t.Run("MFA Required Test", func(t *testing.T) {
+		auth := businessUser.AuthLogin{
+			Email:    "test@mail.com",
+			Password: "12345678",
+		}
+		user := businessUser.User{
+			ID:         "123",
+			Email:      "test@mail.com",
+			Password:   "$2a$10$mfK4MlwOhHnvphtBNp0G0u/E6QjVHBk3ks0C.BnOMnRKI5Ue2J4SW",
+			MFAEnabled: true,
+		}
+		// mocking
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("FindUserByEmail", auth.Email).Return(user, nil)
+		repoMock.On("GenerateMFAChallenge", "123", "test@mail.com").Return("challenge", nil)
+
+		res, err := service.Login(auth)
+		asserting.NoError(err)
+		asserting.True(res.MFARequired)
+		asserting.Equal("challenge", res.MFAToken)
+		asserting.Nil(res.Token)
+		// failures are only cleared once the code passed as well
+		repoMock.AssertNotCalled(t, "Del", mock.Anything)
+		sessionMock.AssertNotCalled(t, "CreateSession", mock.Anything)
 	})
 
 }
@@ -168,14 +466,27 @@ func TestRegisterUser(t *testing.T) {
 		}
 
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
 		repoMock.On("FindUserByEmail", inputUser.Email).Return(result, errors.New("email not found"))
 		repoMock.On("UploadImageS3", mock.Anything).Return("url", nil)
-		repoMock.On("CreateUser", mock.Anything).Return(nil)
+		repoMock.On("CreateUser", mock.Anything).Return("123", nil)
+		repoMock.On("GenerateVerifyEmailToken", "123", "test@mail.com").Return("verify-token", nil)
 
 		err := service.RegisterUser(inputUser)
 		asserting.Nil(err)
-
+		messages := mailer.Messages()
+		asserting.Len(messages, 1)
+		asserting.Equal("test@mail.com", messages[0].To)
+		asserting.Contains(messages[0].Body, "verify-token")
 	})
 	t.Run("Wrong Validation Test", func(t *testing.T) {
 		asserting := assert.New(t)
@@ -192,10 +503,19 @@ func TestRegisterUser(t *testing.T) {
 		}
 
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
 		repoMock.On("FindUserByEmail", inputUser.Email).Return(businessUser.User{}, errors.New("email already exist"))
 		repoMock.On("UploadImageS3", &multipart).Return("url", nil)
-		repoMock.On("CreateUser", mock.Anything).Return(nil)
+		repoMock.On("CreateUser", mock.Anything).Return("123", nil)
 
 		err := service.RegisterUser(inputUser)
 		asserting.Error(err)
@@ -217,10 +537,19 @@ func TestRegisterUser(t *testing.T) {
 		}
 
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
 		repoMock.On("FindUserByEmail", inputUser.Email).Return(businessUser.User{}, errors.New("email already exist"))
 		repoMock.On("UploadImageS3", &multipart).Return("", errors.New("error upload image"))
-		repoMock.On("CreateUser", mock.Anything).Return(nil)
+		repoMock.On("CreateUser", mock.Anything).Return("123", nil)
 
 		err := service.RegisterUser(inputUser)
 		asserting.Error(err)
@@ -246,10 +575,19 @@ func TestRegisterUser(t *testing.T) {
 		}
 
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
 		repoMock.On("FindUserByEmail", inputUser.Email).Return(result, errors.New("email not found"))
 		repoMock.On("UploadImageS3", mock.Anything).Return("url", nil)
-		repoMock.On("CreateUser", mock.Anything).Return(errors.New("error create user"))
+		repoMock.On("CreateUser", mock.Anything).Return("", errors.New("error create user"))
 
 		err := service.RegisterUser(inputUser)
 		asserting.Error(err)
@@ -275,10 +613,19 @@ func TestRegisterUser(t *testing.T) {
 		}
 
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
 		repoMock.On("FindUserByEmail", inputUser.Email).Return(result, nil)
 		repoMock.On("UploadImageS3", mock.Anything).Return("url", nil)
-		repoMock.On("CreateUser", mock.Anything).Return(errors.New("error create user"))
+		repoMock.On("CreateUser", mock.Anything).Return("", errors.New("error create user"))
 
 		err := service.RegisterUser(inputUser)
 		asserting.Error(err)
@@ -295,7 +642,16 @@ func TestGetUserByID(t *testing.T) {
 			FullName: "test",
 		}
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
 		repoMock.On("FindUserByID", user.ID).Return(user, nil)
 
 		res, err := service.GetUserByID(user.ID)
@@ -304,356 +660,3241 @@ func TestGetUserByID(t *testing.T) {
 	})
 }
 
-func TestSwipeUser(t *testing.T) {
+func TestVerifyEmail(t *testing.T) {
+	conf := &config.AppConfig{}
+	conf.Secrettoken.Token = "secret-token-for-test"
+	keys, err := utils.NewKeySet(conf.Secrettoken.Token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, token, err := utils.GenerateVerifyEmailToken("123", "test@mail.com", "jti", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	t.Run("Valid Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
-		}
-		swipe := businessUser.SwipeUser{
-			IDSwipe: "1234",
-			Swipe:   "like",
-		}
-		hour := 24 * time.Hour
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomid:123").Return("123", nil)
-		repoMock.On("Set", "apptinder:allrandomid:123", "123,1234", hour).Return(nil)
-		repoMock.On("GetRandomUser", mock.Anything).Return(businessUser.ResponseRandomUser{}, nil)
-		repoMock.On("SwipeUser", user.ID, mock.Anything).Return(nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
+		repoMock.On("GetDel", "apptinder:verifyemail:jti").Return("123", nil)
+		repoMock.On("SetEmailVerified", "123", mock.AnythingOfType("time.Time")).Return(nil)
 
-		err := service.SwipeUser(user.ID, swipe)
+		err := service.VerifyEmail(businessUser.VerifyEmail{Token: token})
 		asserting.NoError(err)
+		repoMock.AssertCalled(t, "SetEmailVerified", "123", mock.AnythingOfType("time.Time"))
 	})
 
-	t.Run("Valid Test 2", func(t *testing.T) {
+	t.Run("Used Token Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
-		}
-		swipe := businessUser.SwipeUser{
-			IDSwipe: "1234",
-			Swipe:   "like",
-		}
-		hour := 24 * time.Hour
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomid:123").Return("123,2232", nil)
-		repoMock.On("Set", "apptinder:allrandomid:123", "123,2232,1234", hour).Return(nil)
-		repoMock.On("GetRandomUser", mock.Anything).Return(businessUser.ResponseRandomUser{}, nil)
-		repoMock.On("SwipeUser", user.ID, mock.Anything).Return(nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
+		repoMock.On("GetDel", "apptinder:verifyemail:jti").Return("", errors.New("redis: nil"))
 
-		err := service.SwipeUser(user.ID, swipe)
-		asserting.NoError(err)
+		err := service.VerifyEmail(businessUser.VerifyEmail{Token: token})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "SetEmailVerified", mock.Anything, mock.Anything)
 	})
 
-	t.Run("Test Error Set Redis", func(t *testing.T) {
+	t.Run("Access Token Rejected Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
-		}
-		swipe := businessUser.SwipeUser{
-			IDSwipe: "1234",
-			Swipe:   "like",
-		}
-		hour := 24 * time.Hour
+		_, access, err := utils.GenerateAccessTokenUser("123", "test@mail.com", "family", "jti", 1, nil, keys)
+		asserting.NoError(err)
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomid:123").Return("123,2232", nil)
-		repoMock.On("Set", "apptinder:allrandomid:123", "123,2232,1234", hour).Return(errors.New("error set redis"))
-		repoMock.On("GetRandomUser", mock.Anything).Return(businessUser.ResponseRandomUser{}, nil)
-		repoMock.On("SwipeUser", user.ID, mock.Anything).Return(nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
 
-		err := service.SwipeUser(user.ID, swipe)
+		err = service.VerifyEmail(businessUser.VerifyEmail{Token: access})
 		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
 	})
 
-	t.Run("Limit 10 Swipe Test", func(t *testing.T) {
+	t.Run("Resend Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
-		}
-		swipe := businessUser.SwipeUser{
-			IDSwipe: "1234",
-			Swipe:   "like",
-		}
-		hour := 24 * time.Hour
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomid:123").Return("123,221,21412,21412,214,214,124,124,214,214,214,21", nil)
-		repoMock.On("Set", "apptinder:allrandomid:123", "123,1234", hour).Return(nil)
-		repoMock.On("GetRandomUser", mock.Anything).Return(businessUser.ResponseRandomUser{}, nil)
-		repoMock.On("SwipeUser", user.ID, mock.Anything).Return(nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
+		repoMock.On("FindUserByEmail", "test@mail.com").Return(businessUser.User{ID: "123", Email: "test@mail.com"}, nil)
+		repoMock.On("GenerateVerifyEmailToken", "123", "test@mail.com").Return("verify-token", nil)
 
-		err := service.SwipeUser(user.ID, swipe)
-		asserting.Error(err)
+		err := service.ResendVerifyEmail(businessUser.ResendVerifyEmail{Email: "test@mail.com"})
+		asserting.NoError(err)
+		asserting.Len(mailer.Messages(), 1)
 	})
 
-	t.Run("PREMIUM PACKAGE Swipe Test", func(t *testing.T) {
+	t.Run("Resend Unknown Email Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		packages := []businessUser.Package{
-			{
-				ID:          "123",
-				PackageName: "premium",
-				Description: "test",
-			},
-		}
-		user := businessUser.User{
-			ID:       "123",
-			Email:    "test@mail.com",
-			Packages: packages,
-		}
-		swipe := businessUser.SwipeUser{
-			IDSwipe: "1234",
-			Swipe:   "like",
-		}
-		hour := 24 * time.Hour
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomid:123").Return("123", nil)
-		repoMock.On("Set", "apptinder:allrandomid:123", "123,1234", hour).Return(nil)
-		repoMock.On("GetRandomUser", mock.Anything).Return(businessUser.ResponseRandomUser{}, nil)
-		repoMock.On("SwipeUser", user.ID, mock.Anything).Return(nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
+		repoMock.On("FindUserByEmail", "unknown@mail.com").Return(businessUser.User{}, errors.New("wrong email"))
 
-		err := service.SwipeUser(user.ID, swipe)
+		err := service.ResendVerifyEmail(businessUser.ResendVerifyEmail{Email: "unknown@mail.com"})
 		asserting.NoError(err)
+		asserting.Empty(mailer.Messages())
 	})
+}
 
-	t.Run("Id Swipe already Swipe Test", func(t *testing.T) {
-		asserting := assert.New(t)
+func TestForgotPassword(t *testing.T) {
+	user := businessUser.User{ID: "123", Email: "test@mail.com"}
 
-		swipe := businessUser.SwipeUser{
-			IDSwipe: "",
-			Swipe:   "like",
-		}
-		hour := 24 * time.Hour
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("Get", "apptinder:allrandomid:123").Return("1234", nil)
-		repoMock.On("Set", "apptinder:allrandomid:123", "123,1234", hour).Return(nil)
-		repoMock.On("GetRandomUser", mock.Anything).Return(businessUser.ResponseRandomUser{}, nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("FindUserByEmail", "test@mail.com").Return(user, nil)
+		repoMock.On("Incr", "apptinder:resetrequests:123", time.Hour).Return(int64(1), nil)
+		var hashed string
+		repoMock.On("Set", "apptinder:resetcode:123", mock.AnythingOfType("string"), 15*time.Minute).Run(func(args mock.Arguments) {
+			hashed = args.String(1)
+		}).Return(nil)
 
-		err := service.SwipeUser(swipe.IDSwipe, swipe)
-		asserting.Error(err)
+		err := service.ForgotPassword(businessUser.ForgotPassword{Email: "test@mail.com"})
+		asserting.NoError(err)
+		messages := mailer.Messages()
+		asserting.Len(messages, 1)
+		// only the hash of the mailed code is stored
+		code := strings.Fields(messages[0].Body)[5]
+		asserting.Len(code, 6)
+		asserting.NotEqual(code, hashed)
+		asserting.True(utils.VerifyCode(hashed, code))
 	})
 
-	t.Run("Error Get Me", func(t *testing.T) {
-		asserting := assert.New(t)
-
-		swipe := businessUser.SwipeUser{
-			IDSwipe: "124",
-			Swipe:   "like",
-		}
-		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetMe", "1234").Return(businessUser.User{}, errors.New("error get me"))
-		repoMock.On("Get", "apptinder:allrandomid:1234").Return("", nil)
-		err := service.SwipeUser("1234", swipe)
-		asserting.Error(err)
-	})
-	t.Run("Already Swipe Test", func(t *testing.T) {
+	t.Run("Unknown Email Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
-		}
-		swipe := businessUser.SwipeUser{
-			IDSwipe: "1234",
-			Swipe:   "like",
-		}
-		hour := 24 * time.Hour
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomid:123").Return("1234,24,124,214,214,214,21", nil)
-		repoMock.On("Set", "apptinder:allrandomid:123", "123,1234", hour).Return(nil)
-		repoMock.On("GetRandomUser", mock.Anything).Return(businessUser.ResponseRandomUser{}, nil)
-		repoMock.On("SwipeUser", user.ID, mock.Anything).Return(nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("FindUserByEmail", "unknown@mail.com").Return(businessUser.User{}, errors.New("wrong email"))
 
-		err := service.SwipeUser(user.ID, swipe)
-		asserting.Error(err)
+		err := service.ForgotPassword(businessUser.ForgotPassword{Email: "unknown@mail.com"})
+		asserting.NoError(err)
+		asserting.Empty(mailer.Messages())
 	})
 
-	t.Run("Append Arr Test", func(t *testing.T) {
+	t.Run("Too Many Requests Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
-		}
-		swipe := businessUser.SwipeUser{
-			IDSwipe: "1234",
-			Swipe:   "like",
-		}
-		hour := 24 * time.Hour
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomid:123").Return("", nil)
-		repoMock.On("Set", "apptinder:allrandomid:123", "123,1234", hour).Return(nil)
-		repoMock.On("GetRandomUser", mock.Anything).Return(businessUser.ResponseRandomUser{}, nil)
-		repoMock.On("SwipeUser", user.ID, mock.Anything).Return(nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("FindUserByEmail", "test@mail.com").Return(user, nil)
+		repoMock.On("Incr", "apptinder:resetrequests:123", time.Hour).Return(int64(4), nil)
 
-		err := service.SwipeUser(user.ID, swipe)
+		err := service.ForgotPassword(businessUser.ForgotPassword{Email: "test@mail.com"})
 		asserting.NoError(err)
+		asserting.Empty(mailer.Messages())
+		repoMock.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything)
 	})
-
 }
 
-func TestPurchasePackage(t *testing.T) {
+func TestResetPassword(t *testing.T) {
+	user := businessUser.User{ID: "123", Email: "test@mail.com"}
+	input := businessUser.ResetPassword{Email: "test@mail.com", Code: "123456", Password: "new-password"}
+
 	t.Run("Valid Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
-		}
-		packages := "123"
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("PurchasePackage", user.ID, packages).Return(nil)
-		repoMock.On("UpdatePackageUser", user.ID, mock.Anything).Return(nil)
-		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{}, nil)
-		repoMock.On("GetMe", user.ID).Return(user, nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("FindUserByEmail", "test@mail.com").Return(user, nil)
+		repoMock.On("Incr", "apptinder:resetattempts:123", 15*time.Minute).Return(int64(1), nil)
+		repoMock.On("Get", "apptinder:resetcode:123").Return(utils.HashCode("123456"), nil)
+		repoMock.On("GetDel", "apptinder:resetcode:123").Return(utils.HashCode("123456"), nil)
+		repoMock.On("UpdatePassword", "123", mock.MatchedBy(func(hash string) bool {
+			return utils.VerifyPassword(hash, "new-password") == nil
+		})).Return(nil)
+		repoMock.On("Del", "apptinder:resetattempts:123").Return(nil)
+		repoMock.On("IncrTokenVersion", "123").Return(nil)
+		sessionMock.On("RevokeSessions", "123", mock.AnythingOfType("time.Time")).Return(nil)
 
-		err := service.PurchasePackage(user.ID, packages)
+		err := service.ResetPassword(input)
 		asserting.NoError(err)
+		repoMock.AssertCalled(t, "IncrTokenVersion", "123")
+		sessionMock.AssertCalled(t, "RevokeSessions", "123", mock.AnythingOfType("time.Time"))
 	})
 
-	t.Run("Purchase Getme Error Test", func(t *testing.T) {
+	t.Run("Wrong Code Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
-		}
-		packages := "123"
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("PurchasePackage", user.ID, packages).Return(nil)
-		repoMock.On("UpdatePackageUser", user.ID, mock.Anything).Return(nil)
-		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{}, nil)
-		repoMock.On("GetMe", user.ID).Return(user, errors.New("error get me"))
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("FindUserByEmail", "test@mail.com").Return(user, nil)
+		repoMock.On("Incr", "apptinder:resetattempts:123", 15*time.Minute).Return(int64(1), nil)
+		repoMock.On("Get", "apptinder:resetcode:123").Return(utils.HashCode("654321"), nil)
 
-		err := service.PurchasePackage(user.ID, packages)
+		err := service.ResetPassword(input)
 		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "GetDel", mock.Anything)
+		repoMock.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
 	})
 
-	t.Run("already purchase Error Test", func(t *testing.T) {
+	t.Run("Too Many Attempts Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:      "123",
-			Email:   "test@mail.com",
-			Package: []string{"123"},
-		}
-		packages := "123"
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("PurchasePackage", user.ID, packages).Return(nil)
-		repoMock.On("UpdatePackageUser", user.ID, mock.Anything).Return(nil)
-		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{}, nil)
-		repoMock.On("GetMe", user.ID).Return(user, nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("FindUserByEmail", "test@mail.com").Return(user, nil)
+		repoMock.On("Incr", "apptinder:resetattempts:123", 15*time.Minute).Return(int64(6), nil)
+		repoMock.On("Del", "apptinder:resetcode:123").Return(nil)
 
-		err := service.PurchasePackage(user.ID, packages)
+		err := service.ResetPassword(input)
 		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertCalled(t, "Del", "apptinder:resetcode:123")
+		repoMock.AssertNotCalled(t, "Get", mock.Anything)
 	})
 
-	t.Run("package not found Error Test", func(t *testing.T) {
+	t.Run("Unknown Email Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
-		}
-		packages := "123"
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("PurchasePackage", user.ID, packages).Return(nil)
-		repoMock.On("UpdatePackageUser", user.ID, mock.Anything).Return(nil)
-		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{}, errors.New("package not found"))
-		repoMock.On("GetMe", user.ID).Return(user, nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("FindUserByEmail", "test@mail.com").Return(businessUser.User{}, errors.New("wrong email"))
 
-		err := service.PurchasePackage(user.ID, packages)
+		err := service.ResetPassword(input)
 		asserting.Error(err)
+		asserting.Equal("invalid or expired reset code", err.Error())
 	})
+}
 
-	t.Run("Update Package User Error Test", func(t *testing.T) {
-		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
+func TestLoginMFA(t *testing.T) {
+	keys, err := utils.NewKeySet("secret-token-for-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := businessUser.User{ID: "123", Email: "test@mail.com", MFAEnabled: true, MFASecret: secret}
+	newChallenge := func(jti string) string {
+		_, token, err := utils.GenerateMFAChallengeToken("123", "test@mail.com", jti, keys)
+		if err != nil {
+			t.Fatal(err)
 		}
-		packages := "123"
-		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("PurchasePackage", user.ID, packages).Return(nil)
-		repoMock.On("UpdatePackageUser", user.ID, mock.Anything).Return(errors.New("error update package user"))
-		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{}, nil)
-		repoMock.On("GetMe", user.ID).Return(user, nil)
-
-		err := service.PurchasePackage(user.ID, packages)
-		asserting.Error(err)
+		return token
+	}
+	totpUsed := mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "apptinder:totpused:123:")
 	})
-}
 
-func TestGetListPackage(t *testing.T) {
 	t.Run("Valid Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		packages := []businessUser.Package{
-			{
-				ID:          "123",
-				PackageName: "test",
-				Description: "test",
-			},
-		}
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetListPackage").Return(packages, nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Get", "apptinder:mfachallenge:jti-1").Return("123", nil)
+		repoMock.On("Incr", "apptinder:mfaattempts:jti-1", 300*time.Second).Return(int64(1), nil)
+		repoMock.On("GetMe", "123").Return(user, nil)
+		repoMock.On("Incr", totpUsed, 90*time.Second).Return(int64(1), nil)
+		repoMock.On("GetDel", "apptinder:mfachallenge:jti-1").Return("123", nil)
+		repoMock.On("Del", "apptinder:mfaattempts:jti-1").Return(nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
+		sessionMock.On("CreateSession", mock.AnythingOfType("user.Session")).Return(businessUser.Session{ID: "session"}, nil)
+		repoMock.On("GenerateTokenFamily", "123", "test@mail.com", "session", mock.Anything).Return(&utils.Token{AccessToken: "access"}, nil)
 
-		res, err := service.GetListPackage()
+		code, err := utils.TOTPCode(secret, time.Now())
 		asserting.NoError(err)
-		asserting.NotNil(res)
+		res, err := service.LoginMFA(businessUser.LoginMFA{MFAToken: newChallenge("jti-1"), Code: code})
+		asserting.NoError(err)
+		asserting.Equal("access", res.Token.AccessToken)
+		repoMock.AssertCalled(t, "Del", "apptinder:loginfail:account:test@mail.com")
 	})
-}
 
-func TestGetMe(t *testing.T) {
-	t.Run("Valid Test", func(t *testing.T) {
+	t.Run("Wrong Code Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		user := businessUser.User{
-			ID:    "123",
-			Email: "test@mail.com",
-		}
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetMe", user.ID).Return(user, nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Get", "apptinder:mfachallenge:jti-1").Return("123", nil)
+		repoMock.On("Incr", "apptinder:mfaattempts:jti-1", 300*time.Second).Return(int64(1), nil)
+		repoMock.On("GetMe", "123").Return(user, nil)
+		repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(1), nil)
 
-		res, err := service.GetMe(user.ID)
+		code, err := utils.TOTPCode(secret, time.Now().Add(-10*time.Minute))
 		asserting.NoError(err)
-
-		asserting.NotNil(res)
+		_, err = service.LoginMFA(businessUser.LoginMFA{MFAToken: newChallenge("jti-1"), Code: code})
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+		repoMock.AssertCalled(t, "Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour)
+		sessionMock.AssertNotCalled(t, "CreateSession", mock.Anything)
 	})
-}
 
-func TestGetPackageByID(t *testing.T) {
-	t.Run("Valid Test", func(t *testing.T) {
+	t.Run("Replayed Code Test", func(t *testing.T) {
 		asserting := assert.New(t)
-		packages := businessUser.Package{
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Get", "apptinder:mfachallenge:jti-1").Return("123", nil)
+		repoMock.On("Incr", "apptinder:mfaattempts:jti-1", 300*time.Second).Return(int64(1), nil)
+		repoMock.On("GetMe", "123").Return(user, nil)
+		repoMock.On("Incr", totpUsed, 90*time.Second).Return(int64(2), nil)
+		repoMock.On("Incr", "apptinder:loginfail:account:test@mail.com", 24*time.Hour).Return(int64(1), nil)
+
+		code, err := utils.TOTPCode(secret, time.Now())
+		asserting.NoError(err)
+		_, err = service.LoginMFA(businessUser.LoginMFA{MFAToken: newChallenge("jti-1"), Code: code})
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+		sessionMock.AssertNotCalled(t, "CreateSession", mock.Anything)
+	})
+
+	t.Run("Recovery Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Get", "apptinder:mfachallenge:jti-1").Return("123", nil)
+		repoMock.On("Incr", "apptinder:mfaattempts:jti-1", 300*time.Second).Return(int64(1), nil)
+		repoMock.On("GetMe", "123").Return(user, nil)
+		repoMock.On("UseRecoveryCode", "123", utils.HashCode("1234567890")).Return(true, nil)
+		repoMock.On("GetDel", "apptinder:mfachallenge:jti-1").Return("123", nil)
+		repoMock.On("Del", "apptinder:mfaattempts:jti-1").Return(nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
+		sessionMock.On("CreateSession", mock.AnythingOfType("user.Session")).Return(businessUser.Session{ID: "session"}, nil)
+		repoMock.On("GenerateTokenFamily", "123", "test@mail.com", "session", mock.Anything).Return(&utils.Token{AccessToken: "access"}, nil)
+
+		res, err := service.LoginMFA(businessUser.LoginMFA{MFAToken: newChallenge("jti-1"), Code: "12345-67890"})
+		asserting.NoError(err)
+		asserting.NotNil(res.Token)
+	})
+
+	t.Run("Too Many Attempts Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:loginlock:account:test@mail.com").Return("", errors.New("redis: nil"))
+		repoMock.On("Get", "apptinder:mfachallenge:jti-1").Return("123", nil)
+		repoMock.On("Incr", "apptinder:mfaattempts:jti-1", 300*time.Second).Return(int64(6), nil)
+		repoMock.On("Del", "apptinder:mfachallenge:jti-1").Return(nil)
+
+		_, err := service.LoginMFA(businessUser.LoginMFA{MFAToken: newChallenge("jti-1"), Code: "123456"})
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+		repoMock.AssertCalled(t, "Del", "apptinder:mfachallenge:jti-1")
+		repoMock.AssertNotCalled(t, "GetMe", mock.Anything)
+	})
+
+	t.Run("Access Token Is Not A Challenge Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, &config.AppConfig{})
+
+		_, access, err := utils.GenerateAccessTokenUser("123", "test@mail.com", "family", "jti-1", 0, nil, keys)
+		asserting.NoError(err)
+		_, err = service.LoginMFA(businessUser.LoginMFA{MFAToken: access, Code: "123456"})
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+	})
+}
+
+func TestEnrollMFA(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123", Email: "test@mail.com"}, nil)
+		repoMock.On("SetMFAPendingSecret", "123", mock.AnythingOfType("string")).Return(nil)
+
+		res, err := service.EnrollMFA("123")
+		asserting.NoError(err)
+		asserting.NotEmpty(res.Secret)
+		asserting.Contains(res.URI, "secret="+res.Secret)
+		repoMock.AssertCalled(t, "SetMFAPendingSecret", "123", res.Secret)
+	})
+
+	t.Run("Already Enabled Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123", MFAEnabled: true}, nil)
+
+		_, err := service.EnrollMFA("123")
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+}
+
+func TestConfirmMFA(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123", MFAPendingSecret: secret}, nil)
+		repoMock.On("Incr", mock.AnythingOfType("string"), 90*time.Second).Return(int64(1), nil)
+		repoMock.On("EnableMFA", "123", secret, mock.AnythingOfType("[]string")).Return(nil)
+
+		code, err := utils.TOTPCode(secret, time.Now())
+		asserting.NoError(err)
+		res, err := service.ConfirmMFA("123", businessUser.MFACode{Code: code})
+		asserting.NoError(err)
+		asserting.Len(res.RecoveryCodes, 10)
+
+		// only hashes of the recovery codes are stored
+		hashed := repoMock.Calls[len(repoMock.Calls)-1].Arguments.Get(2).([]string)
+		asserting.Len(hashed, 10)
+		asserting.Equal(utils.HashCode(strings.Replace(res.RecoveryCodes[0], "-", "", -1)), hashed[0])
+	})
+
+	t.Run("Wrong Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123", MFAPendingSecret: secret}, nil)
+
+		code, err := utils.TOTPCode(secret, time.Now().Add(-10*time.Minute))
+		asserting.NoError(err)
+		_, err = service.ConfirmMFA("123", businessUser.MFACode{Code: code})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "EnableMFA", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Not Enrolled Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123"}, nil)
+
+		_, err := service.ConfirmMFA("123", businessUser.MFACode{Code: "123456"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+}
+
+func TestDisableMFA(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := businessUser.User{ID: "123", MFAEnabled: true, MFASecret: secret}
+
+	t.Run("Recovery Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetMe", "123").Return(user, nil)
+		repoMock.On("UseRecoveryCode", "123", utils.HashCode("1234567890")).Return(true, nil)
+		repoMock.On("DisableMFA", "123").Return(nil)
+
+		err := service.DisableMFA("123", businessUser.MFACode{Code: "12345-67890"})
+		asserting.NoError(err)
+		repoMock.AssertCalled(t, "DisableMFA", "123")
+	})
+
+	t.Run("Used Recovery Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetMe", "123").Return(user, nil)
+		repoMock.On("UseRecoveryCode", "123", utils.HashCode("1234567890")).Return(false, nil)
+
+		err := service.DisableMFA("123", businessUser.MFACode{Code: "12345-67890"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "DisableMFA", mock.Anything)
+	})
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := businessUser.User{ID: "123", MFAEnabled: true, MFASecret: secret}
+
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetMe", "123").Return(user, nil)
+		repoMock.On("Incr", mock.AnythingOfType("string"), 90*time.Second).Return(int64(1), nil)
+		repoMock.On("SetRecoveryCodes", "123", mock.AnythingOfType("[]string")).Return(nil)
+
+		code, err := utils.TOTPCode(secret, time.Now())
+		asserting.NoError(err)
+		res, err := service.GenerateRecoveryCodes("123", businessUser.MFACode{Code: code})
+		asserting.NoError(err)
+		asserting.Len(res.RecoveryCodes, 10)
+	})
+
+	t.Run("Recovery Code Not Accepted Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetMe", "123").Return(user, nil)
+
+		_, err := service.GenerateRecoveryCodes("123", businessUser.MFACode{Code: "12345-67890"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "UseRecoveryCode", mock.Anything, mock.Anything)
+		repoMock.AssertNotCalled(t, "SetRecoveryCodes", mock.Anything, mock.Anything)
+	})
+}
+
+func TestOAuth(t *testing.T) {
+	identity := businessUser.Identity{Subject: "subject-1", Email: "test@mail.com", EmailVerified: true, FullName: "test"}
+	linked := mock.MatchedBy(func(identity businessUser.Identity) bool {
+		return identity.Provider == "fake" && identity.Subject == "subject-1"
+	})
+	// signIn runs StartOAuth and the sign in at the fake provider, it returns
+	// the state and the code the provider redirects back with
+	signIn := func(t *testing.T, service businessUser.Service, repoMock *repoUser.UserMock, provider *repoIdentity.FakeProvider, identity businessUser.Identity) (string, string) {
+		var stored string
+		repoMock.On("Set", mock.MatchedBy(func(key string) bool {
+			return strings.HasPrefix(key, "apptinder:oauthstate:")
+		}), mock.AnythingOfType("string"), 10*time.Minute).Run(func(args mock.Arguments) {
+			stored = args.String(1)
+		}).Return(nil)
+
+		res, err := service.StartOAuth("fake")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := provider.Authorize(res.State, identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repoMock.On("GetDel", "apptinder:oauthstate:"+res.State).Return(stored, nil)
+		return res.State, code
+	}
+
+	t.Run("New Account Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		provider := repoIdentity.NewFakeProvider()
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		providers := map[string]businessUser.IdentityProvider{"fake": provider}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, providers, nil, &config.AppConfig{})
+		repoMock.On("FindUserByIdentity", "fake", "subject-1").Return(businessUser.User{}, errors.New("identity not linked"))
+		repoMock.On("FindUserByEmail", "test@mail.com").Return(businessUser.User{}, errors.New("wrong email"))
+		repoMock.On("CreateOAuthUser", linked).Return("123", nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
+		sessionMock.On("CreateSession", mock.AnythingOfType("user.Session")).Return(businessUser.Session{ID: "session"}, nil)
+		repoMock.On("GenerateTokenFamily", "123", "test@mail.com", "session", mock.Anything).Return(&utils.Token{AccessToken: "access"}, nil)
+
+		state, code := signIn(t, service, repoMock, provider, identity)
+		res, err := service.OAuthCallback("fake", businessUser.OAuthCallback{Code: code, State: state})
+		asserting.NoError(err)
+		asserting.Equal("access", res.Token.AccessToken)
+		asserting.True(res.ProfileIncomplete)
+	})
+
+	t.Run("Link Verified Account Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		provider := repoIdentity.NewFakeProvider()
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		providers := map[string]businessUser.IdentityProvider{"fake": provider}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, providers, nil, &config.AppConfig{})
+		user := businessUser.User{ID: "123", Email: "test@mail.com", EmailVerified: true, ProfileCompleted: true}
+		repoMock.On("FindUserByIdentity", "fake", "subject-1").Return(businessUser.User{}, errors.New("identity not linked"))
+		repoMock.On("FindUserByEmail", "test@mail.com").Return(user, nil)
+		repoMock.On("LinkIdentity", "123", linked, mock.AnythingOfType("time.Time")).Return(nil)
+		auditMock.On("CreateAuditEvent", mock.MatchedBy(func(event businessUser.AuditEvent) bool {
+			return event.Type == businessUser.AuditIdentityLinked && event.UserID == "123"
+		})).Return(nil)
+		repoMock.On("Del", "apptinder:loginfail:account:test@mail.com").Return(nil)
+		sessionMock.On("CreateSession", mock.AnythingOfType("user.Session")).Return(businessUser.Session{ID: "session"}, nil)
+		repoMock.On("GenerateTokenFamily", "123", "test@mail.com", "session", mock.Anything).Return(&utils.Token{AccessToken: "access"}, nil)
+
+		state, code := signIn(t, service, repoMock, provider, identity)
+		res, err := service.OAuthCallback("fake", businessUser.OAuthCallback{Code: code, State: state})
+		asserting.NoError(err)
+		asserting.False(res.ProfileIncomplete)
+		repoMock.AssertCalled(t, "LinkIdentity", "123", linked, mock.AnythingOfType("time.Time"))
+		repoMock.AssertNotCalled(t, "CreateOAuthUser", mock.Anything)
+	})
+
+	t.Run("Unverified Account Not Linked Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		provider := repoIdentity.NewFakeProvider()
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		providers := map[string]businessUser.IdentityProvider{"fake": provider}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, providers, nil, &config.AppConfig{})
+		repoMock.On("FindUserByIdentity", "fake", "subject-1").Return(businessUser.User{}, errors.New("identity not linked"))
+		repoMock.On("FindUserByEmail", "test@mail.com").Return(businessUser.User{ID: "123", Email: "test@mail.com"}, nil)
+
+		state, code := signIn(t, service, repoMock, provider, identity)
+		_, err := service.OAuthCallback("fake", businessUser.OAuthCallback{Code: code, State: state})
+		asserting.Error(err)
+		asserting.Equal(409, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "LinkIdentity", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unverified Provider Email Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		provider := repoIdentity.NewFakeProvider()
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		providers := map[string]businessUser.IdentityProvider{"fake": provider}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, providers, nil, &config.AppConfig{})
+		repoMock.On("FindUserByIdentity", "fake", "subject-1").Return(businessUser.User{}, errors.New("identity not linked"))
+
+		unverified := identity
+		unverified.EmailVerified = false
+		state, code := signIn(t, service, repoMock, provider, unverified)
+		_, err := service.OAuthCallback("fake", businessUser.OAuthCallback{Code: code, State: state})
+		asserting.Error(err)
+		asserting.Equal(403, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "FindUserByEmail", mock.Anything)
+	})
+
+	t.Run("Linked Identity With MFA Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		provider := repoIdentity.NewFakeProvider()
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		providers := map[string]businessUser.IdentityProvider{"fake": provider}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, providers, nil, &config.AppConfig{})
+		user := businessUser.User{ID: "123", Email: "test@mail.com", MFAEnabled: true}
+		repoMock.On("FindUserByIdentity", "fake", "subject-1").Return(user, nil)
+		repoMock.On("GenerateMFAChallenge", "123", "test@mail.com").Return("challenge", nil)
+
+		state, code := signIn(t, service, repoMock, provider, identity)
+		res, err := service.OAuthCallback("fake", businessUser.OAuthCallback{Code: code, State: state})
+		asserting.NoError(err)
+		asserting.True(res.MFARequired)
+		asserting.Nil(res.Token)
+		sessionMock.AssertNotCalled(t, "CreateSession", mock.Anything)
+	})
+
+	t.Run("Other Provider State Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		provider := repoIdentity.NewFakeProvider()
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		providers := map[string]businessUser.IdentityProvider{"fake": provider, "other": repoIdentity.NewFakeProvider()}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, providers, nil, &config.AppConfig{})
+
+		state, code := signIn(t, service, repoMock, provider, identity)
+		_, err := service.OAuthCallback("other", businessUser.OAuthCallback{Code: code, State: state})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+
+	t.Run("Used State Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		providers := map[string]businessUser.IdentityProvider{"fake": repoIdentity.NewFakeProvider()}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, providers, nil, &config.AppConfig{})
+		repoMock.On("GetDel", "apptinder:oauthstate:state-1").Return("", errors.New("redis: nil"))
+
+		_, err := service.OAuthCallback("fake", businessUser.OAuthCallback{Code: "code", State: "state-1"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+
+	t.Run("Unknown Provider Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		_, err := service.StartOAuth("fake")
+		asserting.Error(err)
+		asserting.Equal(404, utils.GetStatusCode(err))
+	})
+}
+
+func TestCompleteProfile(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		file := &multipart.FileHeader{Filename: "photo.jpg"}
+		repoMock.On("UploadImageS3", file).Return("https://s3.test/photo.jpeg", nil)
+		repoMock.On("CompleteProfile", "123", "test", "https://s3.test/photo.jpeg").Return(nil)
+
+		err := service.CompleteProfile("123", businessUser.CompleteProfile{FullName: "test", File: file})
+		asserting.NoError(err)
+		repoMock.AssertCalled(t, "CompleteProfile", "123", "test", "https://s3.test/photo.jpeg")
+	})
+
+	t.Run("Missing Photo Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		err := service.CompleteProfile("123", businessUser.CompleteProfile{FullName: "test"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "CompleteProfile", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestSwipeUser(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(false, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(2), nil)
+		swipeMock.On("CreateSwipe", mock.MatchedBy(func(data businessUser.Swipe) bool {
+			return data.SwiperID == user.ID && data.TargetID == swipe.IDSwipe && data.Direction == swipe.Swipe
+		})).Return(nil)
+		swipeMock.On("IsLiked", swipe.IDSwipe, user.ID).Return(false, nil)
+
+		_, err := service.SwipeUser(user.ID, swipe)
+		asserting.NoError(err)
+		swipeMock.AssertNotCalled(t, "CountSwipeSince", mock.Anything, mock.Anything)
+	})
+
+	t.Run("New Counter Count From Ledger Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "pass",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(false, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(1), nil)
+		repoMock.On("IncrBy", "apptinder:swipecount:123", int64(3), mock.Anything).Return(int64(4), nil)
+		swipeMock.On("CountSwipeSince", user.ID, mock.Anything).Return(int64(3), nil)
+		swipeMock.On("CreateSwipe", mock.Anything).Return(nil)
+		swipeMock.On("IsLiked", swipe.IDSwipe, user.ID).Return(false, nil)
+
+		_, err := service.SwipeUser(user.ID, swipe)
+		asserting.NoError(err)
+		repoMock.AssertCalled(t, "IncrBy", "apptinder:swipecount:123", int64(3), mock.Anything)
+	})
+
+	t.Run("Error Count Swipe Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(false, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(1), nil)
+		repoMock.On("IncrBy", "apptinder:swipecount:123", int64(-1), mock.Anything).Return(int64(0), nil)
+		swipeMock.On("CountSwipeSince", user.ID, mock.Anything).Return(int64(0), errors.New("error count swipe"))
+
+		_, err := service.SwipeUser(user.ID, swipe)
+		asserting.Error(err)
+		asserting.Equal(500, utils.GetStatusCode(err))
+	})
+
+	t.Run("Error Incr Redis Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(false, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(0), errors.New("error incr redis"))
+
+		_, err := service.SwipeUser(user.ID, swipe)
+		asserting.Error(err)
+		asserting.Equal(500, utils.GetStatusCode(err))
+		swipeMock.AssertNotCalled(t, "CreateSwipe", mock.Anything)
+	})
+
+	t.Run("Limit 10 Swipe Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(false, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(11), nil)
+		repoMock.On("IncrBy", "apptinder:swipecount:123", int64(-1), mock.Anything).Return(int64(10), nil)
+
+		_, err := service.SwipeUser(user.ID, swipe)
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		swipeMock.AssertNotCalled(t, "CreateSwipe", mock.Anything)
+		repoMock.AssertCalled(t, "IncrBy", "apptinder:swipecount:123", int64(-1), mock.Anything)
+	})
+
+	t.Run("Tenth Swipe Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(false, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(10), nil)
+		swipeMock.On("CreateSwipe", mock.Anything).Return(nil)
+		swipeMock.On("IsLiked", swipe.IDSwipe, user.ID).Return(false, nil)
+
+		_, err := service.SwipeUser(user.ID, swipe)
+		asserting.NoError(err)
+		repoMock.AssertNotCalled(t, "IncrBy", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unlimited Swipes Feature Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(true, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(26), nil)
+		swipeMock.On("CreateSwipe", mock.Anything).Return(nil)
+		swipeMock.On("IsLiked", swipe.IDSwipe, user.ID).Return(false, nil)
+
+		_, err := service.SwipeUser(user.ID, swipe)
+		asserting.NoError(err)
+	})
+
+	t.Run("Mutual Like Creates Match Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(false, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(2), nil)
+		swipeMock.On("CreateSwipe", mock.Anything).Return(nil)
+		swipeMock.On("IsLiked", swipe.IDSwipe, user.ID).Return(true, nil)
+		matchMock.On("CreateMatch", mock.MatchedBy(func(data businessUser.Match) bool {
+			return data.Status == businessUser.MatchStatusActive && len(data.UserIDs) == 2
+		})).Return(businessUser.Match{ID: "999"}, nil)
+
+		res, err := service.SwipeUser(user.ID, swipe)
+		asserting.NoError(err)
+		asserting.True(res.Match)
+		asserting.Equal("999", res.MatchID)
+	})
+
+	t.Run("Pass Never Creates Match Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "pass",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(false, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(2), nil)
+		swipeMock.On("CreateSwipe", mock.Anything).Return(nil)
+
+		res, err := service.SwipeUser(user.ID, swipe)
+		asserting.NoError(err)
+		asserting.False(res.Match)
+		swipeMock.AssertNotCalled(t, "IsLiked", mock.Anything, mock.Anything)
+		matchMock.AssertNotCalled(t, "CreateMatch", mock.Anything)
+	})
+
+	t.Run("Error Create Match Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(false, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(2), nil)
+		swipeMock.On("CreateSwipe", mock.Anything).Return(nil)
+		swipeMock.On("IsLiked", swipe.IDSwipe, user.ID).Return(true, nil)
+		matchMock.On("CreateMatch", mock.Anything).Return(businessUser.Match{}, errors.New("error create match"))
+
+		_, err := service.SwipeUser(user.ID, swipe)
+		asserting.Error(err)
+	})
+
+	t.Run("Validation Test", func(t *testing.T) {
+		asserting := assert.New(t)
+
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		_, err := service.SwipeUser("123", swipe)
+		asserting.Error(err)
+	})
+
+	t.Run("Swipe Yourself Test", func(t *testing.T) {
+		asserting := assert.New(t)
+
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "123",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		_, err := service.SwipeUser("123", swipe)
+		asserting.Error(err)
+	})
+
+	t.Run("Error Entitlements Test", func(t *testing.T) {
+		asserting := assert.New(t)
+
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "124",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", "1234", entitlement.FeatureUnlimitedSwipes).Return(false, errors.New("error get features"))
+
+		_, err := service.SwipeUser("1234", swipe)
+		asserting.Error(err)
+	})
+
+	t.Run("Already Swipe Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		swipe := businessUser.SwipeUser{
+			IDSwipe: "1234",
+			Swipe:   "like",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		featureMock.On("Has", user.ID, entitlement.FeatureUnlimitedSwipes).Return(false, nil)
+		repoMock.On("Incr", "apptinder:swipecount:123", mock.Anything).Return(int64(4), nil)
+		repoMock.On("IncrBy", "apptinder:swipecount:123", int64(-1), mock.Anything).Return(int64(3), nil)
+		swipeMock.On("CreateSwipe", mock.Anything).Return(utils.HandleError(400, "already swipe"))
+
+		_, err := service.SwipeUser(user.ID, swipe)
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertCalled(t, "IncrBy", "apptinder:swipecount:123", int64(-1), mock.Anything)
+	})
+}
+
+func TestPurchasePackage(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{ID: packages, BillingPeriod: businessUser.BillingMonthly}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+		paymentMock.On("CreatePayment", mock.AnythingOfType("user.Payment")).Return(businessUser.Payment{ID: "789", UserID: user.ID, Status: businessUser.PaymentPending}, nil)
+		paymentMock.On("UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(true, nil)
+		entitlementMock.On("ExpireLapsedEntitlements", mock.Anything, mock.Anything, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{}, nil)
+		entitlementMock.On("CreateEntitlement", mock.MatchedBy(func(data businessUser.Entitlement) bool {
+			return data.UserID == user.ID && data.PackageID == packages &&
+				data.Status == businessUser.EntitlementActive &&
+				data.EndsAt != nil && data.EndsAt.Equal(data.StartsAt.AddDate(0, 1, 0))
+		})).Return(businessUser.Entitlement{ID: "456"}, nil)
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.NoError(err)
+		entitlementMock.AssertNumberOfCalls(t, "CreateEntitlement", 1)
+		// a free purchase is settled in the ledger right away
+		paymentMock.AssertCalled(t, "UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time"))
+	})
+
+	t.Run("Trial Days Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{ID: packages, BillingPeriod: businessUser.BillingMonthly, TrialDays: 7}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+		paymentMock.On("CreatePayment", mock.AnythingOfType("user.Payment")).Return(businessUser.Payment{ID: "789", UserID: user.ID, Status: businessUser.PaymentPending}, nil)
+		paymentMock.On("UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(true, nil)
+		entitlementMock.On("ExpireLapsedEntitlements", mock.Anything, mock.Anything, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{}, nil)
+		// the month is billed once the week of trial is over
+		entitlementMock.On("CreateEntitlement", mock.MatchedBy(func(data businessUser.Entitlement) bool {
+			return data.EndsAt != nil && data.EndsAt.Equal(data.StartsAt.AddDate(0, 0, 7).AddDate(0, 1, 0))
+		})).Return(businessUser.Entitlement{ID: "456"}, nil)
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.NoError(err)
+		entitlementMock.AssertNumberOfCalls(t, "CreateEntitlement", 1)
+	})
+
+	t.Run("Renewal Expires Lapsed Entitlement Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{ID: packages, BillingPeriod: businessUser.BillingMonthly}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+		paymentMock.On("CreatePayment", mock.AnythingOfType("user.Payment")).Return(businessUser.Payment{ID: "789", UserID: user.ID, Status: businessUser.PaymentPending}, nil)
+		paymentMock.On("UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(true, nil)
+		entitlementMock.On("ExpireLapsedEntitlements", user.ID, packages, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{{ID: "455", UserID: user.ID, PackageID: packages}}, nil)
+		entitlementMock.On("CreateEntitlement", mock.AnythingOfType("user.Entitlement")).Return(businessUser.Entitlement{ID: "456"}, nil)
+		auditMock.On("CreateAuditEvent", mock.MatchedBy(func(event businessUser.AuditEvent) bool {
+			return event.Type == businessUser.AuditEntitlementExpired && event.UserID == user.ID
+		})).Return(nil)
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.NoError(err)
+		auditMock.AssertNumberOfCalls(t, "CreateAuditEvent", 1)
+	})
+
+	t.Run("Error Expire Lapsed Entitlement Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{ID: packages}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+		paymentMock.On("CreatePayment", mock.AnythingOfType("user.Payment")).Return(businessUser.Payment{ID: "789", UserID: user.ID, Status: businessUser.PaymentPending}, nil)
+		paymentMock.On("UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentFailed, mock.AnythingOfType("time.Time")).Return(true, nil)
+		entitlementMock.On("ExpireLapsedEntitlements", user.ID, packages, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{}, errors.New("error expire entitlements"))
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.Error(err)
+		asserting.Equal(500, utils.GetStatusCode(err))
+		entitlementMock.AssertNotCalled(t, "CreateEntitlement", mock.Anything)
+	})
+
+	t.Run("One-off Package Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		// packages from before billing periods have none
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{ID: packages}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+		paymentMock.On("CreatePayment", mock.AnythingOfType("user.Payment")).Return(businessUser.Payment{ID: "789", UserID: user.ID, Status: businessUser.PaymentPending}, nil)
+		paymentMock.On("UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(true, nil)
+		entitlementMock.On("ExpireLapsedEntitlements", mock.Anything, mock.Anything, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{}, nil)
+		entitlementMock.On("CreateEntitlement", mock.MatchedBy(func(data businessUser.Entitlement) bool {
+			return data.EndsAt == nil
+		})).Return(businessUser.Entitlement{ID: "456"}, nil)
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.NoError(err)
+	})
+
+	t.Run("Purchase Getme Error Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, errors.New("error get me"))
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.Error(err)
+	})
+
+	t.Run("already purchase Error Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:      "123",
+			Email:   "test@mail.com",
+			Package: []string{"123"},
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.Error(err)
+	})
+
+	t.Run("package not found Error Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{}, errors.New("package not found"))
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.Error(err)
+	})
+
+	t.Run("Archived Package Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		archivedAt := time.Now()
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{ID: packages, ArchivedAt: &archivedAt}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		entitlementMock.AssertNotCalled(t, "CreateEntitlement", mock.Anything)
+	})
+
+	t.Run("Create Entitlement Error Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{ID: packages}, nil)
+		entitlementMock.On("ExpireLapsedEntitlements", mock.Anything, mock.Anything, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{}, nil)
+		entitlementMock.On("CreateEntitlement", mock.AnythingOfType("user.Entitlement")).Return(businessUser.Entitlement{}, errors.New("error create entitlement"))
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+		paymentMock.On("CreatePayment", mock.AnythingOfType("user.Payment")).Return(businessUser.Payment{ID: "789", UserID: user.ID, Status: businessUser.PaymentPending}, nil)
+		paymentMock.On("UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentFailed, mock.AnythingOfType("time.Time")).Return(true, nil)
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.Error(err)
+		paymentMock.AssertCalled(t, "UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentFailed, mock.AnythingOfType("time.Time"))
+	})
+
+	t.Run("Paid Package Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{
+			ID:            packages,
+			PackageName:   "Premium",
+			BillingPeriod: businessUser.BillingMonthly,
+			Prices: []businessUser.PackagePrice{
+				{Currency: "USD", Amount: 999},
+				{Currency: "IDR", Amount: 150000},
+			},
+		}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+		paymentMock.On("CreatePayment", mock.MatchedBy(func(data businessUser.Payment) bool {
+			return data.UserID == user.ID && data.PackageID == packages &&
+				data.Status == businessUser.PaymentPending &&
+				data.Amount == 150000 && data.Currency == "IDR" &&
+				data.Package.PackageName == "Premium" && data.Package.BillingPeriod == businessUser.BillingMonthly
+		})).Return(businessUser.Payment{ID: "789", UserID: user.ID, PackageID: packages, Status: businessUser.PaymentPending, Amount: 150000, Currency: "IDR"}, nil)
+		paymentMock.On("SetPaymentReference", "789", mock.AnythingOfType("string")).Return(nil)
+
+		res, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages, Currency: "idr"})
+		asserting.NoError(err)
+		asserting.NotNil(res.Payment)
+		asserting.NotEmpty(res.Payment.Reference)
+		asserting.Contains(res.CheckoutURL, res.Payment.Reference)
+		// the package is granted once the payment is confirmed
+		entitlementMock.AssertNotCalled(t, "CreateEntitlement", mock.Anything)
+	})
+
+	t.Run("Payments Not Available Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{
+			ID:     packages,
+			Prices: []businessUser.PackagePrice{{Currency: "USD", Amount: 999}},
+		}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages})
+		asserting.Error(err)
+		asserting.Equal(503, utils.GetStatusCode(err))
+		entitlementMock.AssertNotCalled(t, "CreateEntitlement", mock.Anything)
+	})
+
+	t.Run("Currency Not Sold Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		packages := "123"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", packages).Return(businessUser.Package{
+			ID:     packages,
+			Prices: []businessUser.PackagePrice{{Currency: "USD", Amount: 999}},
+		}, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+
+		_, err := service.PurchasePackage(user.ID, businessUser.Purchase{ID: packages, Currency: "EUR"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		paymentMock.AssertNotCalled(t, "CreatePayment", mock.Anything)
+	})
+}
+
+func TestPurchasePackagePromoCode(t *testing.T) {
+	pack := businessUser.Package{
+		ID:            "456",
+		PackageName:   "Premium",
+		BillingPeriod: businessUser.BillingMonthly,
+		Prices:        []businessUser.PackagePrice{{Currency: "USD", Amount: 1000}},
+	}
+	percentOff := businessUser.PromoCode{ID: "p1", Code: "SAVE20", DiscountType: businessUser.PromoPercent, PercentOff: 20}
+
+	t.Run("Percent Promo Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", "456").Return(pack, nil)
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123"}, nil)
+		promoMock.On("FindPromoCodeByCode", "SAVE20").Return(percentOff, nil)
+		paymentMock.On("CreatePayment", mock.MatchedBy(func(data businessUser.Payment) bool {
+			return data.Amount == 800 && data.Discount == 200 && data.PromoCode == "SAVE20"
+		})).Return(businessUser.Payment{ID: "789", UserID: "123", PackageID: "456", Status: businessUser.PaymentPending, Amount: 800, Currency: "USD", PromoCode: "SAVE20", Discount: 200}, nil)
+		promoMock.On("RedeemPromoCode", percentOff, mock.MatchedBy(func(data businessUser.PromoRedemption) bool {
+			return data.PromoCodeID == percentOff.ID && data.UserID == "123" && data.PaymentID == "789" &&
+				data.Discount == 200 && data.Currency == "USD"
+		})).Return(nil)
+		paymentMock.On("SetPaymentReference", "789", mock.AnythingOfType("string")).Return(nil)
+
+		res, err := service.PurchasePackage("123", businessUser.Purchase{ID: "456", PromoCode: " save20 "})
+		asserting.NoError(err)
+		asserting.Equal(int64(800), res.Payment.Amount)
+		promoMock.AssertNumberOfCalls(t, "RedeemPromoCode", 1)
+	})
+
+	t.Run("Full Discount Promo Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", "456").Return(pack, nil)
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123"}, nil)
+		fixed := businessUser.PromoCode{ID: "p2", Code: "FREEMONTH", DiscountType: businessUser.PromoFixed, AmountOff: 5000, Currency: "USD"}
+		promoMock.On("FindPromoCodeByCode", "FREEMONTH").Return(fixed, nil)
+		paymentMock.On("CreatePayment", mock.MatchedBy(func(data businessUser.Payment) bool {
+			return data.Amount == 0 && data.Discount == 1000
+		})).Return(businessUser.Payment{ID: "789", UserID: "123", PackageID: "456", Status: businessUser.PaymentPending, Currency: "USD", PromoCode: "FREEMONTH", Discount: 1000}, nil)
+		promoMock.On("RedeemPromoCode", fixed, mock.AnythingOfType("user.PromoRedemption")).Return(nil)
+		entitlementMock.On("ExpireLapsedEntitlements", mock.Anything, mock.Anything, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{}, nil)
+		entitlementMock.On("CreateEntitlement", mock.AnythingOfType("user.Entitlement")).Return(businessUser.Entitlement{ID: "1"}, nil)
+		paymentMock.On("UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(true, nil)
+		promoMock.On("ConfirmPromoRedemption", "789").Return(nil)
+
+		// nothing is left to pay, the package is granted without a checkout
+		res, err := service.PurchasePackage("123", businessUser.Purchase{ID: "456", PromoCode: "FREEMONTH"})
+		asserting.NoError(err)
+		asserting.Nil(res.Payment)
+		entitlementMock.AssertNumberOfCalls(t, "CreateEntitlement", 1)
+		promoMock.AssertCalled(t, "ConfirmPromoRedemption", "789")
+	})
+
+	t.Run("Promo Code Fully Redeemed Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", "456").Return(pack, nil)
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123"}, nil)
+		promoMock.On("FindPromoCodeByCode", "SAVE20").Return(percentOff, nil)
+		paymentMock.On("CreatePayment", mock.AnythingOfType("user.Payment")).Return(businessUser.Payment{ID: "789", UserID: "123", PackageID: "456", Status: businessUser.PaymentPending, Amount: 800, Currency: "USD", PromoCode: "SAVE20", Discount: 200}, nil)
+		promoMock.On("RedeemPromoCode", percentOff, mock.AnythingOfType("user.PromoRedemption")).Return(utils.HandleError(400, "promo code fully redeemed"))
+		paymentMock.On("UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentFailed, mock.AnythingOfType("time.Time")).Return(true, nil)
+
+		_, err := service.PurchasePackage("123", businessUser.Purchase{ID: "456", PromoCode: "SAVE20"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		paymentMock.AssertCalled(t, "UpdatePaymentStatus", "789", businessUser.PaymentPending, businessUser.PaymentFailed, mock.AnythingOfType("time.Time"))
+		paymentMock.AssertNotCalled(t, "SetPaymentReference", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Expired Promo Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", "456").Return(pack, nil)
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123"}, nil)
+		expired := percentOff
+		endsAt := time.Now().Add(-time.Hour)
+		expired.EndsAt = &endsAt
+		promoMock.On("FindPromoCodeByCode", "SAVE20").Return(expired, nil)
+
+		_, err := service.PurchasePackage("123", businessUser.Purchase{ID: "456", PromoCode: "SAVE20"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		paymentMock.AssertNotCalled(t, "CreatePayment", mock.Anything)
+	})
+
+	t.Run("Promo Code Other Package Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", "456").Return(pack, nil)
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123"}, nil)
+		restricted := percentOff
+		restricted.PackageIDs = []string{"999"}
+		promoMock.On("FindPromoCodeByCode", "SAVE20").Return(restricted, nil)
+
+		_, err := service.PurchasePackage("123", businessUser.Purchase{ID: "456", PromoCode: "SAVE20"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		paymentMock.AssertNotCalled(t, "CreatePayment", mock.Anything)
+	})
+
+	t.Run("Promo Code Other Currency Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", "456").Return(pack, nil)
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123"}, nil)
+		fixed := businessUser.PromoCode{ID: "p2", Code: "IDR50K", DiscountType: businessUser.PromoFixed, AmountOff: 5000000, Currency: "IDR"}
+		promoMock.On("FindPromoCodeByCode", "IDR50K").Return(fixed, nil)
+
+		_, err := service.PurchasePackage("123", businessUser.Purchase{ID: "456", Currency: "USD", PromoCode: "IDR50K"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		paymentMock.AssertNotCalled(t, "CreatePayment", mock.Anything)
+	})
+
+	t.Run("Unknown Promo Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", "456").Return(pack, nil)
+		repoMock.On("GetMe", "123").Return(businessUser.User{ID: "123"}, nil)
+		promoMock.On("FindPromoCodeByCode", "NOPE").Return(businessUser.PromoCode{}, utils.HandleError(404, "promo code not found"))
+
+		_, err := service.PurchasePackage("123", businessUser.Purchase{ID: "456", PromoCode: "nope"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+}
+
+func TestCreatePromoCode(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		input := businessUser.PromoCodeInput{
+			Code:           "launch10",
+			DiscountType:   businessUser.PromoPercent,
+			PercentOff:     10,
+			AmountOff:      500,
+			MaxRedemptions: 100,
+			MaxPerUser:     1,
+			PackageIDs:     []string{"456"},
+		}
+		repoMock.On("GetPackageByID", "456").Return(businessUser.Package{ID: "456"}, nil)
+		promoMock.On("CreatePromoCode", mock.MatchedBy(func(data businessUser.PromoCode) bool {
+			// the amount of a percentage code is dropped
+			return data.Code == "LAUNCH10" && data.PercentOff == 10 && data.AmountOff == 0 &&
+				data.MaxRedemptions == 100 && data.MaxPerUser == 1
+		})).Return(businessUser.PromoCode{ID: "p1", Code: "LAUNCH10"}, nil)
+
+		res, err := service.CreatePromoCode(input)
+		asserting.NoError(err)
+		asserting.Equal("LAUNCH10", res.Code)
+	})
+
+	t.Run("Fixed Without Currency Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		_, err := service.CreatePromoCode(businessUser.PromoCodeInput{
+			Code:         "TENOFF",
+			DiscountType: businessUser.PromoFixed,
+			AmountOff:    1000,
+		})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		promoMock.AssertNotCalled(t, "CreatePromoCode", mock.Anything)
+	})
+
+	t.Run("Ends Before Starts Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		startsAt := time.Now()
+		endsAt := startsAt.Add(-time.Hour)
+		_, err := service.CreatePromoCode(businessUser.PromoCodeInput{
+			Code:         "LAUNCH10",
+			DiscountType: businessUser.PromoPercent,
+			PercentOff:   10,
+			StartsAt:     &startsAt,
+			EndsAt:       &endsAt,
+		})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		promoMock.AssertNotCalled(t, "CreatePromoCode", mock.Anything)
+	})
+
+	t.Run("Unknown Package Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		repoMock.On("GetPackageByID", "999").Return(businessUser.Package{}, errors.New("package not found"))
+
+		_, err := service.CreatePromoCode(businessUser.PromoCodeInput{
+			Code:         "LAUNCH10",
+			DiscountType: businessUser.PromoPercent,
+			PercentOff:   10,
+			PackageIDs:   []string{"999"},
+		})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		promoMock.AssertNotCalled(t, "CreatePromoCode", mock.Anything)
+	})
+}
+
+func TestHandlePaymentWebhook(t *testing.T) {
+	payment := businessUser.Payment{
+		ID:        "789",
+		UserID:    "123",
+		PackageID: "456",
+		Status:    businessUser.PaymentPending,
+		Amount:    999,
+		Currency:  "USD",
+		Reference: "fake_abc",
+	}
+
+	t.Run("Paid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(payment, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(true, nil)
+		repoMock.On("GetPackageByID", payment.PackageID).Return(businessUser.Package{ID: payment.PackageID, BillingPeriod: businessUser.BillingMonthly}, nil)
+		entitlementMock.On("ExpireLapsedEntitlements", mock.Anything, mock.Anything, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{}, nil)
+		entitlementMock.On("CreateEntitlement", mock.MatchedBy(func(data businessUser.Entitlement) bool {
+			return data.UserID == payment.UserID && data.PackageID == payment.PackageID &&
+				data.PaymentID == payment.ID && data.EndsAt != nil
+		})).Return(businessUser.Entitlement{ID: "1"}, nil)
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentPaid, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.NoError(err)
+		entitlementMock.AssertNumberOfCalls(t, "CreateEntitlement", 1)
+	})
+
+	t.Run("Repeated Paid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		paid := payment
+		paid.Status = businessUser.PaymentPaid
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(paid, nil)
+		// an earlier delivery already moved it to paid
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(false, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentExpired, businessUser.PaymentRefunded, mock.AnythingOfType("time.Time")).Return(false, nil)
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentPaid, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.NoError(err)
+		entitlementMock.AssertNotCalled(t, "CreateEntitlement", mock.Anything)
+	})
+
+	t.Run("Paid With Promo Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		promoPayment := payment
+		promoPayment.PromoCode = "SAVE20"
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(promoPayment, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(true, nil)
+		repoMock.On("GetPackageByID", payment.PackageID).Return(businessUser.Package{ID: payment.PackageID, BillingPeriod: businessUser.BillingMonthly}, nil)
+		entitlementMock.On("ExpireLapsedEntitlements", mock.Anything, mock.Anything, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{}, nil)
+		entitlementMock.On("CreateEntitlement", mock.AnythingOfType("user.Entitlement")).Return(businessUser.Entitlement{ID: "1"}, nil)
+		promoMock.On("ConfirmPromoRedemption", payment.ID).Return(nil)
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentPaid, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.NoError(err)
+		// the reserved redemption is kept for good
+		promoMock.AssertCalled(t, "ConfirmPromoRedemption", payment.ID)
+	})
+
+	t.Run("Paid After Expiry Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		expired := payment
+		expired.Status = businessUser.PaymentExpired
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(expired, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(false, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentExpired, businessUser.PaymentRefunded, mock.AnythingOfType("time.Time")).Return(true, nil)
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentPaid, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.NoError(err)
+		// the checkout expired and its promo code was given back, the money
+		// goes back too
+		asserting.Equal([]string{payment.Reference}, provider.Refunds())
+		entitlementMock.AssertNotCalled(t, "CreateEntitlement", mock.Anything)
+	})
+
+	t.Run("Invalid Signature Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+
+		forged := newPaymentProvider(t, "other")
+		payload, signature, err := forged.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentPaid, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		paymentMock.AssertNotCalled(t, "FindPaymentByReference", mock.Anything)
+	})
+
+	t.Run("Amount Mismatch Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(payment, nil)
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentPaid, Amount: 1, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		paymentMock.AssertNotCalled(t, "UpdatePaymentStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		entitlementMock.AssertNotCalled(t, "CreateEntitlement", mock.Anything)
+	})
+
+	t.Run("Grant Error Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(payment, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(true, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPaid, businessUser.PaymentPending, mock.AnythingOfType("time.Time")).Return(true, nil)
+		repoMock.On("GetPackageByID", payment.PackageID).Return(businessUser.Package{ID: payment.PackageID}, nil)
+		entitlementMock.On("ExpireLapsedEntitlements", mock.Anything, mock.Anything, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{}, nil)
+		entitlementMock.On("CreateEntitlement", mock.AnythingOfType("user.Entitlement")).Return(businessUser.Entitlement{}, errors.New("error create entitlement"))
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentPaid, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.Error(err)
+		// back to pending so the provider retry grants it
+		paymentMock.AssertCalled(t, "UpdatePaymentStatus", payment.ID, businessUser.PaymentPaid, businessUser.PaymentPending, mock.AnythingOfType("time.Time"))
+	})
+
+	t.Run("Package Already Held Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(payment, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPending, businessUser.PaymentPaid, mock.AnythingOfType("time.Time")).Return(true, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPaid, businessUser.PaymentRefunded, mock.AnythingOfType("time.Time")).Return(true, nil)
+		repoMock.On("GetPackageByID", payment.PackageID).Return(businessUser.Package{ID: payment.PackageID}, nil)
+		// a second checkout of the package was paid as well
+		entitlementMock.On("ExpireLapsedEntitlements", mock.Anything, mock.Anything, mock.AnythingOfType("time.Time")).Return([]businessUser.Entitlement{}, nil)
+		entitlementMock.On("CreateEntitlement", mock.AnythingOfType("user.Entitlement")).Return(businessUser.Entitlement{}, utils.HandleError(400, "already purchase package"))
+		entitlementMock.On("RevokeEntitlement", payment.ID, mock.AnythingOfType("time.Time")).Return(nil)
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentPaid, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.NoError(err)
+		asserting.Equal([]string{payment.Reference}, provider.Refunds())
+		paymentMock.AssertCalled(t, "UpdatePaymentStatus", payment.ID, businessUser.PaymentPaid, businessUser.PaymentRefunded, mock.AnythingOfType("time.Time"))
+	})
+
+	t.Run("Failed Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(payment, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPending, businessUser.PaymentFailed, mock.AnythingOfType("time.Time")).Return(true, nil)
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentFailed, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.NoError(err)
+		entitlementMock.AssertNotCalled(t, "CreateEntitlement", mock.Anything)
+	})
+
+	t.Run("Failed With Promo Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		promoPayment := payment
+		promoPayment.PromoCode = "SAVE20"
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(promoPayment, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPending, businessUser.PaymentFailed, mock.AnythingOfType("time.Time")).Return(true, nil)
+		promoMock.On("ReleasePromoRedemption", payment.ID).Return(nil)
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentFailed, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.NoError(err)
+		// the code can be used again
+		promoMock.AssertNumberOfCalls(t, "ReleasePromoRedemption", 1)
+	})
+
+	t.Run("Error Release Promo Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		promoPayment := payment
+		promoPayment.PromoCode = "SAVE20"
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(promoPayment, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPending, businessUser.PaymentFailed, mock.AnythingOfType("time.Time")).Return(true, nil)
+		promoMock.On("ReleasePromoRedemption", payment.ID).Return(errors.New("error release promo code"))
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentFailed, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.Error(err)
+		// the provider retries the webhook
+		asserting.Equal(500, utils.GetStatusCode(err))
+	})
+
+	t.Run("Retry Releases Promo Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		promoPayment := payment
+		promoPayment.PromoCode = "SAVE20"
+		promoPayment.Status = businessUser.PaymentFailed
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(promoPayment, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPending, businessUser.PaymentFailed, mock.AnythingOfType("time.Time")).Return(false, nil)
+		promoMock.On("ReleasePromoRedemption", payment.ID).Return(nil)
+
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentFailed, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.NoError(err)
+		// the release of the first delivery failed
+		promoMock.AssertNumberOfCalls(t, "ReleasePromoRedemption", 1)
+	})
+}
+
+func TestRefundPayment(t *testing.T) {
+	payment := businessUser.Payment{
+		ID:        "789",
+		UserID:    "123",
+		PackageID: "456",
+		Status:    businessUser.PaymentPaid,
+		Amount:    999,
+		Currency:  "USD",
+		Reference: "fake_abc",
+	}
+
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		paymentMock.On("FindPaymentByID", payment.ID).Return(payment, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPaid, businessUser.PaymentRefunded, mock.AnythingOfType("time.Time")).Return(true, nil)
+		entitlementMock.On("RevokeEntitlement", payment.ID, mock.AnythingOfType("time.Time")).Return(nil)
+
+		err := service.RefundPayment(payment.ID)
+		asserting.NoError(err)
+		asserting.Equal([]string{payment.Reference}, provider.Refunds())
+		entitlementMock.AssertNumberOfCalls(t, "RevokeEntitlement", 1)
+	})
+
+	t.Run("Not Paid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		pending := payment
+		pending.Status = businessUser.PaymentPending
+		paymentMock.On("FindPaymentByID", payment.ID).Return(pending, nil)
+
+		err := service.RefundPayment(payment.ID)
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		asserting.Empty(provider.Refunds())
+	})
+
+	t.Run("Refunded Webhook Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		provider := newPaymentProvider(t, "secret")
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, provider, nil, nil, &config.AppConfig{})
+		paymentMock.On("FindPaymentByReference", payment.Reference).Return(payment, nil)
+		paymentMock.On("UpdatePaymentStatus", payment.ID, businessUser.PaymentPaid, businessUser.PaymentRefunded, mock.AnythingOfType("time.Time")).Return(true, nil)
+		entitlementMock.On("RevokeEntitlement", payment.ID, mock.AnythingOfType("time.Time")).Return(nil)
+
+		// refunded from the dashboard of the provider
+		payload, signature, err := provider.Webhook(businessUser.PaymentEvent{Reference: payment.Reference, Status: businessUser.PaymentRefunded, Amount: 999, Currency: "USD"})
+		asserting.NoError(err)
+		err = service.HandlePaymentWebhook(payload, signature)
+		asserting.NoError(err)
+		entitlementMock.AssertNumberOfCalls(t, "RevokeEntitlement", 1)
+	})
+}
+
+func TestGetPurchaseHistory(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		purchases := []businessUser.Payment{{ID: "789", PackageID: "456", Status: businessUser.PaymentPaid, Amount: 999, Currency: "USD"}}
+		// the page defaults to the first one
+		paymentMock.On("GetPaymentsByUser", "123", businessUser.Pagination{Page: 1, Limit: 10}).Return(purchases, int64(1), nil)
+
+		res, err := service.GetPurchaseHistory("123", businessUser.Pagination{})
+		asserting.NoError(err)
+		asserting.Equal(purchases, res.Purchases)
+		asserting.Equal(int64(1), res.Total)
+	})
+
+	t.Run("Repository Error Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		paymentMock.On("GetPaymentsByUser", "123", businessUser.Pagination{Page: 2, Limit: 50}).Return([]businessUser.Payment{}, int64(0), errors.New("error get payments"))
+
+		_, err := service.GetPurchaseHistory("123", businessUser.Pagination{Page: 2, Limit: 100})
+		asserting.Error(err)
+		asserting.Equal(500, utils.GetStatusCode(err))
+	})
+}
+
+func TestGetInvoice(t *testing.T) {
+	user := businessUser.User{
+		ID:       "123",
+		FullName: "Jane <Doe>",
+		Email:    "test@mail.com",
+	}
+	payment := businessUser.Payment{
+		ID:        "789",
+		UserID:    user.ID,
+		PackageID: "456",
+		Package: businessUser.PackageSnapshot{
+			PackageName:   "Premium",
+			BillingPeriod: businessUser.BillingMonthly,
+		},
+		Status:    businessUser.PaymentPaid,
+		Amount:    999,
+		Currency:  "USD",
+		Reference: "fake_abc",
+		CreatedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		paymentMock.On("FindPaymentByID", payment.ID).Return(payment, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+
+		res, err := service.GetInvoice(user.ID, payment.ID)
+		asserting.NoError(err)
+		invoice := string(res)
+		asserting.Contains(invoice, "Premium")
+		asserting.Contains(invoice, "USD 9.99")
+		asserting.Contains(invoice, "1 October 2026")
+		asserting.Contains(invoice, "fake_abc")
+		asserting.Contains(invoice, "Jane &lt;Doe&gt;")
+		asserting.NotContains(invoice, "Promo code")
+	})
+
+	t.Run("Promo Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		discounted := payment
+		discounted.Amount = 799
+		discounted.PromoCode = "SAVE2"
+		discounted.Discount = 200
+		paymentMock.On("FindPaymentByID", payment.ID).Return(discounted, nil)
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+
+		res, err := service.GetInvoice(user.ID, payment.ID)
+		asserting.NoError(err)
+		invoice := string(res)
+		asserting.Contains(invoice, "Promo code SAVE2")
+		asserting.Contains(invoice, "USD 9.99")
+		asserting.Contains(invoice, "-USD 2.00")
+		asserting.Contains(invoice, "USD 7.99")
+	})
+
+	t.Run("Other User Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		paymentMock.On("FindPaymentByID", payment.ID).Return(payment, nil)
+
+		_, err := service.GetInvoice("999", payment.ID)
+		asserting.Error(err)
+		asserting.Equal(404, utils.GetStatusCode(err))
+	})
+
+	t.Run("Pending Payment Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		pending := payment
+		pending.Status = businessUser.PaymentPending
+		paymentMock.On("FindPaymentByID", payment.ID).Return(pending, nil)
+
+		_, err := service.GetInvoice(user.ID, payment.ID)
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+}
+
+func TestExpirePendingPayments(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		expired := []businessUser.Payment{
+			{ID: "1", UserID: "123", Status: businessUser.PaymentExpired, PromoCode: "SAVE20"},
+			{ID: "2", UserID: "456", Status: businessUser.PaymentExpired},
+		}
+		paymentMock.On("ExpirePendingPayments", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), mock.AnythingOfType("int")).Return(expired, nil)
+		promoMock.On("ReleasePromoRedemption", "1").Return(nil)
+
+		count, err := service.ExpirePendingPayments()
+		asserting.NoError(err)
+		asserting.Equal(2, count)
+		// only the payment with a promo code held a redemption
+		promoMock.AssertNumberOfCalls(t, "ReleasePromoRedemption", 1)
+		before := paymentMock.Calls[0].Arguments.Get(0).(time.Time)
+		at := paymentMock.Calls[0].Arguments.Get(1).(time.Time)
+		asserting.Equal(24*time.Hour, at.Sub(before))
+	})
+
+	t.Run("Error Release Promo Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		expired := []businessUser.Payment{{ID: "1", UserID: "123", Status: businessUser.PaymentExpired, PromoCode: "SAVE20"}}
+		paymentMock.On("ExpirePendingPayments", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), mock.AnythingOfType("int")).Return(expired, nil)
+		promoMock.On("ReleasePromoRedemption", "1").Return(errors.New("database down"))
+		paymentMock.On("UpdatePaymentStatus", "1", businessUser.PaymentExpired, businessUser.PaymentPending, mock.AnythingOfType("time.Time")).Return(true, nil)
+
+		_, err := service.ExpirePendingPayments()
+		asserting.Error(err)
+		// back to pending, the next run releases it again
+		paymentMock.AssertCalled(t, "UpdatePaymentStatus", "1", businessUser.PaymentExpired, businessUser.PaymentPending, mock.AnythingOfType("time.Time"))
+	})
+}
+
+func TestExpireEntitlements(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		expired := []businessUser.Entitlement{
+			{ID: "1", UserID: "123", PackageID: "p1", Status: businessUser.EntitlementExpired},
+			{ID: "2", UserID: "456", PackageID: "p2", Status: businessUser.EntitlementExpired},
+		}
+		entitlementMock.On("ExpireEntitlements", mock.AnythingOfType("time.Time"), mock.AnythingOfType("int")).Return(expired, nil)
+		auditMock.On("CreateAuditEvent", mock.MatchedBy(func(event businessUser.AuditEvent) bool {
+			return event.Type == businessUser.AuditEntitlementExpired
+		})).Return(nil)
+
+		count, err := service.ExpireEntitlements()
+		asserting.NoError(err)
+		asserting.Equal(2, count)
+		auditMock.AssertNumberOfCalls(t, "CreateAuditEvent", 2)
+	})
+
+	t.Run("Repository Error Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		// the one expired before the error still gets its event
+		expired := []businessUser.Entitlement{{ID: "1", UserID: "123", PackageID: "p1"}}
+		entitlementMock.On("ExpireEntitlements", mock.AnythingOfType("time.Time"), mock.AnythingOfType("int")).Return(expired, errors.New("connection lost"))
+		auditMock.On("CreateAuditEvent", mock.AnythingOfType("user.AuditEvent")).Return(nil)
+
+		count, err := service.ExpireEntitlements()
+		asserting.Error(err)
+		asserting.Equal(1, count)
+		auditMock.AssertNumberOfCalls(t, "CreateAuditEvent", 1)
+	})
+}
+
+func TestGetListPackage(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		packages := []businessUser.Package{
+			{
+				ID:          "123",
+				PackageName: "test",
+				Description: "test",
+			},
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetListPackage").Return(packages, nil)
+
+		res, err := service.GetListPackage(businessUser.PackageQuery{})
+		asserting.NoError(err)
+		asserting.NotNil(res)
+		asserting.Equal(businessUser.BillingOneOff, res[0].BillingPeriod)
+		asserting.Equal("test", res[0].DisplayName)
+	})
+
+	t.Run("Currency And Language Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		priced := func() []businessUser.Package {
+			return []businessUser.Package{
+				{
+					ID:            "123",
+					PackageName:   "premium",
+					BillingPeriod: businessUser.BillingMonthly,
+					Prices: []businessUser.PackagePrice{
+						{Currency: "USD", Amount: 999},
+						{Currency: "IDR", Amount: 149000},
+					},
+					DisplayNames: map[string]string{"id": "premium id", "en-GB": "premium gb"},
+				},
+			}
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetListPackage").Return(priced(), nil).Once()
+
+		res, err := service.GetListPackage(businessUser.PackageQuery{Currency: "idr", Language: "id-ID"})
+		asserting.NoError(err)
+		asserting.Equal(&businessUser.PackagePrice{Currency: "IDR", Amount: 149000}, res[0].Price)
+		asserting.Equal("premium id", res[0].DisplayName)
+		asserting.Nil(res[0].Prices)
+
+		// a currency without a price falls back to the first price
+		repoMock.On("GetListPackage").Return(priced(), nil).Once()
+		res, err = service.GetListPackage(businessUser.PackageQuery{Currency: "EUR", Language: "en-GB"})
+		asserting.NoError(err)
+		asserting.Equal(&businessUser.PackagePrice{Currency: "USD", Amount: 999}, res[0].Price)
+		asserting.Equal("premium gb", res[0].DisplayName)
+	})
+
+	t.Run("Invalid Currency Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		_, err := service.GetListPackage(businessUser.PackageQuery{Currency: "XYZ1"})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "GetListPackage")
+	})
+}
+
+func TestGetMe(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("GetMe", user.ID).Return(user, nil)
+
+		res, err := service.GetMe(user.ID)
+		asserting.NoError(err)
+
+		asserting.NotNil(res)
+	})
+}
+
+func TestGetPackageByID(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		packages := businessUser.Package{
 			ID:          "123",
 			PackageName: "test",
 			Description: "test",
 		}
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
 		repoMock.On("GetPackageByID", packages.ID).Return(packages, nil)
 
-		res, err := service.GetPackageByID(packages.ID)
-		asserting.NoError(err)
-		asserting.NotNil(res)
+		res, err := service.GetPackageByID(packages.ID)
+		asserting.NoError(err)
+		asserting.NotNil(res)
+	})
+}
+
+func TestCreatePackage(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		input := businessUser.PackageInput{
+			PackageName:   "premium",
+			Description:   "unlimited swipes",
+			Features:      []string{"unlimited_swipes"},
+			BillingPeriod: businessUser.BillingMonthly,
+			TrialDays:     7,
+			Prices:        []businessUser.PackagePrice{{Currency: "USD", Amount: 999}},
+			DisplayNames:  map[string]string{"id": "premium"},
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("CreatePackage", input).Return(businessUser.Package{ID: "123", PackageName: input.PackageName, Position: 2}, nil)
+
+		res, err := service.CreatePackage(input)
+		asserting.NoError(err)
+		asserting.Equal("123", res.ID)
+	})
+
+	t.Run("Validation Error Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		_, err := service.CreatePackage(businessUser.PackageInput{PackageName: "premium", Features: []string{""}})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "CreatePackage", mock.Anything)
+	})
+
+	t.Run("Negative Trial Days Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		_, err := service.CreatePackage(businessUser.PackageInput{
+			PackageName:   "premium",
+			Description:   "unlimited swipes",
+			BillingPeriod: businessUser.BillingMonthly,
+			TrialDays:     -1,
+			Prices:        []businessUser.PackagePrice{{Currency: "USD", Amount: 999}},
+		})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "CreatePackage", mock.Anything)
+	})
+
+	t.Run("Invalid Prices Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		_, err := service.CreatePackage(businessUser.PackageInput{
+			PackageName:   "premium",
+			Description:   "unlimited swipes",
+			BillingPeriod: businessUser.BillingYearly,
+			// usd is uppercased and then priced twice
+			Prices: []businessUser.PackagePrice{{Currency: "USD", Amount: 999}, {Currency: "usd", Amount: 899}},
+		})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "CreatePackage", mock.Anything)
+	})
+
+	t.Run("Unknown Feature Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		_, err := service.CreatePackage(businessUser.PackageInput{
+			PackageName:   "premium",
+			Description:   "unlimited swipes",
+			Features:      []string{"premium"},
+			BillingPeriod: businessUser.BillingMonthly,
+			Prices:        []businessUser.PackagePrice{{Currency: "USD", Amount: 999}},
+		})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "CreatePackage", mock.Anything)
+	})
+}
+
+func TestUpdatePackage(t *testing.T) {
+	input := businessUser.PackageInput{
+		PackageName:   "premium",
+		Description:   "unlimited swipes",
+		BillingPeriod: businessUser.BillingOneOff,
+		Prices:        []businessUser.PackagePrice{{Currency: "USD", Amount: 1999}},
+	}
+
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("UpdatePackage", "123", input).Return(nil)
+		repoMock.On("GetPackageByID", "123").Return(businessUser.Package{ID: "123", PackageName: input.PackageName}, nil)
+
+		res, err := service.UpdatePackage("123", input)
+		asserting.NoError(err)
+		asserting.Equal(input.PackageName, res.PackageName)
+	})
+
+	t.Run("Not Found Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("UpdatePackage", "123", input).Return(utils.HandleError(404, "package not found"))
+
+		_, err := service.UpdatePackage("123", input)
+		asserting.Error(err)
+		asserting.Equal(404, utils.GetStatusCode(err))
+	})
+}
+
+func TestArchivePackage(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("ArchivePackage", "123", mock.AnythingOfType("time.Time")).Return(nil)
+
+		err := service.ArchivePackage("123")
+		asserting.NoError(err)
+	})
+}
+
+func TestReorderPackages(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		ids := []string{"2", "1", "3"}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("ReorderPackages", ids).Return(nil)
+
+		err := service.ReorderPackages(businessUser.ReorderPackages{IDs: ids})
+		asserting.NoError(err)
+	})
+
+	t.Run("Duplicate ID Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		err := service.ReorderPackages(businessUser.ReorderPackages{IDs: []string{"1", "1"}})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "ReorderPackages", mock.Anything)
 	})
 }
 
@@ -673,11 +3914,20 @@ func TestGetRandomUser(t *testing.T) {
 		}
 
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		swipeMock.On("GetSwipedUserIDs", user.ID).Return([]string{}, nil)
+		matchMock.On("GetUnmatchedUserIDs", user.ID).Return([]string{}, nil)
 		repoMock.On("GetRandomUser", mock.Anything).Return(res, nil)
 		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomuser:123").Return("123", nil)
-		repoMock.On("Set", "apptinder:allrandomuser:123", mock.Anything, mock.Anything).Return(nil)
 
 		result, err := service.GetRandomUser(user.ID)
 		asserting.NoError(err)
@@ -699,16 +3949,25 @@ func TestGetRandomUser(t *testing.T) {
 		}
 
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		swipeMock.On("GetSwipedUserIDs", user.ID).Return([]string{}, nil)
+		matchMock.On("GetUnmatchedUserIDs", user.ID).Return([]string{}, nil)
 		repoMock.On("GetRandomUser", mock.Anything).Return(res, errors.New("error get random user"))
 		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomuser:123").Return("123", nil)
-		repoMock.On("Set", "apptinder:allrandomuser:123", mock.Anything, mock.Anything).Return(nil)
 
 		_, err := service.GetRandomUser(user.ID)
 		asserting.Error(err)
 	})
-	t.Run("Only 1 from val redis", func(t *testing.T) {
+	t.Run("Swiped User Excluded Test", func(t *testing.T) {
 		asserting := assert.New(t)
 		user := businessUser.User{
 			ID:    "123",
@@ -722,18 +3981,28 @@ func TestGetRandomUser(t *testing.T) {
 			PhotoUrl: "test",
 		}
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
-		repoMock.On("GetRandomUser", mock.Anything).Return(res, nil)
-		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomuser:123").Return("", nil)
-		repoMock.On("Set", "apptinder:allrandomuser:123", mock.Anything, mock.Anything).Return(nil)
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		swipeMock.On("GetSwipedUserIDs", user.ID).Return([]string{"444", "666"}, nil)
+		matchMock.On("GetUnmatchedUserIDs", user.ID).Return([]string{}, nil)
+		repoMock.On("GetRandomUser", mock.MatchedBy(func(ids []string) bool {
+			return utils.CheckArray(ids, "444") && utils.CheckArray(ids, "666") && utils.CheckArray(ids, user.ID)
+		})).Return(res, nil)
 
 		result, err := service.GetRandomUser(user.ID)
 		asserting.NoError(err)
 		asserting.NotNil(result)
 	})
 
-	t.Run("Error set redis", func(t *testing.T) {
+	t.Run("Error Get Swiped User Test", func(t *testing.T) {
 		asserting := assert.New(t)
 		user := businessUser.User{
 			ID:    "123",
@@ -747,13 +4016,853 @@ func TestGetRandomUser(t *testing.T) {
 			PhotoUrl: "test",
 		}
 		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
-		service := businessUser.NewService(repoMock, &config.AppConfig{})
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		swipeMock.On("GetSwipedUserIDs", user.ID).Return([]string{}, errors.New("error get swiped"))
 		repoMock.On("GetRandomUser", mock.Anything).Return(res, nil)
-		repoMock.On("GetMe", user.ID).Return(user, nil)
-		repoMock.On("Get", "apptinder:allrandomuser:123").Return("", nil)
-		repoMock.On("Set", "apptinder:allrandomuser:123", mock.Anything, mock.Anything).Return(errors.New("error set redis"))
 
 		_, err := service.GetRandomUser(user.ID)
 		asserting.Error(err)
+		repoMock.AssertNotCalled(t, "GetRandomUser", mock.Anything)
+	})
+
+	t.Run("Unmatched User Excluded Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		user := businessUser.User{
+			ID:    "123",
+			Email: "test@mail.com",
+		}
+
+		res := businessUser.ResponseRandomUser{
+			ID:       "777",
+			FullName: "test",
+			Email:    "test@mail.com",
+			PhotoUrl: "test",
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		swipeMock.On("GetSwipedUserIDs", user.ID).Return([]string{}, nil)
+		matchMock.On("GetUnmatchedUserIDs", user.ID).Return([]string{"555"}, nil)
+		repoMock.On("GetRandomUser", mock.MatchedBy(func(ids []string) bool {
+			return utils.CheckArray(ids, "555") && utils.CheckArray(ids, user.ID)
+		})).Return(res, nil)
+
+		result, err := service.GetRandomUser(user.ID)
+		asserting.NoError(err)
+		asserting.Equal("777", result.ID)
+	})
+
+	t.Run("Error Get Unmatched User Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		swipeMock.On("GetSwipedUserIDs", "123").Return([]string{}, nil)
+		matchMock.On("GetUnmatchedUserIDs", "123").Return([]string{}, errors.New("error get unmatched"))
+
+		_, err := service.GetRandomUser("123")
+		asserting.Error(err)
+	})
+}
+
+func TestGetMatches(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		matches := []businessUser.ResponseMatch{
+			{
+				ID: "999",
+				User: businessUser.ResponseRandomUser{
+					ID:       "1234",
+					FullName: "test",
+				},
+			},
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		matchMock.On("GetMatches", "123", businessUser.Pagination{Page: 2, Limit: 5}).Return(matches, int64(6), nil)
+
+		res, err := service.GetMatches("123", businessUser.Pagination{Page: 2, Limit: 5})
+		asserting.NoError(err)
+		asserting.Len(res.Matches, 1)
+		asserting.Equal(int64(6), res.Total)
+	})
+
+	t.Run("Default Pagination Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		matchMock.On("GetMatches", "123", businessUser.Pagination{Page: 1, Limit: 10}).Return([]businessUser.ResponseMatch{}, int64(0), nil)
+
+		res, err := service.GetMatches("123", businessUser.Pagination{Page: 0, Limit: 0})
+		asserting.NoError(err)
+		asserting.Equal(1, res.Pagination.Page)
+		asserting.Equal(10, res.Pagination.Limit)
+	})
+
+	t.Run("Max Limit Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		matchMock.On("GetMatches", "123", businessUser.Pagination{Page: 1, Limit: 50}).Return([]businessUser.ResponseMatch{}, int64(0), nil)
+
+		res, err := service.GetMatches("123", businessUser.Pagination{Page: 1, Limit: 1000})
+		asserting.NoError(err)
+		asserting.Equal(50, res.Pagination.Limit)
+	})
+
+	t.Run("Error Get Matches Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		matchMock.On("GetMatches", "123", mock.Anything).Return([]businessUser.ResponseMatch{}, int64(0), errors.New("error get matches"))
+
+		_, err := service.GetMatches("123", businessUser.Pagination{})
+		asserting.Error(err)
+	})
+}
+
+func TestUnmatch(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		match := businessUser.Match{
+			ID:      "999",
+			UserIDs: []string{"123", "1234"},
+			Status:  businessUser.MatchStatusActive,
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		matchMock.On("FindMatchByID", match.ID).Return(match, nil)
+		matchMock.On("Unmatch", match.ID, "123", mock.Anything).Return(nil)
+
+		err := service.Unmatch("123", match.ID)
+		asserting.NoError(err)
+		matchMock.AssertCalled(t, "Unmatch", match.ID, "123", mock.Anything)
+	})
+
+	t.Run("Match Not Found Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		matchMock.On("FindMatchByID", "999").Return(businessUser.Match{}, utils.HandleError(404, "match not found"))
+
+		err := service.Unmatch("123", "999")
+		asserting.Error(err)
+		asserting.Equal(404, utils.GetStatusCode(err))
+	})
+
+	t.Run("Not Member Of Match Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		match := businessUser.Match{
+			ID:      "999",
+			UserIDs: []string{"1234", "4321"},
+			Status:  businessUser.MatchStatusActive,
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		matchMock.On("FindMatchByID", match.ID).Return(match, nil)
+
+		err := service.Unmatch("123", match.ID)
+		asserting.Error(err)
+		asserting.Equal(404, utils.GetStatusCode(err))
+		matchMock.AssertNotCalled(t, "Unmatch", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Already Unmatched Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		match := businessUser.Match{
+			ID:      "999",
+			UserIDs: []string{"123", "1234"},
+			Status:  businessUser.MatchStatusUnmatched,
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		matchMock.On("FindMatchByID", match.ID).Return(match, nil)
+
+		err := service.Unmatch("123", match.ID)
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+
+	t.Run("Error Unmatch Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		match := businessUser.Match{
+			ID:      "999",
+			UserIDs: []string{"123", "1234"},
+			Status:  businessUser.MatchStatusActive,
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		matchMock.On("FindMatchByID", match.ID).Return(match, nil)
+		matchMock.On("Unmatch", match.ID, "123", mock.Anything).Return(errors.New("error unmatch"))
+
+		err := service.Unmatch("123", match.ID)
+		asserting.Error(err)
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	conf := &config.AppConfig{}
+	conf.Secrettoken.Token = "secret-token-for-test"
+	keys, err := utils.NewKeySet(conf.Secrettoken.Token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRefreshToken := func(jti string) string {
+		_, token, err := utils.GenerateRefreshTokenUser("123", "test@mail.com", "family", jti, 1, nil, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		rotated := &utils.Token{AccessToken: "access", RefreshToken: "refresh"}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
+		repoMock.On("Get", "apptinder:refreshfamily:family").Return("", errors.New("redis: nil"))
+		repoMock.On("GetTokenVersion", "123").Return(int64(1), nil)
+		repoMock.On("GetDel", "apptinder:refresh:jti-1").Return("123", nil)
+		repoMock.On("GenerateTokenFamily", "123", "test@mail.com", "family", []string{"user"}).Return(rotated, nil)
+		sessionMock.On("TouchSession", "family", mock.AnythingOfType("time.Time")).Return(nil)
+
+		res, err := service.RefreshToken(businessUser.RefreshToken{RefreshToken: newRefreshToken("jti-1")})
+		asserting.NoError(err)
+		asserting.Equal("access", res.AccessToken)
+		sessionMock.AssertCalled(t, "TouchSession", "family", mock.AnythingOfType("time.Time"))
+	})
+
+	t.Run("Reuse Revokes Family Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
+		repoMock.On("Get", "apptinder:refreshfamily:family").Return("", errors.New("redis: nil"))
+		repoMock.On("GetTokenVersion", "123").Return(int64(1), nil)
+		repoMock.On("GetDel", "apptinder:refresh:jti-1").Return("", errors.New("redis: nil"))
+		repoMock.On("Set", "apptinder:refreshfamily:family", "revoked", mock.Anything).Return(nil)
+
+		_, err := service.RefreshToken(businessUser.RefreshToken{RefreshToken: newRefreshToken("jti-1")})
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+		repoMock.AssertCalled(t, "Set", "apptinder:refreshfamily:family", "revoked", mock.Anything)
+		repoMock.AssertNotCalled(t, "GenerateTokenFamily", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Token Version Bumped Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
+		repoMock.On("Get", "apptinder:refreshfamily:family").Return("", errors.New("redis: nil"))
+		repoMock.On("GetTokenVersion", "123").Return(int64(2), nil)
+
+		_, err := service.RefreshToken(businessUser.RefreshToken{RefreshToken: newRefreshToken("jti-1")})
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "GetDel", mock.Anything)
+	})
+
+	t.Run("Revoked Family Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
+		repoMock.On("Get", "apptinder:refreshfamily:family").Return("revoked", nil)
+
+		_, err := service.RefreshToken(businessUser.RefreshToken{RefreshToken: newRefreshToken("jti-2")})
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "GetDel", mock.Anything)
+	})
+
+	t.Run("Access Token Rejected Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		_, access, err := utils.GenerateAccessTokenUser("123", "test@mail.com", "family", "jti", 1, nil, keys)
+		asserting.NoError(err)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
+
+		_, err = service.RefreshToken(businessUser.RefreshToken{RefreshToken: access})
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+	})
+
+	t.Run("Validation Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, keys, conf)
+
+		_, err := service.RefreshToken(businessUser.RefreshToken{})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+}
+
+func TestValidateAccessToken(t *testing.T) {
+	claims := utils.JwtTokenClaimsUser{
+		Sub: "123",
+		Jti: "jti",
+		Ver: 1,
+		Exp: time.Now().Add(time.Hour).Unix(),
+	}
+
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:denylist:jti").Return("", errors.New("redis: nil"))
+		repoMock.On("GetTokenVersion", "123").Return(int64(1), nil)
+
+		err := service.ValidateAccessToken(claims)
+		asserting.NoError(err)
+	})
+
+	t.Run("Denylisted Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:denylist:jti").Return("revoked", nil)
+
+		err := service.ValidateAccessToken(claims)
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+	})
+
+	t.Run("Revoked Session Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		claims := claims
+		claims.Family = "session"
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:denylist:jti").Return("", errors.New("redis: nil"))
+		repoMock.On("Get", "apptinder:refreshfamily:session").Return("revoked", nil)
+
+		err := service.ValidateAccessToken(claims)
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "GetTokenVersion", mock.Anything)
+	})
+
+	t.Run("Old Token Version Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Get", "apptinder:denylist:jti").Return("", errors.New("redis: nil"))
+		repoMock.On("GetTokenVersion", "123").Return(int64(2), nil)
+
+		err := service.ValidateAccessToken(claims)
+		asserting.Error(err)
+		asserting.Equal(401, utils.GetStatusCode(err))
+	})
+}
+
+func TestLogout(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		claims := utils.JwtTokenClaimsUser{
+			Sub:    "123",
+			Jti:    "jti",
+			Family: "family",
+			Exp:    time.Now().Add(time.Hour).Unix(),
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Set", "apptinder:denylist:jti", "revoked", mock.MatchedBy(func(ttl time.Duration) bool {
+			return ttl > 59*time.Minute && ttl <= time.Hour
+		})).Return(nil)
+		repoMock.On("Set", "apptinder:refreshfamily:family", "revoked", mock.Anything).Return(nil)
+		sessionMock.On("RevokeSession", "family", "123", mock.AnythingOfType("time.Time")).Return(nil)
+
+		err := service.Logout(claims)
+		asserting.NoError(err)
+		repoMock.AssertNumberOfCalls(t, "Set", 2)
+		sessionMock.AssertCalled(t, "RevokeSession", "family", "123", mock.AnythingOfType("time.Time"))
+	})
+
+	t.Run("Error Set Denylist Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		claims := utils.JwtTokenClaimsUser{
+			Sub: "123",
+			Jti: "jti",
+			Exp: time.Now().Add(time.Hour).Unix(),
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("Set", "apptinder:denylist:jti", "revoked", mock.Anything).Return(errors.New("error set redis"))
+
+		err := service.Logout(claims)
+		asserting.Error(err)
+	})
+
+	t.Run("Logout All Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("IncrTokenVersion", "123").Return(nil)
+		sessionMock.On("RevokeSessions", "123", mock.AnythingOfType("time.Time")).Return(nil)
+
+		err := service.LogoutAll("123")
+		asserting.NoError(err)
+		repoMock.AssertCalled(t, "IncrTokenVersion", "123")
+		sessionMock.AssertCalled(t, "RevokeSessions", "123", mock.AnythingOfType("time.Time"))
+	})
+}
+
+func TestGetSessions(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		sessions := []businessUser.Session{
+			{ID: "phone", Device: "phone"},
+			{ID: "laptop", Device: "laptop"},
+		}
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		sessionMock.On("GetSessions", "123", mock.AnythingOfType("time.Time")).Return(sessions, nil)
+
+		res, err := service.GetSessions("123", "laptop")
+		asserting.NoError(err)
+		asserting.Len(res, 2)
+		asserting.False(res[0].Current)
+		asserting.True(res[1].Current)
+	})
+
+	t.Run("Error Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		sessionMock.On("GetSessions", "123", mock.AnythingOfType("time.Time")).Return([]businessUser.Session{}, errors.New("error get sessions"))
+
+		_, err := service.GetSessions("123", "laptop")
+		asserting.Error(err)
+		asserting.Equal(500, utils.GetStatusCode(err))
+	})
+}
+
+func TestRevokeSession(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		sessionMock.On("RevokeSession", "session", "123", mock.AnythingOfType("time.Time")).Return(nil)
+		repoMock.On("Set", "apptinder:refreshfamily:session", "revoked", mock.Anything).Return(nil)
+
+		err := service.RevokeSession("123", "session")
+		asserting.NoError(err)
+		repoMock.AssertCalled(t, "Set", "apptinder:refreshfamily:session", "revoked", mock.Anything)
+	})
+
+	t.Run("Not Owner Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		sessionMock.On("RevokeSession", "session", "123", mock.AnythingOfType("time.Time")).Return(utils.HandleError(404, "session not found"))
+
+		err := service.RevokeSession("123", "session")
+		asserting.Error(err)
+		asserting.Equal(404, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestSetRoles(t *testing.T) {
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		roles := []string{utils.RoleUser, utils.RoleAdmin}
+		repoMock.On("FindUserByID", "123").Return(businessUser.User{ID: "123", Email: "test@mail.com"}, nil)
+		repoMock.On("SetRoles", "123", roles).Return(nil)
+		auditMock.On("CreateAuditEvent", mock.MatchedBy(func(event businessUser.AuditEvent) bool {
+			return event.Type == businessUser.AuditRolesChanged && event.UserID == "123"
+		})).Return(nil)
+		repoMock.On("IncrTokenVersion", "123").Return(nil)
+		sessionMock.On("RevokeSessions", "123", mock.AnythingOfType("time.Time")).Return(nil)
+
+		err := service.SetRoles("admin-1", "123", businessUser.SetRoles{Roles: roles})
+		asserting.NoError(err)
+		// tokens carrying the old roles stop working
+		repoMock.AssertCalled(t, "IncrTokenVersion", "123")
+	})
+
+	t.Run("Unknown Role Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		err := service.SetRoles("admin-1", "123", businessUser.SetRoles{Roles: []string{"superuser"}})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "SetRoles", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Remove Own Admin Role Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+
+		err := service.SetRoles("123", "123", businessUser.SetRoles{Roles: []string{utils.RoleUser}})
+		asserting.Error(err)
+		asserting.Equal(400, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "SetRoles", mock.Anything, mock.Anything)
+	})
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	input := businessUser.BootstrapAdmin{Email: "admin@mail.com", Password: "12345678", FullName: "Admin"}
+	roles := []string{utils.RoleUser, utils.RoleAdmin}
+
+	t.Run("New Admin Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("CountUsersByRole", utils.RoleAdmin).Return(int64(0), nil)
+		repoMock.On("FindUserByEmail", "admin@mail.com").Return(businessUser.User{}, errors.New("wrong email"))
+		repoMock.On("CreateUser", mock.AnythingOfType("user.Register")).Return("123", nil)
+		repoMock.On("SetRoles", "123", roles).Return(nil)
+		repoMock.On("SetEmailVerified", "123", mock.AnythingOfType("time.Time")).Return(nil)
+
+		id, err := service.BootstrapAdmin(input)
+		asserting.NoError(err)
+		asserting.Equal("123", id)
+		repoMock.AssertCalled(t, "SetRoles", "123", roles)
+	})
+
+	t.Run("Promote Existing User Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("CountUsersByRole", utils.RoleAdmin).Return(int64(0), nil)
+		repoMock.On("FindUserByEmail", "admin@mail.com").Return(businessUser.User{ID: "123"}, nil)
+		repoMock.On("SetRoles", "123", roles).Return(nil)
+		repoMock.On("IncrTokenVersion", "123").Return(nil)
+
+		id, err := service.BootstrapAdmin(input)
+		asserting.NoError(err)
+		asserting.Equal("123", id)
+		repoMock.AssertNotCalled(t, "CreateUser", mock.Anything)
+	})
+
+	t.Run("Admin Exists Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		repoMock := &repoUser.UserMock{Mock: &mock.Mock{}}
+		swipeMock := &repoSwipe.SwipeMock{Mock: &mock.Mock{}}
+		matchMock := &repoMatch.MatchMock{Mock: &mock.Mock{}}
+		sessionMock := &repoSession.SessionMock{Mock: &mock.Mock{}}
+		mailer := repoMailer.NewMemoryMailer()
+		auditMock := &repoAudit.AuditMock{Mock: &mock.Mock{}}
+		entitlementMock := &repoEntitlement.EntitlementMock{Mock: &mock.Mock{}}
+		featureMock := &entitlementsMock{Mock: &mock.Mock{}}
+		paymentMock := &repoPayment.PaymentMock{Mock: &mock.Mock{}}
+		promoMock := &repoPromo.PromoMock{Mock: &mock.Mock{}}
+		service := businessUser.NewService(repoMock, swipeMock, matchMock, sessionMock, mailer, auditMock, entitlementMock, featureMock, paymentMock, promoMock, nil, nil, nil, &config.AppConfig{})
+		repoMock.On("CountUsersByRole", utils.RoleAdmin).Return(int64(1), nil)
+
+		_, err := service.BootstrapAdmin(input)
+		asserting.Error(err)
+		asserting.Equal(409, utils.GetStatusCode(err))
+		repoMock.AssertNotCalled(t, "SetRoles", mock.Anything, mock.Anything)
 	})
 }
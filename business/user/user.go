@@ -3,27 +3,74 @@ package user
 import (
 	"mime/multipart"
 	"roby-backend-golang/utils"
+	"time"
 )
 
 type AuthLogin struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Email     string `json:"email" validate:"required,email"`
+	Password  string `json:"password" validate:"required,min=8"`
+	Device    string `json:"device"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
+// ResponseLogin carries the token pair, or only the mfa token when the user
+// has two-factor authentication enabled and still has to send a code.
 type ResponseLogin struct {
-	Email   string      `json:"email"`
-	Package []Package   `json:"package"`
-	Token   utils.Token `json:"token"`
+	Email       string       `json:"email"`
+	Package     []Package    `json:"package"`
+	Token       *utils.Token `json:"token,omitempty"`
+	MFARequired bool         `json:"mfa_required,omitempty"`
+	MFAToken    string       `json:"mfa_token,omitempty"`
+	// ProfileIncomplete asks an account created by a social login for the
+	// fullname and photo Register would have required
+	ProfileIncomplete bool `json:"profile_incomplete,omitempty"`
+}
+
+type LoginMFA struct {
+	MFAToken  string `json:"mfa_token" validate:"required"`
+	Code      string `json:"code" validate:"required"`
+	Device    string `json:"device"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// MFACode is a code of the authenticator app, or a recovery code where the
+// endpoint accepts one.
+type MFACode struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type ResponseEnrollMFA struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type ResponseRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
 type User struct {
-	ID       string    `json:"id"`
-	FullName string    `form:"fullname" validate:"required" json:"fullname"`
-	Email    string    `form:"email" validate:"required,email" json:"email"`
-	Password string    `json:"-" form:"password" validate:"required"`
-	PhotoUrl string    `json:"photo_url"`
-	Package  []string  `json:"-" bson:"package,omitempty"`
-	Packages []Package `json:"packages"`
+	ID               string   `json:"id"`
+	FullName         string   `form:"fullname" validate:"required" json:"fullname"`
+	Email            string   `form:"email" validate:"required,email" json:"email"`
+	Password         string   `json:"-" form:"password" validate:"required"`
+	PhotoUrl         string   `json:"photo_url"`
+	EmailVerified    bool     `json:"email_verified"`
+	MFAEnabled       bool     `json:"mfa_enabled"`
+	ProfileCompleted bool     `json:"profile_completed"`
+	Roles            []string `json:"roles"`
+	MFASecret        string   `json:"-"`
+	// MFAPendingSecret is enrolled but not confirmed with a code yet
+	MFAPendingSecret string    `json:"-"`
+	Package          []string  `json:"-" bson:"package,omitempty"`
+	Packages         []Package `json:"packages"`
+	// Entitlements are the purchases the packages are granted by right now
+	Entitlements []Entitlement `json:"entitlements,omitempty"`
 }
 
 type ResponseRandomUser struct {
@@ -42,6 +89,65 @@ type Register struct {
 	PhotoUrl string                `json:"photo_url"`
 }
 
+type SetRoles struct {
+	Roles []string `json:"roles" validate:"required,min=1"`
+}
+
+// BootstrapAdmin is the first admin, created from the command line.
+type BootstrapAdmin struct {
+	Email    string `validate:"required,email"`
+	Password string `validate:"required,min=8"`
+	FullName string `validate:"required"`
+}
+
+type CompleteProfile struct {
+	FullName string                `form:"fullname" validate:"required"`
+	File     *multipart.FileHeader `form:"file"`
+}
+
+// Identity is an account of an external identity provider.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FullName      string
+	PhotoUrl      string
+	// Nonce echoes the nonce of the authorization request
+	Nonce string
+}
+
+type ResponseOAuthStart struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+type OAuthCallback struct {
+	Code      string `json:"code" query:"code" validate:"required"`
+	State     string `json:"state" query:"state" validate:"required"`
+	Device    string `json:"device" query:"device"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+type VerifyEmail struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerifyEmail struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPassword struct {
+	Email    string `json:"email" validate:"required,email"`
+	Code     string `json:"code" validate:"required,numeric"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
 type LastRandom struct {
 	LastRandom string `json:"last_random"`
 }
@@ -52,11 +158,240 @@ type SwipeUser struct {
 }
 
 type Package struct {
-	ID          string `json:"id" bson:"_id"`
-	PackageName string `json:"package_name" bson:"package_name"`
-	Description string `json:"description" bson:"description"`
+	ID          string   `json:"id" bson:"_id"`
+	PackageName string   `json:"package_name" bson:"package_name"`
+	Description string   `json:"description" bson:"description"`
+	Features    []string `json:"features" bson:"features"`
+	Position    int      `json:"position" bson:"position"`
+	// ArchivedAt hides the package from the list, holders keep it
+	ArchivedAt    *time.Time `json:"archived_at,omitempty" bson:"archived_at,omitempty"`
+	BillingPeriod string     `json:"billing_period" bson:"billing_period"`
+	// TrialDays is granted free before the first billing period starts
+	TrialDays int `json:"trial_days" bson:"trial_days"`
+	// Prices is the price table, the first price is listed when the package
+	// has no price in the currency asked for, a purchase in it is refused
+	Prices []PackagePrice `json:"prices,omitempty" bson:"prices"`
+	// DisplayNames maps a language tag to the name shown in that language
+	DisplayNames map[string]string `json:"display_names,omitempty" bson:"display_names"`
+	// Price and DisplayName are resolved for the caller by GetListPackage
+	Price       *PackagePrice `json:"price,omitempty" bson:"-"`
+	DisplayName string        `json:"display_name,omitempty" bson:"-"`
+}
+
+// PackagePrice is an amount in the minor unit of the currency, cents for USD.
+type PackagePrice struct {
+	Currency string `json:"currency" bson:"currency" validate:"required,iso4217"`
+	Amount   int64  `json:"amount" bson:"amount" validate:"min=0"`
+}
+
+// PackageInput creates a package or replaces the editable fields of one.
+type PackageInput struct {
+	PackageName   string            `json:"package_name" validate:"required,max=50"`
+	Description   string            `json:"description" validate:"required,max=500"`
+	Features      []string          `json:"features" validate:"dive,required"`
+	BillingPeriod string            `json:"billing_period" validate:"required,oneof=one_off monthly yearly"`
+	TrialDays     int               `json:"trial_days" validate:"min=0,max=365"`
+	Prices        []PackagePrice    `json:"prices" validate:"required,min=1,unique=Currency,dive"`
+	DisplayNames  map[string]string `json:"display_names" validate:"dive,keys,bcp47_language_tag,endkeys,required,max=50"`
+}
+
+// PackageQuery picks the currency and language of the package list.
+type PackageQuery struct {
+	Currency string `query:"currency" validate:"omitempty,iso4217"`
+	Language string `query:"lang"`
+}
+
+// ReorderPackages lists package ids in the order the list shows them.
+type ReorderPackages struct {
+	IDs []string `json:"ids" validate:"required,min=1,unique,dive,required"`
+}
+
+// Entitlement grants a package to a user from StartsAt until EndsAt, a
+// one-off purchase has no end.
+type Entitlement struct {
+	ID        string     `json:"id" bson:"_id"`
+	UserID    string     `json:"-" bson:"user_id"`
+	PackageID string     `json:"package_id" bson:"package_id"`
+	PaymentID string     `json:"-" bson:"payment_id,omitempty"`
+	Status    string     `json:"status" bson:"status"`
+	StartsAt  time.Time  `json:"starts_at" bson:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
 }
 
 type Purchase struct {
 	ID string `json:"id" bson:"_id"`
+	// Currency picks the price to pay, see GetListPackage
+	Currency  string `json:"currency" validate:"omitempty,iso4217"`
+	PromoCode string `json:"promo_code" validate:"omitempty,max=32"`
+}
+
+// ResponsePurchase carries the payment to complete at the checkout url, a free
+// package is granted right away and has neither.
+type ResponsePurchase struct {
+	Payment     *Payment `json:"payment,omitempty"`
+	CheckoutURL string   `json:"checkout_url,omitempty"`
+}
+
+// Payment is a purchase of a package, the package is granted once the payment
+// provider confirms it was paid.
+type Payment struct {
+	ID        string `json:"id"`
+	UserID    string `json:"-"`
+	PackageID string `json:"package_id"`
+	// Package is the package as it was sold, later edits leave it alone
+	Package  PackageSnapshot `json:"package"`
+	Status   string          `json:"status"`
+	Amount   int64           `json:"amount"`
+	Currency string          `json:"currency"`
+	// Discount was taken off the price by PromoCode, Amount is what is left
+	PromoCode string    `json:"promo_code,omitempty"`
+	Discount  int64     `json:"discount,omitempty"`
+	Reference string    `json:"reference,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PackageSnapshot struct {
+	PackageName   string   `json:"package_name"`
+	Description   string   `json:"description"`
+	Features      []string `json:"features"`
+	BillingPeriod string   `json:"billing_period"`
+}
+
+type ResponseListPurchase struct {
+	Purchases  []Payment  `json:"purchases"`
+	Pagination Pagination `json:"pagination"`
+	Total      int64      `json:"total"`
+}
+
+// Checkout is where the payment provider takes the payment.
+type Checkout struct {
+	Reference string
+	URL       string
+}
+
+// PaymentEvent is a verified webhook of the payment provider.
+type PaymentEvent struct {
+	Reference string
+	Status    string
+	Amount    int64
+	Currency  string
+}
+
+type Swipe struct {
+	ID        string    `json:"id"`
+	SwiperID  string    `json:"swiper_id"`
+	TargetID  string    `json:"target_id"`
+	Direction string    `json:"direction"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ResponseSwipe struct {
+	Match   bool   `json:"match"`
+	MatchID string `json:"match_id,omitempty"`
+}
+
+type Match struct {
+	ID          string    `json:"id"`
+	UserIDs     []string  `json:"user_ids"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UnmatchedBy string    `json:"unmatched_by,omitempty"`
+	UnmatchedAt time.Time `json:"unmatched_at,omitempty"`
+}
+
+type ResponseMatch struct {
+	ID        string             `json:"id"`
+	User      ResponseRandomUser `json:"user"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+type Pagination struct {
+	Page  int `query:"page" json:"page"`
+	Limit int `query:"limit" json:"limit"`
+}
+
+type ResponseListMatch struct {
+	Matches    []ResponseMatch `json:"matches"`
+	Pagination Pagination      `json:"pagination"`
+	Total      int64           `json:"total"`
+}
+
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"-"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type AuditEvent struct {
+	Type      string    `json:"type"`
+	UserID    string    `json:"user_id,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromoCode takes a percentage or a fixed amount off the price of a package.
+// A zero limit is no limit.
+type PromoCode struct {
+	ID           string `json:"id"`
+	Code         string `json:"code"`
+	DiscountType string `json:"discount_type"`
+	PercentOff   int    `json:"percent_off,omitempty"`
+	// AmountOff is in the minor unit of Currency, it only applies to prices
+	// in that currency
+	AmountOff      int64      `json:"amount_off,omitempty"`
+	Currency       string     `json:"currency,omitempty"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	MaxRedemptions int64      `json:"max_redemptions"`
+	MaxPerUser     int64      `json:"max_per_user"`
+	// PackageIDs restricts the code to these packages, every package when empty
+	PackageIDs  []string  `json:"package_ids"`
+	Redemptions int64     `json:"redemptions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type PromoCodeInput struct {
+	Code           string     `json:"code" validate:"required,alphanum,min=3,max=32"`
+	DiscountType   string     `json:"discount_type" validate:"required,oneof=percent fixed"`
+	PercentOff     int        `json:"percent_off" validate:"required_if=DiscountType percent,min=0,max=100"`
+	AmountOff      int64      `json:"amount_off" validate:"required_if=DiscountType fixed,min=0"`
+	Currency       string     `json:"currency" validate:"required_if=DiscountType fixed,omitempty,iso4217"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	MaxRedemptions int64      `json:"max_redemptions" validate:"min=0"`
+	MaxPerUser     int64      `json:"max_per_user" validate:"min=0"`
+	PackageIDs     []string   `json:"package_ids" validate:"unique,dive,required"`
+}
+
+// PromoRedemption is the use of a promo code by a purchase, it is reserved
+// while the payment is pending, kept once it is paid and released when it
+// fails or expires.
+type PromoRedemption struct {
+	ID          string    `json:"id"`
+	PromoCodeID string    `json:"promo_code_id"`
+	UserID      string    `json:"user_id"`
+	PaymentID   string    `json:"payment_id"`
+	Discount    int64     `json:"discount"`
+	Currency    string    `json:"currency"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PromoCodeUsage reports the redemptions of paid payments, the users and the
+// discount given. Reserved counts the redemptions of payments still pending,
+// released ones do not count.
+type PromoCodeUsage struct {
+	PromoCode   PromoCode      `json:"promo_code"`
+	Redemptions int64          `json:"redemptions"`
+	Reserved    int64          `json:"reserved"`
+	Users       int64          `json:"users"`
+	Discounts   []PackagePrice `json:"discounts"`
 }
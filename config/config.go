@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/joho/godotenv"
@@ -10,6 +12,10 @@ import (
 type AppConfig struct {
 	App struct {
 		Port string `toml:"port"`
+		// ProxyHeader carries the client ip set by the reverse proxy, it is
+		// only read from the TrustedProxies
+		ProxyHeader    string   `toml:"proxy_header"`
+		TrustedProxies []string `toml:"trusted_proxies"`
 	} `toml:"app"`
 	Database struct {
 		DBURL  string
@@ -29,11 +35,40 @@ type AppConfig struct {
 		Bucket string
 		Zone   string
 	}
+	Mail struct {
+		Host      string
+		Port      string
+		Username  string
+		Password  string
+		From      string
+		Dir       string
+		VerifyURL string
+	}
+	// Payment configures the provider packages are sold through, payments
+	// are disabled while Provider is empty
+	Payment struct {
+		Provider      string
+		WebhookSecret string
+		CheckoutURL   string
+		// AllowFake lets Provider be "fake", never set it in production
+		AllowFake bool
+	}
+	// OIDC lists the identity providers users can sign in with
+	OIDC        []OIDCProvider
 	Secrettoken struct {
-		Token string `toml:"token"`
+		Token    string `toml:"token"`
+		KeysFile string `toml:"keys_file"`
 	} `toml:"secrettoken"`
 }
 
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
 var lock = &sync.Mutex{}
 var appConfig *AppConfig
 
@@ -51,6 +86,14 @@ func GetConfig() *AppConfig {
 func initConfig() *AppConfig {
 	var finalConfig AppConfig
 	finalConfig.App.Port = "8080"
+	finalConfig.App.ProxyHeader = os.Getenv("PROXY_HEADER")
+	// TRUSTED_PROXIES=10.0.0.1,10.1.0.0/16
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy != "" {
+			finalConfig.App.TrustedProxies = append(finalConfig.App.TrustedProxies, proxy)
+		}
+	}
 	finalConfig.Database.DBNAME = os.Getenv("MONGO_DBNAME")
 	finalConfig.Database.DBURL = os.Getenv("MONGO_HOST")
 	finalConfig.Database.DBPASS = os.Getenv("MONGO_PASS")
@@ -59,6 +102,7 @@ func initConfig() *AppConfig {
 	finalConfig.Database.DBPORT = os.Getenv("MONGO_PORT")
 
 	finalConfig.Secrettoken.Token = os.Getenv("JWT_SECRET")
+	finalConfig.Secrettoken.KeysFile = os.Getenv("JWT_KEYS_FILE")
 
 	finalConfig.Database.REDIS_HOST = os.Getenv("REDIS_HOST")
 	finalConfig.Database.REDIS_PASS = os.Getenv("REDIS_PASS")
@@ -69,5 +113,34 @@ func initConfig() *AppConfig {
 	finalConfig.AwsS3.Bucket = os.Getenv("AWS_S3_BUCKET")
 	finalConfig.AwsS3.Zone = os.Getenv("AWS_S3_ZONE")
 
+	finalConfig.Mail.Host = os.Getenv("MAIL_SMTP_HOST")
+	finalConfig.Mail.Port = os.Getenv("MAIL_SMTP_PORT")
+	finalConfig.Mail.Username = os.Getenv("MAIL_SMTP_USER")
+	finalConfig.Mail.Password = os.Getenv("MAIL_SMTP_PASS")
+	finalConfig.Mail.From = os.Getenv("MAIL_FROM")
+	finalConfig.Mail.Dir = os.Getenv("MAIL_DIR")
+	finalConfig.Mail.VerifyURL = os.Getenv("MAIL_VERIFY_URL")
+
+	finalConfig.Payment.Provider = os.Getenv("PAYMENT_PROVIDER")
+	finalConfig.Payment.WebhookSecret = os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	finalConfig.Payment.CheckoutURL = os.Getenv("PAYMENT_CHECKOUT_URL")
+	finalConfig.Payment.AllowFake = os.Getenv("PAYMENT_ALLOW_FAKE") == "true"
+
+	// OIDC_PROVIDERS=google,apple reads OIDC_GOOGLE_ISSUER and so on
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := fmt.Sprintf("OIDC_%s_", strings.ToUpper(name))
+		finalConfig.OIDC = append(finalConfig.OIDC, OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		})
+	}
+
 	return &finalConfig
 }
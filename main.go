@@ -16,6 +16,16 @@ import (
 func main() {
 	conf := config.GetConfig()
 	dbCon := utils.NewConnectionDatabase(conf)
+	if len(os.Args) > 1 {
+		err := app.RunCommand(conf, dbCon, os.Args[1:])
+		dbCon.CloseConnection()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	server, port := app.Run(conf, dbCon)
 
 	defer dbCon.CloseConnection()
@@ -0,0 +1,12 @@
+package audit
+
+import (
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/utils"
+)
+
+func RepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig) user.AuditRepository {
+	auditRepo := NewMongoRepository(dbCon, conf)
+	return auditRepo
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/repository"
+	"roby-backend-golang/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoDBRepository struct {
+	colAudit *mongo.Collection
+	conf     *config.AppConfig
+}
+
+func NewMongoRepository(dbCon *utils.DatabaseConnection, conf *config.AppConfig) *MongoDBRepository {
+	repo := &MongoDBRepository{
+		colAudit: dbCon.MongoDB.Collection("audit_log"),
+		conf:     conf,
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+func (repo *MongoDBRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repo.colAudit.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "type", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (repo *MongoDBRepository) CreateAuditEvent(data businessUser.AuditEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	event := repository.AuditEvent{
+		ID:        primitive.NewObjectID(),
+		Type:      data.Type,
+		Email:     data.Email,
+		IP:        data.IP,
+		Detail:    data.Detail,
+		CreatedAt: data.CreatedAt,
+	}
+	if data.UserID != "" {
+		userID, err := primitive.ObjectIDFromHex(data.UserID)
+		if err != nil {
+			return errors.New("invalid id")
+		}
+		event.UserID = userID
+	}
+
+	_, err := repo.colAudit.InsertOne(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
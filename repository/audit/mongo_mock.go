@@ -0,0 +1,16 @@
+package audit
+
+import (
+	businessUser "roby-backend-golang/business/user"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type AuditMock struct {
+	*mock.Mock
+}
+
+func (m *AuditMock) CreateAuditEvent(data businessUser.AuditEvent) error {
+	args := m.Called(data)
+	return args.Error(0)
+}
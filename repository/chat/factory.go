@@ -0,0 +1,12 @@
+package chat
+
+import (
+	"roby-backend-golang/business/chat"
+	"roby-backend-golang/config"
+	"roby-backend-golang/utils"
+)
+
+func RepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig) chat.Repository {
+	chatRepo := NewMongoRepository(dbCon, conf)
+	return chatRepo
+}
@@ -0,0 +1,239 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	businessChat "roby-backend-golang/business/chat"
+	"roby-backend-golang/config"
+	"roby-backend-golang/repository"
+	"roby-backend-golang/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBRepository struct {
+	colMatch   *mongo.Collection
+	colMessage *mongo.Collection
+	conf       *config.AppConfig
+}
+
+func NewMongoRepository(dbCon *utils.DatabaseConnection, conf *config.AppConfig) *MongoDBRepository {
+	repo := &MongoDBRepository{
+		colMatch:   dbCon.MongoDB.Collection("match"),
+		colMessage: dbCon.MongoDB.Collection("message"),
+		conf:       conf,
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+// ensureIndexes serves the history of a conversation newest first.
+func (repo *MongoDBRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repo.colMessage.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "match_id", Value: 1}, {Key: "_id", Value: -1}},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func toBusinessMessage(msg repository.Message) businessChat.Message {
+	return businessChat.Message{
+		ID:          msg.ID.Hex(),
+		MatchID:     msg.MatchID.Hex(),
+		SenderID:    msg.SenderID.Hex(),
+		RecipientID: msg.RecipientID.Hex(),
+		Body:        msg.Body,
+		CreatedAt:   msg.CreatedAt,
+		DeliveredAt: msg.DeliveredAt,
+		ReadAt:      msg.ReadAt,
+	}
+}
+
+func (repo *MongoDBRepository) FindMatchByID(id string) (businessChat.Match, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return businessChat.Match{}, utils.HandleError(404, "match not found")
+	}
+
+	queryFilter := repository.NewFilterQuery()
+	queryFilter.SetID(objID)
+
+	var match repository.Match
+	err = repo.colMatch.FindOne(ctx, queryFilter).Decode(&match)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return businessChat.Match{}, utils.HandleError(404, "match not found")
+		}
+		return businessChat.Match{}, err
+	}
+
+	return businessChat.Match{
+		ID:      match.ID.Hex(),
+		UserIDs: []string{match.UserA.Hex(), match.UserB.Hex()},
+		Status:  match.Status,
+	}, nil
+}
+
+func (repo *MongoDBRepository) CreateMessage(data businessChat.Message) (businessChat.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	matchID, err := primitive.ObjectIDFromHex(data.MatchID)
+	if err != nil {
+		return data, errors.New("invalid id")
+	}
+	senderID, err := primitive.ObjectIDFromHex(data.SenderID)
+	if err != nil {
+		return data, errors.New("invalid id")
+	}
+	recipientID, err := primitive.ObjectIDFromHex(data.RecipientID)
+	if err != nil {
+		return data, errors.New("invalid id")
+	}
+
+	insMessage := repository.Message{
+		ID:          primitive.NewObjectID(),
+		MatchID:     matchID,
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		Body:        data.Body,
+		CreatedAt:   data.CreatedAt,
+	}
+
+	_, err = repo.colMessage.InsertOne(ctx, insMessage)
+	if err != nil {
+		return data, err
+	}
+
+	return toBusinessMessage(insMessage), nil
+}
+
+func (repo *MongoDBRepository) GetMessages(matchID string, query businessChat.HistoryQuery) ([]businessChat.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	messages := []businessChat.Message{}
+
+	objID, err := primitive.ObjectIDFromHex(matchID)
+	if err != nil {
+		return messages, errors.New("invalid id")
+	}
+
+	filter := bson.M{
+		"match_id":    objID,
+		"archived_at": bson.M{"$exists": false},
+	}
+	if query.Before != "" {
+		before, err := primitive.ObjectIDFromHex(query.Before)
+		if err != nil {
+			return messages, utils.HandleError(400, "invalid before")
+		}
+		filter["_id"] = bson.M{"$lt": before}
+	}
+
+	opts := options.Find().SetSort(bson.M{"_id": -1}).SetLimit(int64(query.Limit))
+
+	cur, err := repo.colMessage.Find(ctx, filter, opts)
+	if err != nil {
+		return messages, err
+	}
+
+	for cur.Next(ctx) {
+		var msg repository.Message
+		err = cur.Decode(&msg)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, toBusinessMessage(msg))
+	}
+
+	return messages, nil
+}
+
+func (repo *MongoDBRepository) GetActiveMatches(userID string) ([]businessChat.Match, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var matches []businessChat.Match
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return matches, errors.New("invalid id")
+	}
+
+	filter := bson.M{
+		"status": "active",
+		"$or":    bson.A{bson.M{"user_a": objID}, bson.M{"user_b": objID}},
+	}
+
+	cur, err := repo.colMatch.Find(ctx, filter)
+	if err != nil {
+		return matches, err
+	}
+
+	for cur.Next(ctx) {
+		var match repository.Match
+		err = cur.Decode(&match)
+		if err != nil {
+			return matches, err
+		}
+		matches = append(matches, businessChat.Match{
+			ID:      match.ID.Hex(),
+			UserIDs: []string{match.UserA.Hex(), match.UserB.Hex()},
+			Status:  match.Status,
+		})
+	}
+
+	return matches, nil
+}
+
+// MarkMessages stamps the messages received by recipientID in the match up to
+// messageID as delivered or read, a read message is delivered as well.
+func (repo *MongoDBRepository) MarkMessages(matchID, recipientID, messageID, state string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	matchObjID, err := primitive.ObjectIDFromHex(matchID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+	recipientObjID, err := primitive.ObjectIDFromHex(recipientID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+	messageObjID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return utils.HandleError(400, "invalid message id")
+	}
+
+	fields := []string{"delivered_at"}
+	if state == businessChat.EventRead {
+		fields = append(fields, "read_at")
+	}
+
+	for _, field := range fields {
+		filter := bson.M{
+			"match_id":     matchObjID,
+			"recipient_id": recipientObjID,
+			"_id":          bson.M{"$lte": messageObjID},
+			field:          bson.M{"$exists": false},
+		}
+		_, err = repo.colMessage.UpdateMany(ctx, filter, bson.M{"$set": bson.M{field: at}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package chat
+
+import (
+	businessChat "roby-backend-golang/business/chat"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type ChatMock struct {
+	*mock.Mock
+}
+
+func (m *ChatMock) FindMatchByID(id string) (businessChat.Match, error) {
+	args := m.Called(id)
+	return args.Get(0).(businessChat.Match), args.Error(1)
+}
+
+func (m *ChatMock) CreateMessage(data businessChat.Message) (businessChat.Message, error) {
+	args := m.Called(data)
+	return args.Get(0).(businessChat.Message), args.Error(1)
+}
+
+func (m *ChatMock) GetMessages(matchID string, query businessChat.HistoryQuery) ([]businessChat.Message, error) {
+	args := m.Called(matchID, query)
+	return args.Get(0).([]businessChat.Message), args.Error(1)
+}
+
+func (m *ChatMock) GetActiveMatches(userID string) ([]businessChat.Match, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]businessChat.Match), args.Error(1)
+}
+
+func (m *ChatMock) MarkMessages(matchID, recipientID, messageID, state string, at time.Time) error {
+	args := m.Called(matchID, recipientID, messageID, state, at)
+	return args.Error(0)
+}
@@ -0,0 +1,127 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	businessChat "roby-backend-golang/business/chat"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	relayChannel = "apptinder:chat:events"
+
+	// a crashed instance never decrements its connections, the ttl bounds how
+	// long such a user keeps showing up as online
+	presenceTTL = 24 * time.Hour
+)
+
+// disconnectScript decrements the presence counter and drops it once no
+// connection is left, in one step so a concurrent connect is never erased.
+var disconnectScript = redis.NewScript(`
+local count = redis.call("DECR", KEYS[1])
+if count <= 0 then
+	redis.call("DEL", KEYS[1])
+	return 0
+end
+return count
+`)
+
+// relayEnvelope is the payload published on the relay channel.
+type relayEnvelope struct {
+	UserID string             `json:"user_id"`
+	Event  businessChat.Event `json:"event"`
+}
+
+// RedisRelay fans chat events out to the hub of every API instance through
+// redis pub/sub and keeps the presence counters in redis.
+type RedisRelay struct {
+	redis  *redis.Client
+	hub    *businessChat.Hub
+	pubsub *redis.PubSub
+}
+
+func NewRedisRelay(client *redis.Client, hub *businessChat.Hub) *RedisRelay {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	relay := &RedisRelay{
+		redis:  client,
+		hub:    hub,
+		pubsub: client.Subscribe(context.Background(), relayChannel),
+	}
+
+	// wait for the subscription so no event published after this returns is lost
+	_, err := relay.pubsub.Receive(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	go relay.listen()
+	return relay
+}
+
+func (r *RedisRelay) listen() {
+	for msg := range r.pubsub.Channel() {
+		var envelope relayEnvelope
+		if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+			continue
+		}
+		r.hub.Notify(envelope.UserID, envelope.Event)
+	}
+}
+
+func (r *RedisRelay) Close() error {
+	return r.pubsub.Close()
+}
+
+func (r *RedisRelay) Notify(userID string, event businessChat.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	payload, err := json.Marshal(relayEnvelope{UserID: userID, Event: event})
+	if err != nil {
+		return
+	}
+
+	err = r.redis.Publish(ctx, relayChannel, payload).Err()
+	if err != nil {
+		// without redis at least the connections on this instance get the event
+		r.hub.Notify(userID, event)
+	}
+}
+
+func presenceKey(userID string) string {
+	return fmt.Sprintf("apptinder:presence:%s", userID)
+}
+
+func (r *RedisRelay) Connect(userID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var incr *redis.IntCmd
+	_, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, presenceKey(userID))
+		pipe.Expire(ctx, presenceKey(userID), presenceTTL)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return incr.Val() == 1, nil
+}
+
+func (r *RedisRelay) Disconnect(userID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count, err := disconnectScript.Run(ctx, r.redis, []string{presenceKey(userID)}).Int64()
+	if err != nil {
+		return false, err
+	}
+
+	return count == 0, nil
+}
@@ -0,0 +1,19 @@
+package chat
+
+import (
+	"github.com/stretchr/testify/mock"
+)
+
+type PresenceMock struct {
+	*mock.Mock
+}
+
+func (m *PresenceMock) Connect(userID string) (bool, error) {
+	args := m.Called(userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *PresenceMock) Disconnect(userID string) (bool, error) {
+	args := m.Called(userID)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,92 @@
+package chat_test
+
+import (
+	businessChat "roby-backend-golang/business/chat"
+	repoChat "roby-backend-golang/repository/chat"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeConn struct {
+	mu     sync.Mutex
+	events []businessChat.Event
+}
+
+func (c *fakeConn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, v.(businessChat.Event))
+	return nil
+}
+
+func (c *fakeConn) Events() []businessChat.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.events
+}
+
+// newInstance starts a hub and relay the way one API replica does.
+func newInstance(t *testing.T, server *miniredis.Miniredis) (*businessChat.Hub, *repoChat.RedisRelay) {
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	hub := businessChat.NewHub()
+	relay := repoChat.NewRedisRelay(client, hub)
+	t.Cleanup(func() {
+		relay.Close()
+		client.Close()
+	})
+	return hub, relay
+}
+
+func TestRedisRelay(t *testing.T) {
+	t.Run("Cross Instance Delivery Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		server := miniredis.RunT(t)
+		hubA, relayA := newInstance(t, server)
+		hubB, _ := newInstance(t, server)
+
+		sender := &fakeConn{}
+		hubA.Register("123", sender)
+		recipient := &fakeConn{}
+		hubB.Register("1234", recipient)
+
+		relayA.Notify("1234", businessChat.Event{
+			Type:    businessChat.EventMessage,
+			Message: &businessChat.Message{ID: "1", Body: "hello"},
+		})
+
+		asserting.Eventually(func() bool {
+			return len(recipient.Events()) == 1
+		}, time.Second, 10*time.Millisecond)
+		asserting.Equal("hello", recipient.Events()[0].Message.Body)
+		asserting.Empty(sender.Events())
+	})
+
+	t.Run("Presence Across Instances Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		server := miniredis.RunT(t)
+		_, relayA := newInstance(t, server)
+		_, relayB := newInstance(t, server)
+
+		first, err := relayA.Connect("123")
+		asserting.NoError(err)
+		asserting.True(first)
+
+		first, err = relayB.Connect("123")
+		asserting.NoError(err)
+		asserting.False(first)
+
+		last, err := relayA.Disconnect("123")
+		asserting.NoError(err)
+		asserting.False(last)
+
+		last, err = relayB.Disconnect("123")
+		asserting.NoError(err)
+		asserting.True(last)
+		asserting.False(server.Exists("apptinder:presence:123"))
+	})
+}
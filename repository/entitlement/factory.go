@@ -0,0 +1,18 @@
+package entitlement
+
+import (
+	"roby-backend-golang/business/entitlement"
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/utils"
+)
+
+func RepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig) user.EntitlementRepository {
+	entitlementRepo := NewMongoRepository(dbCon, conf)
+	return entitlementRepo
+}
+
+func FeatureRepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig) entitlement.Repository {
+	entitlementRepo := NewMongoRepository(dbCon, conf)
+	return entitlementRepo
+}
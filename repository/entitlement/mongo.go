@@ -0,0 +1,320 @@
+package entitlement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/repository"
+	"roby-backend-golang/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBRepository struct {
+	colEntitlement *mongo.Collection
+	colUser        *mongo.Collection
+	conf           *config.AppConfig
+}
+
+func NewMongoRepository(dbCon *utils.DatabaseConnection, conf *config.AppConfig) *MongoDBRepository {
+	repo := &MongoDBRepository{
+		colEntitlement: dbCon.MongoDB.Collection("entitlement"),
+		colUser:        dbCon.MongoDB.Collection("user"),
+		conf:           conf,
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+// ensureIndexes serves the package lookup of a user, the expiry job and
+// refunds, and keeps a user to one active entitlement per package.
+func (repo *MongoDBRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := repo.dedupeActiveEntitlements()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = repo.colEntitlement.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "ends_at", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "package_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": businessUser.EntitlementActive}),
+		},
+		{
+			Keys:    bson.D{{Key: "payment_id", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// dedupeActiveEntitlements leaves the user one active entitlement per package,
+// as the unique index requires, by expiring the others. Purchases from before
+// the index could grant a package twice. The entitlement lasting longest is
+// kept.
+func (repo *MongoDBRepository) dedupeActiveEntitlements() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"status": businessUser.EntitlementActive}},
+		bson.M{"$group": bson.M{
+			"_id":          bson.M{"user_id": "$user_id", "package_id": "$package_id"},
+			"entitlements": bson.M{"$push": bson.M{"_id": "$_id", "ends_at": "$ends_at"}},
+			"count":        bson.M{"$sum": 1},
+		}},
+		bson.M{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+	}
+	cur, err := repo.colEntitlement.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	var groups []struct {
+		Entitlements []struct {
+			ID     primitive.ObjectID `bson:"_id"`
+			EndsAt *time.Time         `bson:"ends_at"`
+		} `bson:"entitlements"`
+	}
+	err = cur.All(ctx, &groups)
+	if err != nil {
+		return err
+	}
+
+	var duplicates []primitive.ObjectID
+	for _, group := range groups {
+		kept := 0
+		for i, entitlement := range group.Entitlements {
+			keptEndsAt := group.Entitlements[kept].EndsAt
+			if keptEndsAt != nil && (entitlement.EndsAt == nil || entitlement.EndsAt.After(*keptEndsAt)) {
+				kept = i
+			}
+		}
+		for i, entitlement := range group.Entitlements {
+			if i != kept {
+				duplicates = append(duplicates, entitlement.ID)
+			}
+		}
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	res, err := repo.colEntitlement.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": duplicates}, "status": businessUser.EntitlementActive},
+		bson.M{"$set": bson.M{"status": businessUser.EntitlementExpired, "expired_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Println("expired duplicate active entitlements:", res.ModifiedCount)
+	return nil
+}
+
+func toBusinessEntitlement(entitlement repository.Entitlement) businessUser.Entitlement {
+	return businessUser.Entitlement{
+		ID:        entitlement.ID.Hex(),
+		UserID:    entitlement.UserID.Hex(),
+		PackageID: entitlement.PackageID.Hex(),
+		Status:    entitlement.Status,
+		StartsAt:  entitlement.StartsAt,
+		EndsAt:    entitlement.EndsAt,
+	}
+}
+
+func (repo *MongoDBRepository) CreateEntitlement(data businessUser.Entitlement) (businessUser.Entitlement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(data.UserID)
+	if err != nil {
+		return businessUser.Entitlement{}, errors.New("invalid id")
+	}
+	packageID, err := primitive.ObjectIDFromHex(data.PackageID)
+	if err != nil {
+		return businessUser.Entitlement{}, errors.New("invalid id")
+	}
+	var paymentID primitive.ObjectID
+	if data.PaymentID != "" {
+		paymentID, err = primitive.ObjectIDFromHex(data.PaymentID)
+		if err != nil {
+			return businessUser.Entitlement{}, errors.New("invalid id")
+		}
+	}
+
+	entitlement := repository.Entitlement{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		PackageID: packageID,
+		PaymentID: paymentID,
+		Status:    data.Status,
+		StartsAt:  data.StartsAt,
+		EndsAt:    data.EndsAt,
+		CreatedAt: time.Now(),
+	}
+
+	// the unique index on the active entitlements of a user lets one of two
+	// concurrent purchases of a package through
+	_, err = repo.colEntitlement.InsertOne(ctx, entitlement)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return businessUser.Entitlement{}, utils.HandleError(400, "already purchase package")
+		}
+		return businessUser.Entitlement{}, err
+	}
+
+	return toBusinessEntitlement(entitlement), nil
+}
+
+func (repo *MongoDBRepository) RevokeEntitlement(paymentID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(paymentID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	filter := bson.M{
+		"payment_id": objID,
+		"status":     businessUser.EntitlementActive,
+	}
+	update := bson.M{"$set": bson.M{
+		"status":     businessUser.EntitlementRevoked,
+		"expired_at": at,
+	}}
+
+	_, err = repo.colEntitlement.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (repo *MongoDBRepository) ExpireEntitlements(at time.Time, limit int) ([]businessUser.Entitlement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var expired []businessUser.Entitlement
+	filter := bson.M{
+		"status":  businessUser.EntitlementActive,
+		"ends_at": bson.M{"$lte": at},
+	}
+	update := bson.M{"$set": bson.M{
+		"status":     businessUser.EntitlementExpired,
+		"expired_at": at,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	// one entitlement at a time so every instance claims different ones
+	for len(expired) < limit {
+		var entitlement repository.Entitlement
+		err := repo.colEntitlement.FindOneAndUpdate(ctx, filter, update, opts).Decode(&entitlement)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired = append(expired, toBusinessEntitlement(entitlement))
+	}
+
+	return expired, nil
+}
+
+// ExpireLapsedEntitlements expires the active entitlements of the user to the
+// package that ended by at, which the expiry job did not reach yet and which
+// would block a renewal on the unique index.
+func (repo *MongoDBRepository) ExpireLapsedEntitlements(userID, packageID string, at time.Time) ([]businessUser.Entitlement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var expired []businessUser.Entitlement
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return expired, errors.New("invalid id")
+	}
+	packageObjID, err := primitive.ObjectIDFromHex(packageID)
+	if err != nil {
+		return expired, errors.New("invalid id")
+	}
+
+	filter := bson.M{
+		"user_id":    userObjID,
+		"package_id": packageObjID,
+		"status":     businessUser.EntitlementActive,
+		"ends_at":    bson.M{"$lte": at},
+	}
+	update := bson.M{"$set": bson.M{
+		"status":     businessUser.EntitlementExpired,
+		"expired_at": at,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	// one at a time like ExpireEntitlements, so each is returned to one
+	// caller only
+	for {
+		var entitlement repository.Entitlement
+		err := repo.colEntitlement.FindOneAndUpdate(ctx, filter, update, opts).Decode(&entitlement)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired = append(expired, toBusinessEntitlement(entitlement))
+	}
+
+	return expired, nil
+}
+
+func (repo *MongoDBRepository) GetFeatures(userID string, at time.Time) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid id")
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"_id": objID}},
+	}
+	pipeline = append(pipeline, repository.PackageLookup("", at)...)
+	pipeline = append(pipeline,
+		bson.M{"$unwind": "$packages"},
+		bson.M{"$unwind": "$packages.features"},
+		bson.M{"$group": bson.M{"_id": nil, "features": bson.M{"$addToSet": "$packages.features"}}},
+	)
+
+	cur, err := repo.colUser.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Features []string `bson:"features"`
+	}
+	for cur.Next(ctx) {
+		err = cur.Decode(&res)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res.Features, nil
+}
@@ -0,0 +1,37 @@
+package entitlement
+
+import (
+	businessUser "roby-backend-golang/business/user"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type EntitlementMock struct {
+	*mock.Mock
+}
+
+func (m *EntitlementMock) CreateEntitlement(data businessUser.Entitlement) (businessUser.Entitlement, error) {
+	args := m.Called(data)
+	return args.Get(0).(businessUser.Entitlement), args.Error(1)
+}
+
+func (m *EntitlementMock) ExpireEntitlements(at time.Time, limit int) ([]businessUser.Entitlement, error) {
+	args := m.Called(at, limit)
+	return args.Get(0).([]businessUser.Entitlement), args.Error(1)
+}
+
+func (m *EntitlementMock) ExpireLapsedEntitlements(userID, packageID string, at time.Time) ([]businessUser.Entitlement, error) {
+	args := m.Called(userID, packageID, at)
+	return args.Get(0).([]businessUser.Entitlement), args.Error(1)
+}
+
+func (m *EntitlementMock) GetFeatures(userID string, at time.Time) ([]string, error) {
+	args := m.Called(userID, at)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *EntitlementMock) RevokeEntitlement(paymentID string, at time.Time) error {
+	args := m.Called(paymentID, at)
+	return args.Error(0)
+}
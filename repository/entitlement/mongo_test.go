@@ -0,0 +1,180 @@
+package entitlement_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/repository"
+	repoEntitlement "roby-backend-golang/repository/entitlement"
+	"roby-backend-golang/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestDatabase connects to the mongodb at MONGO_TEST_URI and hands out a
+// database of its own that is dropped after the test. The unique index the
+// purchases rely on only exists on a real server.
+func newTestDatabase(t *testing.T) *utils.DatabaseConnection {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := client.Database(fmt.Sprintf("entitlement_test_%s", primitive.NewObjectID().Hex()))
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+	return &utils.DatabaseConnection{Driver: utils.MongoDB, MongoDB: db}
+}
+
+func countActive(t *testing.T, dbCon *utils.DatabaseConnection, filter bson.M) int64 {
+	filter["status"] = businessUser.EntitlementActive
+	count, err := dbCon.MongoDB.Collection("entitlement").CountDocuments(context.Background(), filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestCreateEntitlement(t *testing.T) {
+	t.Run("Concurrent Purchases Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		dbCon := newTestDatabase(t)
+		repo := repoEntitlement.NewMongoRepository(dbCon, &config.AppConfig{})
+		userID := primitive.NewObjectID()
+		packageID := primitive.NewObjectID()
+
+		const requests = 20
+		errs := make(chan error, requests)
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.CreateEntitlement(businessUser.Entitlement{
+					UserID:    userID.Hex(),
+					PackageID: packageID.Hex(),
+					PaymentID: primitive.NewObjectID().Hex(),
+					Status:    businessUser.EntitlementActive,
+					StartsAt:  time.Now(),
+				})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		granted := 0
+		for err := range errs {
+			if err == nil {
+				granted++
+				continue
+			}
+			asserting.Equal(400, utils.GetStatusCode(err))
+		}
+		asserting.Equal(1, granted)
+		asserting.Equal(int64(1), countActive(t, dbCon, bson.M{"user_id": userID}))
+	})
+
+	t.Run("Concurrent Purchases Of Packages Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		dbCon := newTestDatabase(t)
+		repo := repoEntitlement.NewMongoRepository(dbCon, &config.AppConfig{})
+		userID := primitive.NewObjectID()
+
+		const requests = 20
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.CreateEntitlement(businessUser.Entitlement{
+					UserID:    userID.Hex(),
+					PackageID: primitive.NewObjectID().Hex(),
+					PaymentID: primitive.NewObjectID().Hex(),
+					Status:    businessUser.EntitlementActive,
+					StartsAt:  time.Now(),
+				})
+				asserting.NoError(err)
+			}()
+		}
+		wg.Wait()
+
+		// every purchase is an entitlement of its own, none is lost
+		asserting.Equal(int64(requests), countActive(t, dbCon, bson.M{"user_id": userID}))
+	})
+
+	t.Run("Renewal After Lapse Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		dbCon := newTestDatabase(t)
+		repo := repoEntitlement.NewMongoRepository(dbCon, &config.AppConfig{})
+		userID := primitive.NewObjectID().Hex()
+		packageID := primitive.NewObjectID().Hex()
+		now := time.Now()
+		lapsed := now.Add(-time.Hour)
+
+		_, err := repo.CreateEntitlement(businessUser.Entitlement{
+			UserID:    userID,
+			PackageID: packageID,
+			Status:    businessUser.EntitlementActive,
+			StartsAt:  now.AddDate(0, -1, 0),
+			EndsAt:    &lapsed,
+		})
+		asserting.NoError(err)
+
+		expired, err := repo.ExpireLapsedEntitlements(userID, packageID, now)
+		asserting.NoError(err)
+		asserting.Len(expired, 1)
+
+		_, err = repo.CreateEntitlement(businessUser.Entitlement{
+			UserID:    userID,
+			PackageID: packageID,
+			Status:    businessUser.EntitlementActive,
+			StartsAt:  now,
+		})
+		asserting.NoError(err)
+	})
+}
+
+func TestDedupeActiveEntitlements(t *testing.T) {
+	t.Run("Longest Entitlement Kept Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		dbCon := newTestDatabase(t)
+		userID := primitive.NewObjectID()
+		packageID := primitive.NewObjectID()
+		now := time.Now()
+		nextMonth := now.AddDate(0, 1, 0)
+		nextYear := now.AddDate(1, 0, 0)
+
+		// granted twice before the unique index existed
+		_, err := dbCon.MongoDB.Collection("entitlement").InsertMany(context.Background(), []interface{}{
+			repository.Entitlement{ID: primitive.NewObjectID(), UserID: userID, PackageID: packageID, Status: businessUser.EntitlementActive, StartsAt: now, EndsAt: &nextMonth, CreatedAt: now},
+			repository.Entitlement{ID: primitive.NewObjectID(), UserID: userID, PackageID: packageID, Status: businessUser.EntitlementActive, StartsAt: now, EndsAt: &nextYear, CreatedAt: now},
+			repository.Entitlement{ID: primitive.NewObjectID(), UserID: userID, PackageID: primitive.NewObjectID(), Status: businessUser.EntitlementActive, StartsAt: now, CreatedAt: now},
+		})
+		asserting.NoError(err)
+
+		repoEntitlement.NewMongoRepository(dbCon, &config.AppConfig{})
+
+		asserting.Equal(int64(1), countActive(t, dbCon, bson.M{"user_id": userID, "package_id": packageID}))
+		asserting.Equal(int64(1), countActive(t, dbCon, bson.M{"user_id": userID, "package_id": packageID, "ends_at": nextYear}))
+		asserting.Equal(int64(2), countActive(t, dbCon, bson.M{"user_id": userID}))
+	})
+}
@@ -0,0 +1,16 @@
+package identity
+
+import (
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+)
+
+// ProviderFactory builds the identity providers configured in OIDC_PROVIDERS
+// keyed by their name, the name is the provider segment of the oauth routes.
+func ProviderFactory(conf *config.AppConfig) map[string]user.IdentityProvider {
+	providers := map[string]user.IdentityProvider{}
+	for _, provider := range conf.OIDC {
+		providers[provider.Name] = NewOIDCProvider(provider)
+	}
+	return providers
+}
@@ -0,0 +1,83 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/utils"
+	"sync"
+)
+
+// FakeProvider stands in for an identity provider in tests. Authorize plays
+// the user signing in at the provider, the code it returns is bound to the
+// PKCE challenge and nonce of the authorization request like a real one.
+type FakeProvider struct {
+	mu       sync.Mutex
+	requests map[string]fakeRequest
+	grants   map[string]fakeGrant
+}
+
+type fakeRequest struct {
+	challenge string
+	nonce     string
+}
+
+type fakeGrant struct {
+	fakeRequest
+	identity user.Identity
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		requests: map[string]fakeRequest{},
+		grants:   map[string]fakeGrant{},
+	}
+}
+
+func (p *FakeProvider) AuthCodeURL(state, codeChallenge, nonce string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests[state] = fakeRequest{challenge: codeChallenge, nonce: nonce}
+	return fmt.Sprintf("https://fake-idp.test/authorize?state=%s", url.QueryEscape(state)), nil
+}
+
+// Authorize signs the identity in for the authorization request of the state
+// and returns the code the provider would redirect back with.
+func (p *FakeProvider) Authorize(state string, identity user.Identity) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	request, ok := p.requests[state]
+	if !ok {
+		return "", errors.New("unknown state")
+	}
+	delete(p.requests, state)
+
+	code, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	p.grants[code] = fakeGrant{fakeRequest: request, identity: identity}
+	return code, nil
+}
+
+func (p *FakeProvider) Exchange(code, codeVerifier string) (user.Identity, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	grant, ok := p.grants[code]
+	if !ok {
+		return user.Identity{}, errors.New("invalid code")
+	}
+	delete(p.grants, code)
+
+	if utils.PKCEChallenge(codeVerifier) != grant.challenge {
+		return user.Identity{}, errors.New("invalid code verifier")
+	}
+
+	identity := grant.identity
+	identity.Nonce = grant.nonce
+	return identity, nil
+}
@@ -0,0 +1,353 @@
+package identity
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"strings"
+	"sync"
+	"time"
+
+	jose "github.com/dvsekhvalnov/jose2go"
+)
+
+const (
+	// keys of the provider are fetched again at most this often for an
+	// unknown kid, providers rotate keys but a token must not force a fetch
+	jwksRefreshInterval = time.Minute
+	maxResponseSize     = 1 << 20
+)
+
+// OIDCProvider signs users in with an OpenID Connect provider through the
+// authorization code flow with PKCE. Endpoints are read from the discovery
+// document of the issuer, the id token is verified against its jwks.
+type OIDCProvider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	client       *http.Client
+	now          func() time.Time
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]interface{}
+	keysAt    time.Time
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type idTokenClaims struct {
+	Iss           string       `json:"iss"`
+	Sub           string       `json:"sub"`
+	Aud           audience     `json:"aud"`
+	Exp           int64        `json:"exp"`
+	Nonce         string       `json:"nonce"`
+	Email         string       `json:"email"`
+	EmailVerified flexibleBool `json:"email_verified"`
+	Name          string       `json:"name"`
+	Picture       string       `json:"picture"`
+}
+
+// audience is a single string or a list in an id token.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// flexibleBool accepts the "true" string some providers send for booleans.
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = flexibleBool(value)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*b = flexibleBool(text == "true")
+	return nil
+}
+
+func NewOIDCProvider(conf config.OIDCProvider) *OIDCProvider {
+	return &OIDCProvider{
+		issuer:       strings.TrimSuffix(conf.Issuer, "/"),
+		clientID:     conf.ClientID,
+		clientSecret: conf.ClientSecret,
+		redirectURL:  conf.RedirectURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		now:          time.Now,
+	}
+}
+
+func (p *OIDCProvider) AuthCodeURL(state, codeChallenge, nonce string) (string, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.clientID)
+	query.Set("redirect_uri", p.redirectURL)
+	query.Set("scope", "openid email profile")
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades the code for tokens and returns the identity of the
+// verified id token.
+func (p *OIDCProvider) Exchange(code, codeVerifier string) (user.Identity, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return user.Identity{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("client_id", p.clientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.clientSecret != "" {
+		form.Set("client_secret", p.clientSecret)
+	}
+
+	resp, err := p.client.PostForm(doc.TokenEndpoint, form)
+	if err != nil {
+		return user.Identity{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return user.Identity{}, fmt.Errorf("token endpoint answered %d", resp.StatusCode)
+	}
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&token)
+	if err != nil {
+		return user.Identity{}, err
+	}
+	if token.IDToken == "" {
+		return user.Identity{}, errors.New("token response has no id token")
+	}
+
+	claims, err := p.verifyIDToken(token.IDToken, doc)
+	if err != nil {
+		return user.Identity{}, err
+	}
+
+	return user.Identity{
+		Subject:       claims.Sub,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		FullName:      claims.Name,
+		PhotoUrl:      claims.Picture,
+		Nonce:         claims.Nonce,
+	}, nil
+}
+
+func (p *OIDCProvider) verifyIDToken(token string, doc *discoveryDocument) (*idTokenClaims, error) {
+	payload, _, err := jose.Decode(token, func(headers map[string]interface{}, payload string) interface{} {
+		alg, _ := headers["alg"].(string)
+		kid, _ := headers["kid"].(string)
+		key, err := p.publicKey(doc, kid)
+		if err != nil {
+			return err
+		}
+		// the key decides the algorithm, a token can not pick a weaker one
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if alg != jose.RS256 {
+				return fmt.Errorf("alg %q does not match the key", alg)
+			}
+		case *ecdsa.PublicKey:
+			if alg != jose.ES256 {
+				return fmt.Errorf("alg %q does not match the key", alg)
+			}
+		}
+		return key
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &idTokenClaims{}
+	err = json.Unmarshal([]byte(payload), claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Iss != doc.Issuer {
+		return nil, errors.New("id token from another issuer")
+	}
+	audienceMatch := false
+	for _, aud := range claims.Aud {
+		audienceMatch = audienceMatch || aud == p.clientID
+	}
+	if !audienceMatch {
+		return nil, errors.New("id token for another client")
+	}
+	if claims.Exp < p.now().Unix() {
+		return nil, errors.New("id token expired")
+	}
+
+	return claims, nil
+}
+
+func (p *OIDCProvider) getDiscovery() (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	doc := &discoveryDocument{}
+	err := p.getJSON(p.issuer+"/.well-known/openid-configuration", doc)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Issuer != p.issuer {
+		return nil, fmt.Errorf("discovery document is for issuer %q", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JwksURI == "" {
+		return nil, errors.New("discovery document misses an endpoint")
+	}
+
+	p.discovery = doc
+	return doc, nil
+}
+
+// publicKey returns the signing key of the provider with the kid, the keys
+// are fetched again when the kid is unknown since the provider may have
+// rotated.
+func (p *OIDCProvider) publicKey(doc *discoveryDocument, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := p.keys[kid]
+	if ok {
+		return key, nil
+	}
+	if !p.keysAt.IsZero() && p.now().Sub(p.keysAt) < jwksRefreshInterval {
+		return nil, errors.New("unknown signing key")
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err := p.getJSON(doc.JwksURI, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		public, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = public
+	}
+	p.keys, p.keysAt = keys, p.now()
+
+	key, ok = p.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	return key, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func (p *OIDCProvider) getJSON(endpoint string, v interface{}) error {
+	resp, err := p.client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
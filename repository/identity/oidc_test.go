@@ -0,0 +1,194 @@
+package identity_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"roby-backend-golang/config"
+	repoIdentity "roby-backend-golang/repository/identity"
+	"roby-backend-golang/utils"
+	"testing"
+	"time"
+
+	jose "github.com/dvsekhvalnov/jose2go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIssuer serves the discovery, jwks and token endpoints of an OIDC
+// provider, claims is the id token the token endpoint hands out.
+type fakeIssuer struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	verifier string
+	claims   map[string]interface{}
+	sign     func(payload string) string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &fakeIssuer{key: key}
+	issuer.sign = func(payload string) string {
+		token, err := jose.Sign(payload, jose.RS256, key, jose.Header("kid", "key-1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.server.URL,
+			"authorization_endpoint": issuer.server.URL + "/authorize",
+			"token_endpoint":         issuer.server.URL + "/token",
+			"jwks_uri":               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "code-1" || r.PostFormValue("code_verifier") != issuer.verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		payload, _ := json.Marshal(issuer.claims)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": issuer.sign(string(payload))})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	issuer.verifier = "verifier"
+	issuer.claims = map[string]interface{}{
+		"iss":            issuer.server.URL,
+		"sub":            "subject-1",
+		"aud":            "client-1",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"nonce":          "nonce-1",
+		"email":          "test@mail.com",
+		"email_verified": true,
+		"name":           "test",
+	}
+	return issuer
+}
+
+func (i *fakeIssuer) provider() *repoIdentity.OIDCProvider {
+	return repoIdentity.NewOIDCProvider(config.OIDCProvider{
+		Name:        "fake",
+		Issuer:      i.server.URL,
+		ClientID:    "client-1",
+		RedirectURL: "https://app.test/callback",
+	})
+}
+
+func TestOIDCProvider(t *testing.T) {
+	t.Run("Auth Code URL Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		issuer := newFakeIssuer(t)
+
+		authURL, err := issuer.provider().AuthCodeURL("state-1", utils.PKCEChallenge("verifier"), "nonce-1")
+		asserting.NoError(err)
+		parsed, err := url.Parse(authURL)
+		asserting.NoError(err)
+		asserting.Equal("/authorize", parsed.Path)
+		asserting.Equal("state-1", parsed.Query().Get("state"))
+		asserting.Equal("S256", parsed.Query().Get("code_challenge_method"))
+		asserting.Equal(utils.PKCEChallenge("verifier"), parsed.Query().Get("code_challenge"))
+	})
+
+	t.Run("Valid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		issuer := newFakeIssuer(t)
+
+		identity, err := issuer.provider().Exchange("code-1", "verifier")
+		asserting.NoError(err)
+		asserting.Equal("subject-1", identity.Subject)
+		asserting.Equal("test@mail.com", identity.Email)
+		asserting.True(identity.EmailVerified)
+		asserting.Equal("nonce-1", identity.Nonce)
+	})
+
+	t.Run("Wrong Verifier Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		issuer := newFakeIssuer(t)
+
+		_, err := issuer.provider().Exchange("code-1", "another-verifier")
+		asserting.Error(err)
+	})
+
+	t.Run("Other Audience Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		issuer := newFakeIssuer(t)
+		issuer.claims["aud"] = []string{"client-2"}
+
+		_, err := issuer.provider().Exchange("code-1", "verifier")
+		asserting.Error(err)
+	})
+
+	t.Run("Other Issuer Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		issuer := newFakeIssuer(t)
+		issuer.claims["iss"] = "https://evil.test"
+
+		_, err := issuer.provider().Exchange("code-1", "verifier")
+		asserting.Error(err)
+	})
+
+	t.Run("Expired Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		issuer := newFakeIssuer(t)
+		issuer.claims["exp"] = time.Now().Add(-time.Minute).Unix()
+
+		_, err := issuer.provider().Exchange("code-1", "verifier")
+		asserting.Error(err)
+	})
+
+	t.Run("Unsigned Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		issuer := newFakeIssuer(t)
+		issuer.sign = func(payload string) string {
+			token, _ := jose.Sign(payload, jose.NONE, nil, jose.Header("kid", "key-1"))
+			return token
+		}
+
+		_, err := issuer.provider().Exchange("code-1", "verifier")
+		asserting.Error(err)
+	})
+
+	t.Run("Algorithm Confusion Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		issuer := newFakeIssuer(t)
+		// HS256 keyed with the public modulus must not pass as the rsa key
+		issuer.sign = func(payload string) string {
+			token, _ := jose.Sign(payload, jose.HS256, issuer.key.N.Bytes(), jose.Header("kid", "key-1"))
+			return token
+		}
+
+		_, err := issuer.provider().Exchange("code-1", "verifier")
+		asserting.Error(err)
+	})
+
+	t.Run("String Email Verified Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		issuer := newFakeIssuer(t)
+		issuer.claims["email_verified"] = "true"
+
+		identity, err := issuer.provider().Exchange("code-1", "verifier")
+		asserting.NoError(err)
+		asserting.True(identity.EmailVerified)
+	})
+}
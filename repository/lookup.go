@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"roby-backend-golang/business/user"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PackageLookup joins the packages a user holds at the time into packages,
+// prefix is the path of the user document in the pipeline, "" at the root.
+// package is replaced by the held package ids: those of the entitlements
+// active at the time and the ids stored on users before entitlements existed,
+// which were granted for good.
+func PackageLookup(prefix string, at time.Time) bson.A {
+	return bson.A{
+		bson.M{"$lookup": bson.M{
+			"from": "entitlement",
+			"let":  bson.M{"user_id": "$" + prefix + "_id"},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{
+					"$expr":     bson.M{"$eq": bson.A{"$user_id", "$$user_id"}},
+					"status":    user.EntitlementActive,
+					"starts_at": bson.M{"$lte": at},
+					// the expiry job may not have run yet
+					"$or": bson.A{
+						bson.M{"ends_at": bson.M{"$exists": false}},
+						bson.M{"ends_at": bson.M{"$gt": at}},
+					},
+				}},
+				bson.M{"$sort": bson.M{"starts_at": 1}},
+			},
+			"as": prefix + "entitlements",
+		}},
+		bson.M{"$addFields": bson.M{
+			prefix + "package": bson.M{"$setUnion": bson.A{
+				bson.M{"$ifNull": bson.A{"$" + prefix + "package", bson.A{}}},
+				"$" + prefix + "entitlements.package_id",
+			}},
+		}},
+		bson.M{"$lookup": bson.M{
+			"from":         "package",
+			"localField":   prefix + "package",
+			"foreignField": "_id",
+			"as":           prefix + "packages",
+		}},
+	}
+}
@@ -0,0 +1,19 @@
+package mailer
+
+import (
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+)
+
+// MailerFactory sends through smtp when a host is configured, otherwise mail
+// is written to MAIL_DIR so it can be read during local development.
+func MailerFactory(conf *config.AppConfig) user.Mailer {
+	if conf.Mail.Host != "" {
+		return NewSMTPMailer(conf)
+	}
+	dir := conf.Mail.Dir
+	if dir == "" {
+		dir = "mail"
+	}
+	return NewFileMailer(dir)
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"roby-backend-golang/config"
+	"strings"
+	"time"
+)
+
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(conf *config.AppConfig) *SMTPMailer {
+	port := conf.Mail.Port
+	if port == "" {
+		port = "587"
+	}
+	mailer := &SMTPMailer{
+		addr: fmt.Sprintf("%s:%s", conf.Mail.Host, port),
+		from: conf.Mail.From,
+	}
+	if conf.Mail.Username != "" {
+		mailer.auth = smtp.PlainAuth("", conf.Mail.Username, conf.Mail.Password, conf.Mail.Host)
+	}
+	return mailer
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	msg, err := buildMessage(m.from, to, subject, body)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, msg)
+}
+
+// buildMessage renders a plain text mail. Header values come from user input
+// so line breaks are refused to keep extra headers from being injected.
+func buildMessage(from, to, subject, body string) ([]byte, error) {
+	for _, v := range []string{from, to, subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, errors.New("invalid mail header")
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,12 @@
+package match
+
+import (
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/utils"
+)
+
+func RepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig) user.MatchRepository {
+	matchRepo := NewMongoRepository(dbCon, conf)
+	return matchRepo
+}
@@ -0,0 +1,287 @@
+package match
+
+import (
+	"context"
+	"errors"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/repository"
+	"roby-backend-golang/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBRepository struct {
+	colMatch   *mongo.Collection
+	colMessage *mongo.Collection
+	conf       *config.AppConfig
+}
+
+// matchUser is a match joined with the profile of the other party.
+type matchUser struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	CreatedAt time.Time          `bson:"created_at"`
+	User      repository.User    `bson:"user"`
+}
+
+func NewMongoRepository(dbCon *utils.DatabaseConnection, conf *config.AppConfig) *MongoDBRepository {
+	repo := &MongoDBRepository{
+		colMatch:   dbCon.MongoDB.Collection("match"),
+		colMessage: dbCon.MongoDB.Collection("message"),
+		conf:       conf,
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+// ensureIndexes keeps a single match document per pair of users, the pair is
+// always stored with the lower id in user_a.
+func (repo *MongoDBRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repo.colMatch.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_a", Value: 1}, {Key: "user_b", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_b", Value: 1}, {Key: "status", Value: 1}},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func pairObjectID(userIDs []string) (primitive.ObjectID, primitive.ObjectID, error) {
+	if len(userIDs) != 2 {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("match needs two users")
+	}
+	userA, err := primitive.ObjectIDFromHex(userIDs[0])
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("invalid id")
+	}
+	userB, err := primitive.ObjectIDFromHex(userIDs[1])
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("invalid id")
+	}
+	if userA.Hex() > userB.Hex() {
+		userA, userB = userB, userA
+	}
+	return userA, userB, nil
+}
+
+func (repo *MongoDBRepository) CreateMatch(data businessUser.Match) (businessUser.Match, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userA, userB, err := pairObjectID(data.UserIDs)
+	if err != nil {
+		return businessUser.Match{}, err
+	}
+
+	// both users can like each other at the same time, the upsert makes the
+	// second request return the match created by the first one
+	filter := bson.M{"user_a": userA, "user_b": userB}
+	update := bson.M{"$setOnInsert": bson.M{
+		"status":     data.Status,
+		"created_at": data.CreatedAt,
+	}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var match repository.Match
+	err = repo.colMatch.FindOneAndUpdate(ctx, filter, update, opts).Decode(&match)
+	if err != nil {
+		return businessUser.Match{}, err
+	}
+
+	return toBusinessMatch(match), nil
+}
+
+func toBusinessMatch(match repository.Match) businessUser.Match {
+	res := businessUser.Match{
+		ID:          match.ID.Hex(),
+		UserIDs:     []string{match.UserA.Hex(), match.UserB.Hex()},
+		Status:      match.Status,
+		CreatedAt:   match.CreatedAt,
+		UnmatchedAt: match.UnmatchedAt,
+	}
+	if !match.UnmatchedBy.IsZero() {
+		res.UnmatchedBy = match.UnmatchedBy.Hex()
+	}
+	return res
+}
+
+func (repo *MongoDBRepository) GetMatches(userID string, page businessUser.Pagination) ([]businessUser.ResponseMatch, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	matches := []businessUser.ResponseMatch{}
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return matches, 0, errors.New("invalid id")
+	}
+
+	filter := bson.M{
+		"status": businessUser.MatchStatusActive,
+		"$or":    bson.A{bson.M{"user_a": objID}, bson.M{"user_b": objID}},
+	}
+
+	total, err := repo.colMatch.CountDocuments(ctx, filter)
+	if err != nil {
+		return matches, 0, err
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": filter},
+		bson.M{"$sort": bson.M{"created_at": -1}},
+		bson.M{"$skip": (page.Page - 1) * page.Limit},
+		bson.M{"$limit": page.Limit},
+		bson.M{"$addFields": bson.M{
+			"other": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$user_a", objID}}, "$user_b", "$user_a"}},
+		}},
+		bson.M{"$lookup": bson.M{
+			"from":         "user",
+			"localField":   "other",
+			"foreignField": "_id",
+			"as":           "user",
+		}},
+		bson.M{"$unwind": "$user"},
+	}
+	pipeline = append(pipeline, repository.PackageLookup("user.", time.Now())...)
+
+	cur, err := repo.colMatch.Aggregate(ctx, pipeline)
+	if err != nil {
+		return matches, 0, err
+	}
+
+	for cur.Next(ctx) {
+		var match matchUser
+		err = cur.Decode(&match)
+		if err != nil {
+			return matches, 0, err
+		}
+		matches = append(matches, businessUser.ResponseMatch{
+			ID: match.ID.Hex(),
+			User: businessUser.ResponseRandomUser{
+				ID:       match.User.ID.Hex(),
+				FullName: match.User.Fullname,
+				Email:    match.User.Email,
+				PhotoUrl: match.User.PhotoUrl,
+				Packages: match.User.Packages,
+			},
+			CreatedAt: match.CreatedAt,
+		})
+	}
+
+	return matches, total, nil
+}
+
+func (repo *MongoDBRepository) FindMatchByID(id string) (businessUser.Match, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return businessUser.Match{}, utils.HandleError(404, "match not found")
+	}
+
+	queryFilter := repository.NewFilterQuery()
+	queryFilter.SetID(objID)
+
+	var match repository.Match
+	err = repo.colMatch.FindOne(ctx, queryFilter).Decode(&match)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return businessUser.Match{}, utils.HandleError(404, "match not found")
+		}
+		return businessUser.Match{}, err
+	}
+
+	return toBusinessMatch(match), nil
+}
+
+// Unmatch dissolves the match for both users and archives the conversation
+// that belongs to it.
+func (repo *MongoDBRepository) Unmatch(id, userID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	filter := bson.M{"_id": objID, "status": businessUser.MatchStatusActive}
+	update := bson.M{"$set": bson.M{
+		"status":       businessUser.MatchStatusUnmatched,
+		"unmatched_by": userObjID,
+		"unmatched_at": at,
+	}}
+
+	res, err := repo.colMatch.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return utils.HandleError(400, "already unmatched")
+	}
+
+	_, err = repo.colMessage.UpdateMany(ctx,
+		bson.M{"match_id": objID, "archived_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"archived_at": at}},
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *MongoDBRepository) GetUnmatchedUserIDs(userID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var ids []string
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ids, errors.New("invalid id")
+	}
+
+	filter := bson.M{
+		"status": businessUser.MatchStatusUnmatched,
+		"$or":    bson.A{bson.M{"user_a": objID}, bson.M{"user_b": objID}},
+	}
+	opts := options.Find().SetProjection(bson.M{"user_a": 1, "user_b": 1})
+
+	cur, err := repo.colMatch.Find(ctx, filter, opts)
+	if err != nil {
+		return ids, err
+	}
+
+	for cur.Next(ctx) {
+		var match repository.Match
+		err = cur.Decode(&match)
+		if err != nil {
+			return ids, err
+		}
+		if match.UserA == objID {
+			ids = append(ids, match.UserB.Hex())
+		} else {
+			ids = append(ids, match.UserA.Hex())
+		}
+	}
+
+	return ids, nil
+}
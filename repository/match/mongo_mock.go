@@ -0,0 +1,37 @@
+package match
+
+import (
+	businessUser "roby-backend-golang/business/user"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MatchMock struct {
+	*mock.Mock
+}
+
+func (m *MatchMock) CreateMatch(data businessUser.Match) (businessUser.Match, error) {
+	args := m.Called(data)
+	return args.Get(0).(businessUser.Match), args.Error(1)
+}
+
+func (m *MatchMock) GetMatches(userID string, page businessUser.Pagination) ([]businessUser.ResponseMatch, int64, error) {
+	args := m.Called(userID, page)
+	return args.Get(0).([]businessUser.ResponseMatch), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MatchMock) FindMatchByID(id string) (businessUser.Match, error) {
+	args := m.Called(id)
+	return args.Get(0).(businessUser.Match), args.Error(1)
+}
+
+func (m *MatchMock) Unmatch(id, userID string, at time.Time) error {
+	args := m.Called(id, userID, at)
+	return args.Error(0)
+}
+
+func (m *MatchMock) GetUnmatchedUserIDs(userID string) ([]string, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]string), args.Error(1)
+}
@@ -16,31 +16,192 @@ type User struct {
 	PhotoUrl string             `json:"photo_url" bson:"photo_url,omitempty"`
 	Package  []string           `json:"package" bson:"package,omitempty"`
 	Packages []user.Package     `json:"packages" bson:"packages,omitempty"`
+	// missing on accounts created before email verification, those count as verified
+	EmailVerified    *bool  `json:"email_verified" bson:"email_verified,omitempty"`
+	MFAEnabled       bool   `json:"mfa_enabled" bson:"mfa_enabled,omitempty"`
+	MFASecret        string `json:"-" bson:"mfa_secret,omitempty"`
+	MFAPendingSecret string `json:"-" bson:"mfa_pending_secret,omitempty"`
+	// sha256 of the unused recovery codes
+	MFARecoveryCodes []string `json:"-" bson:"mfa_recovery_codes,omitempty"`
+	// missing on accounts created by Register, those always have a profile
+	ProfileCompleted *bool      `json:"profile_completed" bson:"profile_completed,omitempty"`
+	Identities       []Identity `json:"identities" bson:"identities,omitempty"`
+	Roles            []string   `json:"roles" bson:"roles,omitempty"`
+	// TokenVersion is raised to revoke every token of the user
+	TokenVersion int64 `json:"-" bson:"token_version,omitempty"`
+	// Entitlements is joined in by PackageLookup
+	Entitlements []user.Entitlement `json:"entitlements" bson:"entitlements,omitempty"`
+}
+
+func (u User) IsEmailVerified() bool {
+	return u.EmailVerified == nil || *u.EmailVerified
+}
+
+func (u User) IsProfileCompleted() bool {
+	return u.ProfileCompleted == nil || *u.ProfileCompleted
+}
+
+// Identity links an account of an identity provider to the user.
+type Identity struct {
+	Provider string    `json:"provider" bson:"provider"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email" bson:"email,omitempty"`
+	LinkedAt time.Time `json:"linked_at" bson:"linked_at"`
 }
 
 type Package struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
 	PackageName string             `bson:"package_name,omitempty" binding:"required" json:"package_name"`
 	Description string             `bson:"description,omitempty" binding:"required" json:"description"`
+	Features    []string           `bson:"features,omitempty" json:"features"`
+	Position    int                `bson:"position" json:"position"`
+	ArchivedAt  *time.Time         `bson:"archived_at,omitempty" json:"archived_at"`
+	// BillingPeriod is missing on packages from before prices, those are one-off
+	BillingPeriod string              `bson:"billing_period,omitempty" json:"billing_period"`
+	TrialDays     int                 `bson:"trial_days" json:"trial_days"`
+	Prices        []user.PackagePrice `bson:"prices" json:"prices"`
+	DisplayNames  map[string]string   `bson:"display_names,omitempty" json:"display_names"`
+	CreatedAt     time.Time           `bson:"created_at,omitempty" json:"created_at"`
+	UpdatedAt     time.Time           `bson:"updated_at,omitempty" json:"updated_at"`
 }
 
-type Role struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
-	Rolename    string             `bson:"rolename,omitempty" binding:"required" json:"rolename"`
-	Rolelabel   string             `bson:"rolelabel,omitempty" binding:"required" json:"rolelabel"`
-	Description string             `bson:"description,omitempty" binding:"required" json:"description"`
+type Entitlement struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	PackageID primitive.ObjectID `json:"package_id" bson:"package_id"`
+	PaymentID primitive.ObjectID `json:"payment_id" bson:"payment_id,omitempty"`
+	Status    string             `json:"status" bson:"status"`
+	StartsAt  time.Time          `json:"starts_at" bson:"starts_at"`
+	EndsAt    *time.Time         `json:"ends_at" bson:"ends_at,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	ExpiredAt *time.Time         `json:"expired_at" bson:"expired_at,omitempty"`
+}
+
+type Payment struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	PackageID primitive.ObjectID `json:"package_id" bson:"package_id"`
+	Package   PackageSnapshot    `json:"package" bson:"package"`
+	Status    string             `json:"status" bson:"status"`
+	Amount    int64              `json:"amount" bson:"amount"`
+	Currency  string             `json:"currency" bson:"currency"`
+	PromoCode string             `json:"promo_code" bson:"promo_code,omitempty"`
+	Discount  int64              `json:"discount" bson:"discount,omitempty"`
+	Reference string             `json:"reference" bson:"reference,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+type PromoCode struct {
+	ID             primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	Code           string               `json:"code" bson:"code"`
+	DiscountType   string               `json:"discount_type" bson:"discount_type"`
+	PercentOff     int                  `json:"percent_off" bson:"percent_off,omitempty"`
+	AmountOff      int64                `json:"amount_off" bson:"amount_off,omitempty"`
+	Currency       string               `json:"currency" bson:"currency,omitempty"`
+	StartsAt       *time.Time           `json:"starts_at" bson:"starts_at,omitempty"`
+	EndsAt         *time.Time           `json:"ends_at" bson:"ends_at,omitempty"`
+	MaxRedemptions int64                `json:"max_redemptions" bson:"max_redemptions"`
+	MaxPerUser     int64                `json:"max_per_user" bson:"max_per_user"`
+	PackageIDs     []primitive.ObjectID `json:"package_ids" bson:"package_ids"`
+	Redemptions    int64                `json:"redemptions" bson:"redemptions"`
+	CreatedAt      time.Time            `json:"created_at" bson:"created_at"`
+}
+
+// PromoUsage counts the redemptions of a promo code by one user.
+type PromoUsage struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	PromoCodeID primitive.ObjectID `json:"promo_code_id" bson:"promo_code_id"`
+	UserID      primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Count       int64              `json:"count" bson:"count"`
+}
+
+type PromoRedemption struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	PromoCodeID primitive.ObjectID `json:"promo_code_id" bson:"promo_code_id"`
+	UserID      primitive.ObjectID `json:"user_id" bson:"user_id"`
+	PaymentID   primitive.ObjectID `json:"payment_id" bson:"payment_id"`
+	Discount    int64              `json:"discount" bson:"discount"`
+	Currency    string             `json:"currency" bson:"currency"`
+	Status      string             `json:"status" bson:"status"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+	ReleasedAt  *time.Time         `json:"released_at" bson:"released_at,omitempty"`
+}
+
+type PackageSnapshot struct {
+	PackageName   string   `json:"package_name" bson:"package_name"`
+	Description   string   `json:"description" bson:"description"`
+	Features      []string `json:"features" bson:"features"`
+	BillingPeriod string   `json:"billing_period" bson:"billing_period"`
 }
 
 type RegisterUser struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Fullname string             `json:"fullname" bson:"fullname,omitempty"`
+	Package  string             `json:"package" bson:"package,omitempty"`
+	Email    string             `json:"email" bson:"email,omitempty"`
+	Type     string             `json:"type" bson:"type,omitempty"`
+	Password string             `json:"password" bson:"password,omitempty"`
+	PhotoUrl string             `json:"photo_url" bson:"photo_url,omitempty"`
+	// EmailVerified is stored even when false, a missing field means verified
+	EmailVerified    bool       `json:"email_verified" bson:"email_verified"`
+	ProfileCompleted *bool      `json:"profile_completed" bson:"profile_completed,omitempty"`
+	Identities       []Identity `json:"identities" bson:"identities,omitempty"`
+	Roles            []string   `json:"roles" bson:"roles,omitempty"`
+	CreatedAt        time.Time  `json:"created_at" bson:"created_at,omitempty"`
+	UpdatedAt        time.Time  `json:"updated_at" bson:"updated_at,omitempty"`
+}
+
+type Swipe struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Fullname  string             `json:"fullname" bson:"fullname,omitempty"`
-	Package   string             `json:"package" bson:"package,omitempty"`
+	SwiperID  primitive.ObjectID `json:"swiper_id" bson:"swiper_id"`
+	TargetID  primitive.ObjectID `json:"target_id" bson:"target_id"`
+	Direction string             `json:"direction" bson:"direction"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type Match struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserA       primitive.ObjectID `json:"user_a" bson:"user_a"`
+	UserB       primitive.ObjectID `json:"user_b" bson:"user_b"`
+	Status      string             `json:"status" bson:"status"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+	UnmatchedBy primitive.ObjectID `json:"unmatched_by" bson:"unmatched_by,omitempty"`
+	UnmatchedAt time.Time          `json:"unmatched_at" bson:"unmatched_at,omitempty"`
+}
+
+type Message struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	MatchID     primitive.ObjectID `json:"match_id" bson:"match_id"`
+	SenderID    primitive.ObjectID `json:"sender_id" bson:"sender_id"`
+	RecipientID primitive.ObjectID `json:"recipient_id" bson:"recipient_id"`
+	Body        string             `json:"body" bson:"body"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+	DeliveredAt *time.Time         `json:"delivered_at" bson:"delivered_at,omitempty"`
+	ReadAt      *time.Time         `json:"read_at" bson:"read_at,omitempty"`
+	ArchivedAt  *time.Time         `json:"archived_at" bson:"archived_at,omitempty"`
+}
+
+type Session struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Device     string             `json:"device" bson:"device"`
+	IP         string             `json:"ip" bson:"ip"`
+	UserAgent  string             `json:"user_agent" bson:"user_agent"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	LastSeenAt time.Time          `json:"last_seen_at" bson:"last_seen_at"`
+	ExpiresAt  time.Time          `json:"expires_at" bson:"expires_at"`
+	RevokedAt  *time.Time         `json:"revoked_at" bson:"revoked_at,omitempty"`
+}
+
+type AuditEvent struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Type      string             `json:"type" bson:"type"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id,omitempty"`
 	Email     string             `json:"email" bson:"email,omitempty"`
-	Type      string             `json:"type" bson:"type,omitempty"`
-	Password  string             `json:"password" bson:"password,omitempty"`
-	PhotoUrl  string             `json:"photo_url" bson:"photo_url,omitempty"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at,omitempty"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	IP        string             `json:"ip" bson:"ip,omitempty"`
+	Detail    string             `json:"detail" bson:"detail,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 }
 
 type FilterQuery bson.M
@@ -54,13 +215,9 @@ func (q FilterQuery) SetDeleteAtExists(exist bool) FilterQuery {
 	return q
 }
 
-func (q FilterQuery) SetRole() FilterQuery {
-	q["$lookup"] = bson.M{
-		"from":         "role",
-		"localField":   "role_id",
-		"foreignField": "id",
-		"as":           "role",
-	}
+// SetRole matches users holding the role.
+func (q FilterQuery) SetRole(role string) FilterQuery {
+	q["roles"] = role
 	return q
 }
 
@@ -99,11 +256,6 @@ func (q FilterQuery) SetSortAscending(field string) FilterQuery {
 	return q
 }
 
-func (q FilterQuery) SetRoleID(id int) FilterQuery {
-	q["role_id"] = id
-	return q
-}
-
 func (q FilterQuery) SetPassword(password string) FilterQuery {
 	q["password"] = password
 	return q
@@ -0,0 +1,34 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/utils"
+)
+
+func RepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig) user.PaymentRepository {
+	paymentRepo := NewMongoRepository(dbCon, conf)
+	return paymentRepo
+}
+
+// ProviderFactory builds the payment provider named by PAYMENT_PROVIDER, nil
+// when payments are not configured. The fake provider grants packages without
+// charging anyone, it is only built when PAYMENT_ALLOW_FAKE is set.
+func ProviderFactory(conf *config.AppConfig) (user.PaymentProvider, error) {
+	switch conf.Payment.Provider {
+	case "":
+		return nil, nil
+	case "fake":
+		if !conf.Payment.AllowFake {
+			return nil, errors.New("payment provider fake is for development only, set PAYMENT_ALLOW_FAKE=true to use it")
+		}
+		provider, err := NewFakeProvider(conf.Payment.WebhookSecret, conf.Payment.CheckoutURL)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", conf.Payment.Provider)
+}
@@ -0,0 +1,60 @@
+package payment_test
+
+import (
+	"roby-backend-golang/config"
+	repoPayment "roby-backend-golang/repository/payment"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderFactory(t *testing.T) {
+	t.Run("Payments Disabled Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		provider, err := repoPayment.ProviderFactory(&config.AppConfig{})
+		asserting.NoError(err)
+		asserting.Nil(provider)
+	})
+
+	t.Run("Fake Provider Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		conf := &config.AppConfig{}
+		conf.Payment.Provider = "fake"
+		conf.Payment.WebhookSecret = "secret"
+		conf.Payment.AllowFake = true
+
+		provider, err := repoPayment.ProviderFactory(conf)
+		asserting.NoError(err)
+		asserting.NotNil(provider)
+	})
+
+	t.Run("Fake Provider Not Allowed Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		conf := &config.AppConfig{}
+		conf.Payment.Provider = "fake"
+		conf.Payment.WebhookSecret = "secret"
+
+		_, err := repoPayment.ProviderFactory(conf)
+		asserting.Error(err)
+	})
+
+	t.Run("Empty Webhook Secret Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		conf := &config.AppConfig{}
+		conf.Payment.Provider = "fake"
+		conf.Payment.AllowFake = true
+
+		_, err := repoPayment.ProviderFactory(conf)
+		asserting.Error(err)
+	})
+
+	t.Run("Unknown Provider Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		conf := &config.AppConfig{}
+		conf.Payment.Provider = "stripe"
+		conf.Payment.WebhookSecret = "secret"
+
+		_, err := repoPayment.ProviderFactory(conf)
+		asserting.Error(err)
+	})
+}
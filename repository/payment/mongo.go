@@ -0,0 +1,244 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/repository"
+	"roby-backend-golang/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBRepository struct {
+	colPayment *mongo.Collection
+	conf       *config.AppConfig
+}
+
+func NewMongoRepository(dbCon *utils.DatabaseConnection, conf *config.AppConfig) *MongoDBRepository {
+	repo := &MongoDBRepository{
+		colPayment: dbCon.MongoDB.Collection("payment"),
+		conf:       conf,
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+// ensureIndexes finds the payment of a webhook by the reference of the
+// provider, a reference belongs to one payment, and serves the expiry of
+// pending payments.
+func (repo *MongoDBRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repo.colPayment.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "reference", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"reference": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func toBusinessPayment(payment repository.Payment) businessUser.Payment {
+	return businessUser.Payment{
+		ID:        payment.ID.Hex(),
+		UserID:    payment.UserID.Hex(),
+		PackageID: payment.PackageID.Hex(),
+		Package: businessUser.PackageSnapshot{
+			PackageName:   payment.Package.PackageName,
+			Description:   payment.Package.Description,
+			Features:      payment.Package.Features,
+			BillingPeriod: payment.Package.BillingPeriod,
+		},
+		Status:    payment.Status,
+		Amount:    payment.Amount,
+		Currency:  payment.Currency,
+		PromoCode: payment.PromoCode,
+		Discount:  payment.Discount,
+		Reference: payment.Reference,
+		CreatedAt: payment.CreatedAt,
+	}
+}
+
+func (repo *MongoDBRepository) CreatePayment(data businessUser.Payment) (businessUser.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(data.UserID)
+	if err != nil {
+		return businessUser.Payment{}, errors.New("invalid id")
+	}
+	packageID, err := primitive.ObjectIDFromHex(data.PackageID)
+	if err != nil {
+		return businessUser.Payment{}, errors.New("invalid id")
+	}
+
+	payment := repository.Payment{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		PackageID: packageID,
+		Package: repository.PackageSnapshot{
+			PackageName:   data.Package.PackageName,
+			Description:   data.Package.Description,
+			Features:      data.Package.Features,
+			BillingPeriod: data.Package.BillingPeriod,
+		},
+		Status:    data.Status,
+		Amount:    data.Amount,
+		Currency:  data.Currency,
+		PromoCode: data.PromoCode,
+		Discount:  data.Discount,
+		CreatedAt: data.CreatedAt,
+		UpdatedAt: data.CreatedAt,
+	}
+	_, err = repo.colPayment.InsertOne(ctx, payment)
+	if err != nil {
+		return businessUser.Payment{}, err
+	}
+
+	return toBusinessPayment(payment), nil
+}
+
+func (repo *MongoDBRepository) FindPaymentByID(id string) (businessUser.Payment, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return businessUser.Payment{}, utils.HandleError(404, "payment not found")
+	}
+	return repo.findPayment(bson.M{"_id": objID})
+}
+
+func (repo *MongoDBRepository) FindPaymentByReference(reference string) (businessUser.Payment, error) {
+	return repo.findPayment(bson.M{"reference": reference})
+}
+
+func (repo *MongoDBRepository) findPayment(filter bson.M) (businessUser.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var payment repository.Payment
+	err := repo.colPayment.FindOne(ctx, filter).Decode(&payment)
+	if err == mongo.ErrNoDocuments {
+		return businessUser.Payment{}, utils.HandleError(404, "payment not found")
+	}
+	if err != nil {
+		return businessUser.Payment{}, err
+	}
+
+	return toBusinessPayment(payment), nil
+}
+
+func (repo *MongoDBRepository) SetPaymentReference(id, reference string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	update := bson.M{"$set": bson.M{"reference": reference, "updated_at": time.Now()}}
+	_, err = repo.colPayment.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	return err
+}
+
+func (repo *MongoDBRepository) UpdatePaymentStatus(id, from, to string, at time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errors.New("invalid id")
+	}
+
+	filter := bson.M{"_id": objID, "status": from}
+	update := bson.M{"$set": bson.M{"status": to, "updated_at": at}}
+	res, err := repo.colPayment.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount == 1, nil
+}
+
+func (repo *MongoDBRepository) GetPaymentsByUser(userID string, page businessUser.Pagination) ([]businessUser.Payment, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, 0, errors.New("invalid id")
+	}
+
+	filter := bson.M{"user_id": objID}
+	total, err := repo.colPayment.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page.Page - 1) * page.Limit)).
+		SetLimit(int64(page.Limit))
+	cursor, err := repo.colPayment.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var payments []repository.Payment
+	err = cursor.All(ctx, &payments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res := make([]businessUser.Payment, 0, len(payments))
+	for _, payment := range payments {
+		res = append(res, toBusinessPayment(payment))
+	}
+	return res, total, nil
+}
+
+func (repo *MongoDBRepository) ExpirePendingPayments(before, at time.Time, limit int) ([]businessUser.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var expired []businessUser.Payment
+	filter := bson.M{
+		"status":     businessUser.PaymentPending,
+		"created_at": bson.M{"$lt": before},
+	}
+	update := bson.M{"$set": bson.M{
+		"status":     businessUser.PaymentExpired,
+		"updated_at": at,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	// one payment at a time so every instance claims different ones
+	for len(expired) < limit {
+		var payment repository.Payment
+		err := repo.colPayment.FindOneAndUpdate(ctx, filter, update, opts).Decode(&payment)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired = append(expired, toBusinessPayment(payment))
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,47 @@
+package payment
+
+import (
+	businessUser "roby-backend-golang/business/user"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type PaymentMock struct {
+	*mock.Mock
+}
+
+func (m *PaymentMock) CreatePayment(data businessUser.Payment) (businessUser.Payment, error) {
+	args := m.Called(data)
+	return args.Get(0).(businessUser.Payment), args.Error(1)
+}
+
+func (m *PaymentMock) FindPaymentByID(id string) (businessUser.Payment, error) {
+	args := m.Called(id)
+	return args.Get(0).(businessUser.Payment), args.Error(1)
+}
+
+func (m *PaymentMock) FindPaymentByReference(reference string) (businessUser.Payment, error) {
+	args := m.Called(reference)
+	return args.Get(0).(businessUser.Payment), args.Error(1)
+}
+
+func (m *PaymentMock) SetPaymentReference(id, reference string) error {
+	args := m.Called(id, reference)
+	return args.Error(0)
+}
+
+func (m *PaymentMock) UpdatePaymentStatus(id, from, to string, at time.Time) (bool, error) {
+	args := m.Called(id, from, to, at)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *PaymentMock) GetPaymentsByUser(userID string, page businessUser.Pagination) ([]businessUser.Payment, int64, error) {
+	args := m.Called(userID, page)
+	return args.Get(0).([]businessUser.Payment), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *PaymentMock) ExpirePendingPayments(before, at time.Time, limit int) ([]businessUser.Payment, error) {
+	args := m.Called(before, at, limit)
+	return args.Get(0).([]businessUser.Payment), args.Error(1)
+}
@@ -0,0 +1,12 @@
+package promo
+
+import (
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/utils"
+)
+
+func RepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig) user.PromoRepository {
+	promoRepo := NewMongoRepository(dbCon, conf)
+	return promoRepo
+}
@@ -0,0 +1,437 @@
+package promo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/repository"
+	"roby-backend-golang/utils"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBRepository struct {
+	colPromo      *mongo.Collection
+	colUsage      *mongo.Collection
+	colRedemption *mongo.Collection
+	colPayment    *mongo.Collection
+	conf          *config.AppConfig
+}
+
+func NewMongoRepository(dbCon *utils.DatabaseConnection, conf *config.AppConfig) *MongoDBRepository {
+	repo := &MongoDBRepository{
+		colPromo:      dbCon.MongoDB.Collection("promo_code"),
+		colUsage:      dbCon.MongoDB.Collection("promo_usage"),
+		colRedemption: dbCon.MongoDB.Collection("promo_redemption"),
+		colPayment:    dbCon.MongoDB.Collection("payment"),
+		conf:          conf,
+	}
+	repo.ensureIndexes()
+	repo.confirmPaidRedemptions()
+	return repo
+}
+
+// ensureIndexes keeps codes unique, counts the redemptions of a user in one
+// document per code and serves the usage report.
+func (repo *MongoDBRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repo.colPromo.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = repo.colUsage.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "promo_code_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = repo.colRedemption.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "promo_code_id", Value: 1}, {Key: "status", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "payment_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// confirmPaidRedemptions confirms the redemptions still reserved by a payment
+// that was paid, those from before redemptions were confirmed and those whose
+// confirmation did not go through.
+func (repo *MongoDBRepository) confirmPaidRedemptions() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"status": businessUser.PromoRedeemed}},
+		bson.M{"$lookup": bson.M{
+			"from":         repo.colPayment.Name(),
+			"localField":   "payment_id",
+			"foreignField": "_id",
+			"as":           "payment",
+		}},
+		bson.M{"$match": bson.M{"payment.status": businessUser.PaymentPaid}},
+		bson.M{"$project": bson.M{"_id": 1}},
+	}
+	cursor, err := repo.colRedemption.Aggregate(ctx, pipeline)
+	if err != nil {
+		panic(err)
+	}
+	var redemptions []repository.PromoRedemption
+	err = cursor.All(ctx, &redemptions)
+	if err != nil {
+		panic(err)
+	}
+	if len(redemptions) == 0 {
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(redemptions))
+	for _, redemption := range redemptions {
+		ids = append(ids, redemption.ID)
+	}
+	res, err := repo.colRedemption.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "status": businessUser.PromoRedeemed},
+		bson.M{"$set": bson.M{"status": businessUser.PromoPaid}},
+	)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("confirmed promo code redemptions of paid payments:", res.ModifiedCount)
+}
+
+func toBusinessPromoCode(promo repository.PromoCode) businessUser.PromoCode {
+	packageIDs := make([]string, 0, len(promo.PackageIDs))
+	for _, id := range promo.PackageIDs {
+		packageIDs = append(packageIDs, id.Hex())
+	}
+
+	return businessUser.PromoCode{
+		ID:             promo.ID.Hex(),
+		Code:           promo.Code,
+		DiscountType:   promo.DiscountType,
+		PercentOff:     promo.PercentOff,
+		AmountOff:      promo.AmountOff,
+		Currency:       promo.Currency,
+		StartsAt:       promo.StartsAt,
+		EndsAt:         promo.EndsAt,
+		MaxRedemptions: promo.MaxRedemptions,
+		MaxPerUser:     promo.MaxPerUser,
+		PackageIDs:     packageIDs,
+		Redemptions:    promo.Redemptions,
+		CreatedAt:      promo.CreatedAt,
+	}
+}
+
+func (repo *MongoDBRepository) CreatePromoCode(data businessUser.PromoCode) (businessUser.PromoCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	packageIDs := make([]primitive.ObjectID, 0, len(data.PackageIDs))
+	for _, id := range data.PackageIDs {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return businessUser.PromoCode{}, errors.New("invalid id")
+		}
+		packageIDs = append(packageIDs, objID)
+	}
+
+	promo := repository.PromoCode{
+		ID:             primitive.NewObjectID(),
+		Code:           data.Code,
+		DiscountType:   data.DiscountType,
+		PercentOff:     data.PercentOff,
+		AmountOff:      data.AmountOff,
+		Currency:       data.Currency,
+		StartsAt:       data.StartsAt,
+		EndsAt:         data.EndsAt,
+		MaxRedemptions: data.MaxRedemptions,
+		MaxPerUser:     data.MaxPerUser,
+		PackageIDs:     packageIDs,
+		CreatedAt:      data.CreatedAt,
+	}
+	_, err := repo.colPromo.InsertOne(ctx, promo)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return businessUser.PromoCode{}, utils.HandleError(400, "promo code already exists")
+		}
+		return businessUser.PromoCode{}, err
+	}
+
+	return toBusinessPromoCode(promo), nil
+}
+
+func (repo *MongoDBRepository) GetPromoCodes() ([]businessUser.PromoCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := repo.colPromo.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var promos []repository.PromoCode
+	err = cursor.All(ctx, &promos)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]businessUser.PromoCode, 0, len(promos))
+	for _, promo := range promos {
+		res = append(res, toBusinessPromoCode(promo))
+	}
+	return res, nil
+}
+
+func (repo *MongoDBRepository) FindPromoCodeByID(id string) (businessUser.PromoCode, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return businessUser.PromoCode{}, utils.HandleError(404, "promo code not found")
+	}
+	return repo.findPromoCode(bson.M{"_id": objID})
+}
+
+func (repo *MongoDBRepository) FindPromoCodeByCode(code string) (businessUser.PromoCode, error) {
+	return repo.findPromoCode(bson.M{"code": code})
+}
+
+func (repo *MongoDBRepository) findPromoCode(filter bson.M) (businessUser.PromoCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var promo repository.PromoCode
+	err := repo.colPromo.FindOne(ctx, filter).Decode(&promo)
+	if err == mongo.ErrNoDocuments {
+		return businessUser.PromoCode{}, utils.HandleError(404, "promo code not found")
+	}
+	if err != nil {
+		return businessUser.PromoCode{}, err
+	}
+
+	return toBusinessPromoCode(promo), nil
+}
+
+// RedeemPromoCode takes a redemption from the user's allowance and then from
+// the code's, each with an update that only matches below the limit, and
+// gives the first back when the second is used up.
+func (repo *MongoDBRepository) RedeemPromoCode(promo businessUser.PromoCode, data businessUser.PromoRedemption) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	promoID, err := primitive.ObjectIDFromHex(promo.ID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+	userID, err := primitive.ObjectIDFromHex(data.UserID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+	paymentID, err := primitive.ObjectIDFromHex(data.PaymentID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	usage := bson.M{"promo_code_id": promoID, "user_id": userID}
+	if promo.MaxPerUser > 0 {
+		// at the limit the filter misses and the upsert runs into the unique
+		// index
+		filter := bson.M{"promo_code_id": promoID, "user_id": userID, "count": bson.M{"$lt": promo.MaxPerUser}}
+		_, err = repo.colUsage.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"count": 1}}, options.Update().SetUpsert(true))
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.HandleError(400, "promo code already redeemed")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	releaseUsage := func() {
+		if promo.MaxPerUser > 0 {
+			_, _ = repo.colUsage.UpdateOne(ctx, usage, bson.M{"$inc": bson.M{"count": -1}})
+		}
+	}
+
+	filter := bson.M{"_id": promoID}
+	if promo.MaxRedemptions > 0 {
+		filter["redemptions"] = bson.M{"$lt": promo.MaxRedemptions}
+	}
+	res, err := repo.colPromo.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"redemptions": 1}})
+	if err != nil {
+		releaseUsage()
+		return err
+	}
+	if res.MatchedCount == 0 {
+		releaseUsage()
+		return utils.HandleError(400, "promo code fully redeemed")
+	}
+
+	_, err = repo.colRedemption.InsertOne(ctx, repository.PromoRedemption{
+		ID:          primitive.NewObjectID(),
+		PromoCodeID: promoID,
+		UserID:      userID,
+		PaymentID:   paymentID,
+		Discount:    data.Discount,
+		Currency:    data.Currency,
+		Status:      data.Status,
+		CreatedAt:   data.CreatedAt,
+	})
+	if err != nil {
+		_, _ = repo.colPromo.UpdateOne(ctx, bson.M{"_id": promoID}, bson.M{"$inc": bson.M{"redemptions": -1}})
+		releaseUsage()
+		return err
+	}
+
+	return nil
+}
+
+func (repo *MongoDBRepository) ReleasePromoRedemption(paymentID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(paymentID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	// claiming the redemption first makes a second release find nothing
+	now := time.Now()
+	var redemption repository.PromoRedemption
+	err = repo.colRedemption.FindOneAndUpdate(ctx,
+		bson.M{"payment_id": objID, "status": businessUser.PromoRedeemed},
+		bson.M{"$set": bson.M{"status": businessUser.PromoReleased, "released_at": now}},
+	).Decode(&redemption)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// a release that fails half way hands the redemption back, so a retry
+	// claims and releases it again
+	unclaim := func() {
+		_, _ = repo.colRedemption.UpdateOne(ctx,
+			bson.M{"_id": redemption.ID},
+			bson.M{"$set": bson.M{"status": businessUser.PromoRedeemed}, "$unset": bson.M{"released_at": ""}},
+		)
+	}
+
+	_, err = repo.colPromo.UpdateOne(ctx, bson.M{"_id": redemption.PromoCodeID}, bson.M{"$inc": bson.M{"redemptions": -1}})
+	if err != nil {
+		unclaim()
+		return err
+	}
+
+	filter := bson.M{"promo_code_id": redemption.PromoCodeID, "user_id": redemption.UserID, "count": bson.M{"$gt": 0}}
+	_, err = repo.colUsage.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"count": -1}})
+	if err != nil {
+		_, _ = repo.colPromo.UpdateOne(ctx, bson.M{"_id": redemption.PromoCodeID}, bson.M{"$inc": bson.M{"redemptions": 1}})
+		unclaim()
+		return err
+	}
+
+	return nil
+}
+
+func (repo *MongoDBRepository) ConfirmPromoRedemption(paymentID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(paymentID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	_, err = repo.colRedemption.UpdateOne(ctx,
+		bson.M{"payment_id": objID, "status": businessUser.PromoRedeemed},
+		bson.M{"$set": bson.M{"status": businessUser.PromoPaid}},
+	)
+	return err
+}
+
+func (repo *MongoDBRepository) GetPromoCodeUsage(id string) (businessUser.PromoCodeUsage, error) {
+	promo, err := repo.FindPromoCodeByID(id)
+	if err != nil {
+		return businessUser.PromoCodeUsage{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, _ := primitive.ObjectIDFromHex(promo.ID)
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"promo_code_id": objID,
+			"status":        bson.M{"$in": bson.A{businessUser.PromoRedeemed, businessUser.PromoPaid}},
+		}},
+		bson.M{"$group": bson.M{
+			"_id":         bson.M{"currency": "$currency", "status": "$status"},
+			"redemptions": bson.M{"$sum": 1},
+			"discount":    bson.M{"$sum": "$discount"},
+			"users":       bson.M{"$addToSet": "$user_id"},
+		}},
+	}
+	cursor, err := repo.colRedemption.Aggregate(ctx, pipeline)
+	if err != nil {
+		return businessUser.PromoCodeUsage{}, err
+	}
+
+	var groups []struct {
+		ID struct {
+			Currency string `bson:"currency"`
+			Status   string `bson:"status"`
+		} `bson:"_id"`
+		Redemptions int64                `bson:"redemptions"`
+		Discount    int64                `bson:"discount"`
+		Users       []primitive.ObjectID `bson:"users"`
+	}
+	err = cursor.All(ctx, &groups)
+	if err != nil {
+		return businessUser.PromoCodeUsage{}, err
+	}
+
+	usage := businessUser.PromoCodeUsage{
+		PromoCode: promo,
+		Discounts: []businessUser.PackagePrice{},
+	}
+	users := map[primitive.ObjectID]bool{}
+	for _, group := range groups {
+		// a reserved redemption is only counted, it gave no discount yet
+		if group.ID.Status == businessUser.PromoRedeemed {
+			usage.Reserved += group.Redemptions
+			continue
+		}
+		usage.Redemptions += group.Redemptions
+		usage.Discounts = append(usage.Discounts, businessUser.PackagePrice{
+			Currency: group.ID.Currency,
+			Amount:   group.Discount,
+		})
+		for _, user := range group.Users {
+			users[user] = true
+		}
+	}
+	usage.Users = int64(len(users))
+	sort.Slice(usage.Discounts, func(i, j int) bool {
+		return usage.Discounts[i].Currency < usage.Discounts[j].Currency
+	})
+
+	return usage, nil
+}
@@ -0,0 +1,51 @@
+package promo
+
+import (
+	businessUser "roby-backend-golang/business/user"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type PromoMock struct {
+	*mock.Mock
+}
+
+func (m *PromoMock) CreatePromoCode(data businessUser.PromoCode) (businessUser.PromoCode, error) {
+	args := m.Called(data)
+	return args.Get(0).(businessUser.PromoCode), args.Error(1)
+}
+
+func (m *PromoMock) GetPromoCodes() ([]businessUser.PromoCode, error) {
+	args := m.Called()
+	return args.Get(0).([]businessUser.PromoCode), args.Error(1)
+}
+
+func (m *PromoMock) FindPromoCodeByID(id string) (businessUser.PromoCode, error) {
+	args := m.Called(id)
+	return args.Get(0).(businessUser.PromoCode), args.Error(1)
+}
+
+func (m *PromoMock) FindPromoCodeByCode(code string) (businessUser.PromoCode, error) {
+	args := m.Called(code)
+	return args.Get(0).(businessUser.PromoCode), args.Error(1)
+}
+
+func (m *PromoMock) RedeemPromoCode(promo businessUser.PromoCode, data businessUser.PromoRedemption) error {
+	args := m.Called(promo, data)
+	return args.Error(0)
+}
+
+func (m *PromoMock) ReleasePromoRedemption(paymentID string) error {
+	args := m.Called(paymentID)
+	return args.Error(0)
+}
+
+func (m *PromoMock) ConfirmPromoRedemption(paymentID string) error {
+	args := m.Called(paymentID)
+	return args.Error(0)
+}
+
+func (m *PromoMock) GetPromoCodeUsage(id string) (businessUser.PromoCodeUsage, error) {
+	args := m.Called(id)
+	return args.Get(0).(businessUser.PromoCodeUsage), args.Error(1)
+}
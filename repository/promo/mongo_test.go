@@ -0,0 +1,88 @@
+package promo_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/repository"
+	repoPromo "roby-backend-golang/repository/promo"
+	"roby-backend-golang/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestDatabase connects to the mongodb at MONGO_TEST_URI and hands out a
+// database of its own that is dropped after the test.
+func newTestDatabase(t *testing.T) *utils.DatabaseConnection {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := client.Database(fmt.Sprintf("promo_test_%s", primitive.NewObjectID().Hex()))
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+	return &utils.DatabaseConnection{Driver: utils.MongoDB, MongoDB: db}
+}
+
+func TestGetPromoCodeUsage(t *testing.T) {
+	t.Run("Paid And Reserved Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		dbCon := newTestDatabase(t)
+		ctx := context.Background()
+		now := time.Now()
+
+		promoID := primitive.NewObjectID()
+		paidUser := primitive.NewObjectID()
+		pendingUser := primitive.NewObjectID()
+		paidPayment := primitive.NewObjectID()
+		legacyPayment := primitive.NewObjectID()
+		pendingPayment := primitive.NewObjectID()
+		_, err := dbCon.MongoDB.Collection("promo_code").InsertOne(ctx, repository.PromoCode{ID: promoID, Code: "SAVE20", DiscountType: businessUser.PromoPercent, PercentOff: 20, Redemptions: 3})
+		asserting.NoError(err)
+		_, err = dbCon.MongoDB.Collection("payment").InsertMany(ctx, []interface{}{
+			repository.Payment{ID: paidPayment, UserID: paidUser, Status: businessUser.PaymentPaid, CreatedAt: now},
+			repository.Payment{ID: legacyPayment, UserID: paidUser, Status: businessUser.PaymentPaid, CreatedAt: now},
+			repository.Payment{ID: pendingPayment, UserID: pendingUser, Status: businessUser.PaymentPending, CreatedAt: now},
+		})
+		asserting.NoError(err)
+		_, err = dbCon.MongoDB.Collection("promo_redemption").InsertMany(ctx, []interface{}{
+			// paid before redemptions were confirmed
+			repository.PromoRedemption{ID: primitive.NewObjectID(), PromoCodeID: promoID, UserID: paidUser, PaymentID: legacyPayment, Discount: 200, Currency: "USD", Status: businessUser.PromoRedeemed, CreatedAt: now},
+			repository.PromoRedemption{ID: primitive.NewObjectID(), PromoCodeID: promoID, UserID: pendingUser, PaymentID: pendingPayment, Discount: 200, Currency: "USD", Status: businessUser.PromoRedeemed, CreatedAt: now},
+		})
+		asserting.NoError(err)
+
+		repo := repoPromo.NewMongoRepository(dbCon, &config.AppConfig{})
+		// paid since the start
+		_, err = dbCon.MongoDB.Collection("promo_redemption").InsertOne(ctx,
+			repository.PromoRedemption{ID: primitive.NewObjectID(), PromoCodeID: promoID, UserID: paidUser, PaymentID: paidPayment, Discount: 200, Currency: "USD", Status: businessUser.PromoRedeemed, CreatedAt: now})
+		asserting.NoError(err)
+		asserting.NoError(repo.ConfirmPromoRedemption(paidPayment.Hex()))
+		// a second confirmation changes nothing
+		asserting.NoError(repo.ConfirmPromoRedemption(paidPayment.Hex()))
+
+		usage, err := repo.GetPromoCodeUsage(promoID.Hex())
+		asserting.NoError(err)
+		asserting.Equal(int64(2), usage.Redemptions)
+		asserting.Equal(int64(1), usage.Reserved)
+		asserting.Equal(int64(1), usage.Users)
+		asserting.Equal([]businessUser.PackagePrice{{Currency: "USD", Amount: 400}}, usage.Discounts)
+	})
+}
@@ -0,0 +1,12 @@
+package session
+
+import (
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/utils"
+)
+
+func RepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig) user.SessionRepository {
+	sessionRepo := NewMongoRepository(dbCon, conf)
+	return sessionRepo
+}
@@ -0,0 +1,195 @@
+package session
+
+import (
+	"context"
+	"errors"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/repository"
+	"roby-backend-golang/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBRepository struct {
+	colSession *mongo.Collection
+	conf       *config.AppConfig
+}
+
+func NewMongoRepository(dbCon *utils.DatabaseConnection, conf *config.AppConfig) *MongoDBRepository {
+	repo := &MongoDBRepository{
+		colSession: dbCon.MongoDB.Collection("session"),
+		conf:       conf,
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+// ensureIndexes lets mongo drop a session once its last refresh token expired.
+func (repo *MongoDBRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repo.colSession.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_seen_at", Value: -1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func toBusinessSession(session repository.Session) businessUser.Session {
+	return businessUser.Session{
+		ID:         session.ID.Hex(),
+		UserID:     session.UserID.Hex(),
+		Device:     session.Device,
+		IP:         session.IP,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
+
+func (repo *MongoDBRepository) CreateSession(data businessUser.Session) (businessUser.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(data.UserID)
+	if err != nil {
+		return businessUser.Session{}, errors.New("invalid id")
+	}
+
+	session := repository.Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		Device:     data.Device,
+		IP:         data.IP,
+		UserAgent:  data.UserAgent,
+		CreatedAt:  data.CreatedAt,
+		LastSeenAt: data.CreatedAt,
+		ExpiresAt:  data.CreatedAt.Add(utils.RefreshTokenExpired * time.Second),
+	}
+
+	_, err = repo.colSession.InsertOne(ctx, session)
+	if err != nil {
+		return businessUser.Session{}, err
+	}
+
+	return toBusinessSession(session), nil
+}
+
+// GetSessions lists the sessions of the user that are neither revoked nor
+// expired at the given time, most recently used first.
+func (repo *MongoDBRepository) GetSessions(userID string, at time.Time) ([]businessUser.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sessions := []businessUser.Session{}
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return sessions, errors.New("invalid id")
+	}
+
+	filter := bson.M{
+		"user_id":    objID,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": at},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "last_seen_at", Value: -1}})
+
+	cur, err := repo.colSession.Find(ctx, filter, opts)
+	if err != nil {
+		return sessions, err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var session repository.Session
+		err = cur.Decode(&session)
+		if err != nil {
+			return sessions, err
+		}
+		sessions = append(sessions, toBusinessSession(session))
+	}
+
+	return sessions, cur.Err()
+}
+
+// TouchSession records a refresh of the session, which also pushes back its
+// expiry because the rotated refresh token lives longer.
+func (repo *MongoDBRepository) TouchSession(id string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	filter := bson.M{"_id": objID, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{
+		"last_seen_at": at,
+		"expires_at":   at.Add(utils.RefreshTokenExpired * time.Second),
+	}}
+
+	_, err = repo.colSession.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (repo *MongoDBRepository) RevokeSession(id, userID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return utils.HandleError(404, "session not found")
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	filter := bson.M{
+		"_id":        objID,
+		"user_id":    userObjID,
+		"revoked_at": bson.M{"$exists": false},
+	}
+	update := bson.M{"$set": bson.M{"revoked_at": at}}
+
+	res, err := repo.colSession.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return utils.HandleError(404, "session not found")
+	}
+
+	return nil
+}
+
+func (repo *MongoDBRepository) RevokeSessions(userID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	filter := bson.M{"user_id": objID, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revoked_at": at}}
+
+	_, err = repo.colSession.UpdateMany(ctx, filter, update)
+	return err
+}
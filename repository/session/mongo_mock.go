@@ -0,0 +1,37 @@
+package session
+
+import (
+	businessUser "roby-backend-golang/business/user"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type SessionMock struct {
+	*mock.Mock
+}
+
+func (m *SessionMock) CreateSession(data businessUser.Session) (businessUser.Session, error) {
+	args := m.Called(data)
+	return args.Get(0).(businessUser.Session), args.Error(1)
+}
+
+func (m *SessionMock) GetSessions(userID string, at time.Time) ([]businessUser.Session, error) {
+	args := m.Called(userID, at)
+	return args.Get(0).([]businessUser.Session), args.Error(1)
+}
+
+func (m *SessionMock) TouchSession(id string, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+func (m *SessionMock) RevokeSession(id, userID string, at time.Time) error {
+	args := m.Called(id, userID, at)
+	return args.Error(0)
+}
+
+func (m *SessionMock) RevokeSessions(userID string, at time.Time) error {
+	args := m.Called(userID, at)
+	return args.Error(0)
+}
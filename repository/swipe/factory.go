@@ -0,0 +1,12 @@
+package swipe
+
+import (
+	"roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/utils"
+)
+
+func RepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig) user.SwipeRepository {
+	swipeRepo := NewMongoRepository(dbCon, conf)
+	return swipeRepo
+}
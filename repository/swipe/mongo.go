@@ -0,0 +1,153 @@
+package swipe
+
+import (
+	"context"
+	"errors"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/config"
+	"roby-backend-golang/repository"
+	"roby-backend-golang/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBRepository struct {
+	colSwipe *mongo.Collection
+	conf     *config.AppConfig
+}
+
+func NewMongoRepository(dbCon *utils.DatabaseConnection, conf *config.AppConfig) *MongoDBRepository {
+	repo := &MongoDBRepository{
+		colSwipe: dbCon.MongoDB.Collection("swipe"),
+		conf:     conf,
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+// ensureIndexes makes a user able to swipe another user only once and keeps
+// the daily counter query on an index.
+func (repo *MongoDBRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repo.colSwipe.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "swiper_id", Value: 1}, {Key: "target_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "swiper_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (repo *MongoDBRepository) CreateSwipe(data businessUser.Swipe) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	swiperID, err := primitive.ObjectIDFromHex(data.SwiperID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+	targetID, err := primitive.ObjectIDFromHex(data.TargetID)
+	if err != nil {
+		return utils.HandleError(400, "invalid id swipe")
+	}
+
+	insSwipe := repository.Swipe{
+		ID:        primitive.NewObjectID(),
+		SwiperID:  swiperID,
+		TargetID:  targetID,
+		Direction: data.Direction,
+		CreatedAt: data.CreatedAt,
+	}
+
+	_, err = repo.colSwipe.InsertOne(ctx, insSwipe)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.HandleError(400, "already swipe")
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (repo *MongoDBRepository) CountSwipeSince(swiperID string, since time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(swiperID)
+	if err != nil {
+		return 0, errors.New("invalid id")
+	}
+
+	filter := bson.M{
+		"swiper_id":  objID,
+		"created_at": bson.M{"$gte": since},
+	}
+
+	return repo.colSwipe.CountDocuments(ctx, filter)
+}
+
+// GetSwipedUserIDs returns the ids of every user the swiper swiped, left or
+// right, the swipe index covers the query.
+func (repo *MongoDBRepository) GetSwipedUserIDs(swiperID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var ids []string
+
+	objID, err := primitive.ObjectIDFromHex(swiperID)
+	if err != nil {
+		return ids, errors.New("invalid id")
+	}
+
+	targets, err := repo.colSwipe.Distinct(ctx, "target_id", bson.M{"swiper_id": objID})
+	if err != nil {
+		return ids, err
+	}
+
+	for _, target := range targets {
+		if targetID, ok := target.(primitive.ObjectID); ok {
+			ids = append(ids, targetID.Hex())
+		}
+	}
+
+	return ids, nil
+}
+
+func (repo *MongoDBRepository) IsLiked(swiperID, targetID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	swiperObjID, err := primitive.ObjectIDFromHex(swiperID)
+	if err != nil {
+		return false, errors.New("invalid id")
+	}
+	targetObjID, err := primitive.ObjectIDFromHex(targetID)
+	if err != nil {
+		return false, errors.New("invalid id")
+	}
+
+	filter := bson.M{
+		"swiper_id": swiperObjID,
+		"target_id": targetObjID,
+		"direction": "like",
+	}
+
+	count, err := repo.colSwipe.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
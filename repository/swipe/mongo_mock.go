@@ -0,0 +1,32 @@
+package swipe
+
+import (
+	businessUser "roby-backend-golang/business/user"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type SwipeMock struct {
+	*mock.Mock
+}
+
+func (m *SwipeMock) CreateSwipe(data businessUser.Swipe) error {
+	args := m.Called(data)
+	return args.Error(0)
+}
+
+func (m *SwipeMock) CountSwipeSince(swiperID string, since time.Time) (int64, error) {
+	args := m.Called(swiperID, since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *SwipeMock) GetSwipedUserIDs(swiperID string) ([]string, error) {
+	args := m.Called(swiperID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *SwipeMock) IsLiked(swiperID, targetID string) (bool, error) {
+	args := m.Called(swiperID, targetID)
+	return args.Bool(0), args.Error(1)
+}
@@ -6,7 +6,7 @@ import (
 	"roby-backend-golang/utils"
 )
 
-func RepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig) user.Repository {
-	adminRepo := NewMongoRepository(dbCon, conf)
+func RepositoryFactory(dbCon *utils.DatabaseConnection, conf *config.AppConfig, keys *utils.KeySet) user.Repository {
+	adminRepo := NewMongoRepository(dbCon, conf, keys)
 	return adminRepo
 }
@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"mime/multipart"
 	"path/filepath"
+	"roby-backend-golang/business/entitlement"
 	businessUser "roby-backend-golang/business/user"
 	"roby-backend-golang/config"
 	"roby-backend-golang/repository"
@@ -20,45 +21,159 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/net/context"
 )
 
 type MongoDBRepository struct {
 	colUser *mongo.Collection
 	colPack *mongo.Collection
+	colSeq  *mongo.Collection
 	conf    *config.AppConfig
 	aws     *session.Session
 	redis   *redis.Client
+	keys    *utils.KeySet
 }
 
-func NewMongoRepository(dbCon *utils.DatabaseConnection, conf *config.AppConfig) *MongoDBRepository {
-	return &MongoDBRepository{
+func NewMongoRepository(dbCon *utils.DatabaseConnection, conf *config.AppConfig, keys *utils.KeySet) *MongoDBRepository {
+	repo := &MongoDBRepository{
 		colUser: dbCon.MongoDB.Collection("user"),
 		colPack: dbCon.MongoDB.Collection("package"),
+		colSeq:  dbCon.MongoDB.Collection("counter"),
 		conf:    conf,
 		aws:     dbCon.AwsS3,
 		redis:   dbCon.Redis,
+		keys:    keys,
 	}
+	repo.ensureIndexes()
+	repo.backfillPackageFeatures()
+	repo.seedPackagePosition()
+	return repo
+}
+
+// ensureIndexes links an account of an identity provider to one user at most.
+func (repo *MongoDBRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repo.colUser.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"identities": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// backfillPackageFeatures gives the premium package from before features
+// existed the unlimited swipes its name used to unlock, so its holders keep
+// them. Packages created since have a billing period and are left alone.
+func (repo *MongoDBRepository) backfillPackageFeatures() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"package_name":   "premium",
+		"billing_period": bson.M{"$exists": false},
+		// null matches a missing field too
+		"$or": bson.A{bson.M{"features": nil}, bson.M{"features": bson.A{}}},
+	}
+	update := bson.M{"$set": bson.M{"features": []string{entitlement.FeatureUnlimitedSwipes}}}
+
+	res, err := repo.colPack.UpdateMany(ctx, filter, update)
+	if err != nil {
+		panic(err)
+	}
+	if res.ModifiedCount > 0 {
+		fmt.Println("granted unlimited swipes to legacy premium packages:", res.ModifiedCount)
+	}
+}
+
+// packagePositionCounter is the counter document handing out the position of
+// new packages.
+const packagePositionCounter = "package_position"
+
+// seedPackagePosition raises the package position counter above the packages
+// created before it existed. $max never lowers it, so running it again or on
+// several instances at once is safe.
+func (repo *MongoDBRepository) seedPackagePosition() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count, err := repo.colPack.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		panic(err)
+	}
+	last := count - 1
+
+	var pack repository.Package
+	opts := options.FindOne().SetSort(bson.D{{Key: "position", Value: -1}})
+	err = repo.colPack.FindOne(ctx, bson.M{}, opts).Decode(&pack)
+	if err != nil && err != mongo.ErrNoDocuments {
+		panic(err)
+	}
+	if int64(pack.Position) > last {
+		last = int64(pack.Position)
+	}
+
+	_, err = repo.colSeq.UpdateOne(ctx,
+		bson.M{"_id": packagePositionCounter},
+		bson.M{"$max": bson.M{"seq": last}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// nextPackagePosition takes the next position from the counter, two packages
+// created at once never share one.
+func (repo *MongoDBRepository) nextPackagePosition(ctx context.Context) (int, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := repo.colSeq.FindOneAndUpdate(ctx,
+		bson.M{"_id": packagePositionCounter},
+		bson.M{"$inc": bson.M{"seq": 1}}, opts).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(counter.Seq), nil
 }
 
 func (repo *MongoDBRepository) FindUserByEmail(email string) (businessUser.User, error) {
+	user, err := repo.findUser(bson.M{"email": email})
+	if err == mongo.ErrNoDocuments {
+		return user, errors.New("wrong email")
+	}
+	return user, err
+}
+
+func (repo *MongoDBRepository) FindUserByIdentity(provider, subject string) (businessUser.User, error) {
+	user, err := repo.findUser(bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}})
+	if err == mongo.ErrNoDocuments {
+		return user, errors.New("identity not linked")
+	}
+	return user, err
+}
+
+// findUser loads the first user matching the filter with its packages, it
+// returns mongo.ErrNoDocuments when there is none.
+func (repo *MongoDBRepository) findUser(match bson.M) (businessUser.User, error) {
 	var user repository.User
 	var userBusiness businessUser.User
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	queryFilter := bson.A{
-		bson.M{"$match": bson.M{"email": email}},
-		bson.M{"$lookup": bson.M{
-			"from":         "package",
-			"localField":   "package",
-			"foreignField": "_id",
-			"as":           "packages",
-		}},
+		bson.M{"$match": match},
 		bson.M{
 			"$limit": 1,
 		},
 	}
+	queryFilter = append(queryFilter, repository.PackageLookup("", time.Now())...)
 
 	cur, err := repo.colUser.Aggregate(ctx, queryFilter)
 	if err != nil {
@@ -73,7 +188,7 @@ func (repo *MongoDBRepository) FindUserByEmail(email string) (businessUser.User,
 	}
 
 	if user.ID.IsZero() {
-		return userBusiness, errors.New("wrong email")
+		return userBusiness, mongo.ErrNoDocuments
 	}
 
 	userBusiness.ID = user.ID.Hex()
@@ -82,17 +197,22 @@ func (repo *MongoDBRepository) FindUserByEmail(email string) (businessUser.User,
 	userBusiness.PhotoUrl = user.PhotoUrl
 	userBusiness.FullName = user.Fullname
 	userBusiness.Packages = user.Packages
+	userBusiness.EmailVerified = user.IsEmailVerified()
+	userBusiness.MFAEnabled = user.MFAEnabled
+	userBusiness.MFASecret = user.MFASecret
+	userBusiness.ProfileCompleted = user.IsProfileCompleted()
+	userBusiness.Roles = utils.NormalizeRoles(user.Roles)
 
 	return userBusiness, nil
 }
 
-func (repo *MongoDBRepository) CreateUser(data businessUser.Register) error {
+func (repo *MongoDBRepository) CreateUser(data businessUser.Register) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	passwd, err := utils.Hash(data.Password)
 	if err != nil {
-		return errors.New("failed to hash password")
+		return "", errors.New("failed to hash password")
 	}
 
 	insUser := repository.RegisterUser{
@@ -102,14 +222,15 @@ func (repo *MongoDBRepository) CreateUser(data businessUser.Register) error {
 		Type:     "free",
 		Password: string(passwd),
 		PhotoUrl: data.PhotoUrl,
+		Roles:    []string{utils.RoleUser},
 	}
 
 	_, err = repo.colUser.InsertOne(ctx, insUser)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return insUser.ID.Hex(), nil
 }
 
 func (repo *MongoDBRepository) FindUserByID(id string) (businessUser.User, error) {
@@ -136,6 +257,7 @@ func (repo *MongoDBRepository) FindUserByID(id string) (businessUser.User, error
 	userBusiness.ID = user.ID.Hex()
 	userBusiness.Email = user.Email
 	userBusiness.Password = user.Password
+	userBusiness.Roles = utils.NormalizeRoles(user.Roles)
 	return userBusiness, nil
 }
 
@@ -192,15 +314,15 @@ func (repo *MongoDBRepository) GetRandomUser(id []string) (businessUser.Response
 	}
 
 	filter := bson.A{
-		bson.M{"$match": bson.M{"_id": bson.M{"$nin": objArr}}},
-		bson.M{"$sample": bson.M{"size": 1}},
-		bson.M{"$lookup": bson.M{
-			"from":         "package",
-			"localField":   "package",
-			"foreignField": "_id",
-			"as":           "packages",
+		bson.M{"$match": bson.M{
+			"_id":            bson.M{"$nin": objArr},
+			"email_verified": bson.M{"$ne": false},
+			// accounts of a social login without fullname and photo yet
+			"profile_completed": bson.M{"$ne": false},
 		}},
+		bson.M{"$sample": bson.M{"size": 1}},
 	}
+	filter = append(filter, repository.PackageLookup("", time.Now())...)
 
 	cur, err := repo.colUser.Aggregate(ctx, filter)
 	if err != nil {
@@ -215,7 +337,7 @@ func (repo *MongoDBRepository) GetRandomUser(id []string) (businessUser.Response
 	}
 
 	if user.ID.IsZero() {
-		return userBusiness, errors.New("no user found, please wait for tomorrow")
+		return userBusiness, errors.New("no more users to show, every candidate was already swiped")
 	}
 
 	userBusiness.ID = user.ID.Hex()
@@ -246,42 +368,66 @@ func (repo *MongoDBRepository) Get(key string) (string, error) {
 	return res, nil
 }
 
-func (repo *MongoDBRepository) Del(key string) error {
+func (repo *MongoDBRepository) GetDel(key string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	return repo.redis.Del(ctx, key).Err()
+	return repo.redis.GetDel(ctx, key).Result()
+}
+
+// incrScript increments the counter and starts its window in one step, a
+// counter left without expiration gets one on the next increment.
+var incrScript = redis.NewScript(`
+local count = redis.call("INCRBY", KEYS[1], ARGV[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return count
+`)
+
+// Incr counts within a window, the expiration is set by the first increment
+// so the window does not move with every call.
+func (repo *MongoDBRepository) Incr(key string, expiration time.Duration) (int64, error) {
+	return repo.IncrBy(key, 1, expiration)
 }
 
-func (repo *MongoDBRepository) PurchasePackage(id string, packages []string) error {
+// IncrBy adds value to the counter like Incr, a negative value takes back an
+// earlier increment.
+func (repo *MongoDBRepository) IncrBy(key string, value int64, expiration time.Duration) (int64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return errors.New("invalid id")
-	}
+	return incrScript.Run(ctx, repo.redis, []string{key}, value, expiration.Milliseconds()).Int64()
+}
 
-	queryFilter := repository.NewFilterQuery()
-	queryFilter.SetID(objID)
+func (repo *MongoDBRepository) Del(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	update := bson.M{"$set": bson.M{"package": packages}}
+	return repo.redis.Del(ctx, key).Err()
+}
 
-	_, err = repo.colUser.UpdateOne(ctx, queryFilter, update)
-	if err != nil {
-		return err
-	}
+func (repo *MongoDBRepository) GetListPackage() ([]businessUser.Package, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	return nil
+	return repo.findPackages(ctx, bson.M{"archived_at": bson.M{"$exists": false}})
 }
 
-func (repo *MongoDBRepository) GetListPackage() ([]businessUser.Package, error) {
+func (repo *MongoDBRepository) GetAllPackages() ([]businessUser.Package, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	return repo.findPackages(ctx, bson.M{})
+}
+
+// findPackages lists the packages matching the filter in the order set by an
+// admin, packages never reordered keep their insertion order.
+func (repo *MongoDBRepository) findPackages(ctx context.Context, filter bson.M) ([]businessUser.Package, error) {
 	var packages []businessUser.Package
 
-	cur, err := repo.colPack.Find(ctx, bson.M{})
+	opts := options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "_id", Value: 1}})
+	cur, err := repo.colPack.Find(ctx, filter, opts)
 	if err != nil {
 		return packages, err
 	}
@@ -298,6 +444,123 @@ func (repo *MongoDBRepository) GetListPackage() ([]businessUser.Package, error)
 	return packages, nil
 }
 
+func (repo *MongoDBRepository) CreatePackage(data businessUser.PackageInput) (businessUser.Package, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// a new package goes to the end of the list
+	position, err := repo.nextPackagePosition(ctx)
+	if err != nil {
+		return businessUser.Package{}, err
+	}
+
+	now := time.Now()
+	pack := repository.Package{
+		PackageName:   data.PackageName,
+		Description:   data.Description,
+		Features:      data.Features,
+		Position:      position,
+		BillingPeriod: data.BillingPeriod,
+		TrialDays:     data.TrialDays,
+		Prices:        data.Prices,
+		DisplayNames:  data.DisplayNames,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	res, err := repo.colPack.InsertOne(ctx, pack)
+	if err != nil {
+		return businessUser.Package{}, err
+	}
+
+	return businessUser.Package{
+		ID:            res.InsertedID.(primitive.ObjectID).Hex(),
+		PackageName:   pack.PackageName,
+		Description:   pack.Description,
+		Features:      pack.Features,
+		Position:      pack.Position,
+		BillingPeriod: pack.BillingPeriod,
+		TrialDays:     pack.TrialDays,
+		Prices:        pack.Prices,
+		DisplayNames:  pack.DisplayNames,
+	}, nil
+}
+
+func (repo *MongoDBRepository) UpdatePackage(id string, data businessUser.PackageInput) error {
+	return repo.updatePackage(id, bson.M{}, bson.M{"$set": bson.M{
+		"package_name":   data.PackageName,
+		"description":    data.Description,
+		"features":       data.Features,
+		"billing_period": data.BillingPeriod,
+		"trial_days":     data.TrialDays,
+		"prices":         data.Prices,
+		"display_names":  data.DisplayNames,
+		"updated_at":     time.Now(),
+	}})
+}
+
+func (repo *MongoDBRepository) ArchivePackage(id string, at time.Time) error {
+	return repo.updatePackage(id, bson.M{"archived_at": bson.M{"$exists": false}}, bson.M{"$set": bson.M{
+		"archived_at": at,
+		"updated_at":  at,
+	}})
+}
+
+func (repo *MongoDBRepository) updatePackage(id string, filter, update bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return utils.HandleError(404, "package not found")
+	}
+	filter["_id"] = objID
+
+	res, err := repo.colPack.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return utils.HandleError(404, "package not found")
+	}
+
+	return nil
+}
+
+// ReorderPackages checks every package of the order before moving any, so an
+// unknown or archived package leaves the order as it was.
+func (repo *MongoDBRepository) ReorderPackages(ids []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return utils.HandleError(400, fmt.Sprintf("package %s not found", id))
+		}
+		objIDs = append(objIDs, objID)
+	}
+
+	active := bson.M{"_id": bson.M{"$in": objIDs}, "archived_at": bson.M{"$exists": false}}
+	count, err := repo.colPack.CountDocuments(ctx, active)
+	if err != nil {
+		return err
+	}
+	if count != int64(len(objIDs)) {
+		return utils.HandleError(400, "unknown or archived package in the order")
+	}
+
+	models := make([]mongo.WriteModel, 0, len(objIDs))
+	for i, objID := range objIDs {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": objID}).
+			SetUpdate(bson.M{"$set": bson.M{"position": i}}))
+	}
+
+	_, err = repo.colPack.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
 func (repo *MongoDBRepository) GetMe(id string) (businessUser.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -312,13 +575,8 @@ func (repo *MongoDBRepository) GetMe(id string) (businessUser.User, error) {
 
 	filter := bson.A{
 		bson.M{"$match": bson.M{"_id": objID}},
-		bson.M{"$lookup": bson.M{
-			"from":         "package",
-			"localField":   "package",
-			"foreignField": "_id",
-			"as":           "packages",
-		}},
 	}
+	filter = append(filter, repository.PackageLookup("", time.Now())...)
 
 	cursor, err := repo.colUser.Aggregate(ctx, filter)
 	if err != nil {
@@ -336,24 +594,185 @@ func (repo *MongoDBRepository) GetMe(id string) (businessUser.User, error) {
 		userBusiness.FullName = user.Fullname
 		userBusiness.Packages = user.Packages
 		userBusiness.Package = user.Package
+		userBusiness.Entitlements = user.Entitlements
+		userBusiness.EmailVerified = user.IsEmailVerified()
+		userBusiness.MFAEnabled = user.MFAEnabled
+		userBusiness.MFASecret = user.MFASecret
+		userBusiness.MFAPendingSecret = user.MFAPendingSecret
+		userBusiness.ProfileCompleted = user.IsProfileCompleted()
+		userBusiness.Roles = utils.NormalizeRoles(user.Roles)
 	}
 
 	return userBusiness, nil
 }
 
-func (repo *MongoDBRepository) UpdatePackageUser(id string, idPackage []string) error {
+func (repo *MongoDBRepository) GetPackageByID(id string) (businessUser.Package, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var objArr []primitive.ObjectID
-	for _, v := range idPackage {
-		objID, err := primitive.ObjectIDFromHex(v)
-		if err != nil {
-			return errors.New("invalid id")
-		}
-		objArr = append(objArr, objID)
+	var pack businessUser.Package
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return pack, errors.New("invalid id")
+	}
+
+	queryFilter := repository.NewFilterQuery()
+	queryFilter.SetID(objID)
+
+	err = repo.colPack.FindOne(ctx, queryFilter).Decode(&pack)
+	if err != nil {
+		return pack, err
+	}
+
+	return pack, nil
+}
+
+func (repo *MongoDBRepository) GenerateTokenAuth(id, email string) (*utils.Token, error) {
+	return repo.GenerateTokenFamily(id, email, primitive.NewObjectID().Hex(), nil)
+}
+
+// GenerateTokenFamily mints a token pair whose refresh token belongs to the
+// family, the refresh token id is kept in redis until it is rotated.
+func (repo *MongoDBRepository) GenerateTokenFamily(id, email, family string, roles []string) (*utils.Token, error) {
+	version, err := repo.GetTokenVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	exp, token, err := utils.GenerateAccessTokenUser(id, email, family, primitive.NewObjectID().Hex(), version, roles, repo.keys)
+	if err != nil {
+		return nil, err
+	}
+	jti := primitive.NewObjectID().Hex()
+	exprefresh, refreshtoken, err := utils.GenerateRefreshTokenUser(id, email, family, jti, version, roles, repo.keys)
+	if err != nil {
+		return nil, err
 	}
 
+	err = repo.Set(fmt.Sprintf("apptinder:refresh:%s", jti), id, time.Duration(exprefresh)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	var restoken = utils.Token{
+		AccessToken:         token,
+		AccessTokenExpired:  exp,
+		RefreshToken:        refreshtoken,
+		RefreshTokenExpired: exprefresh,
+	}
+
+	return &restoken, nil
+}
+
+func tokenVersionKey(id string) string {
+	return fmt.Sprintf("apptinder:tokenversion:%s", id)
+}
+
+// tokenVersionCacheTTL bounds how long redis keeps a token version, mongodb
+// holds the version for good.
+const tokenVersionCacheTTL = 24 * time.Hour
+
+// cacheTokenVersionScript caches a token version unless a newer one is cached
+// already, a read racing with a revocation can not bring the old one back.
+var cacheTokenVersionScript = redis.NewScript(`
+local cached = tonumber(redis.call("GET", KEYS[1]))
+local version = tonumber(ARGV[1])
+if cached == nil or cached < version then
+	redis.call("SET", KEYS[1], version, "PX", ARGV[2])
+	return version
+end
+return cached
+`)
+
+// GetTokenVersion returns the token version of the user, tokens minted with
+// a lower version are no longer valid. Redis caches the version of the user
+// document.
+func (repo *MongoDBRepository) GetTokenVersion(id string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	version, err := repo.redis.Get(ctx, tokenVersionKey(id)).Int64()
+	if err == nil {
+		return version, nil
+	}
+	if err != redis.Nil {
+		return 0, err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return 0, errors.New("invalid id")
+	}
+
+	var user struct {
+		TokenVersion int64 `bson:"token_version"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"token_version": 1})
+	err = repo.colUser.FindOne(ctx, bson.M{"_id": objID}, opts).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+
+	return cacheTokenVersionScript.Run(ctx, repo.redis, []string{tokenVersionKey(id)}, user.TokenVersion, tokenVersionCacheTTL.Milliseconds()).Int64()
+}
+
+func (repo *MongoDBRepository) IncrTokenVersion(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	// versions used to live in redis alone, the next one starts above both
+	cached, err := repo.redis.Get(ctx, tokenVersionKey(id)).Int64()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	update := bson.A{bson.M{"$set": bson.M{"token_version": bson.M{"$add": bson.A{
+		bson.M{"$max": bson.A{bson.M{"$ifNull": bson.A{"$token_version", 0}}, cached}},
+		1,
+	}}}}}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"token_version": 1})
+	var user struct {
+		TokenVersion int64 `bson:"token_version"`
+	}
+	err = repo.colUser.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return utils.HandleError(404, "user not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return cacheTokenVersionScript.Run(ctx, repo.redis, []string{tokenVersionKey(id)}, user.TokenVersion, tokenVersionCacheTTL.Milliseconds()).Err()
+}
+
+// GenerateVerifyEmailToken mints a verification token, its id is kept in redis
+// until the token is used so every token verifies once.
+func (repo *MongoDBRepository) GenerateVerifyEmailToken(id, email string) (string, error) {
+	jti := primitive.NewObjectID().Hex()
+	exp, token, err := utils.GenerateVerifyEmailToken(id, email, jti, repo.keys)
+	if err != nil {
+		return "", err
+	}
+
+	err = repo.Set(fmt.Sprintf("apptinder:verifyemail:%s", jti), id, time.Duration(exp)*time.Second)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (repo *MongoDBRepository) SetEmailVerified(id string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return errors.New("invalid id")
@@ -362,7 +781,7 @@ func (repo *MongoDBRepository) UpdatePackageUser(id string, idPackage []string)
 	queryFilter := repository.NewFilterQuery()
 	queryFilter.SetID(objID)
 
-	update := bson.M{"$set": bson.M{"package": objArr}}
+	update := bson.M{"$set": bson.M{"email_verified": true, "email_verified_at": at}}
 
 	_, err = repo.colUser.UpdateOne(ctx, queryFilter, update)
 	if err != nil {
@@ -372,43 +791,181 @@ func (repo *MongoDBRepository) UpdatePackageUser(id string, idPackage []string)
 	return nil
 }
 
-func (repo *MongoDBRepository) GetPackageByID(id string) (businessUser.Package, error) {
+func (repo *MongoDBRepository) UpdatePassword(id, password string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var pack businessUser.Package
-
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return pack, errors.New("invalid id")
+		return errors.New("invalid id")
 	}
 
 	queryFilter := repository.NewFilterQuery()
 	queryFilter.SetID(objID)
 
-	err = repo.colPack.FindOne(ctx, queryFilter).Decode(&pack)
+	update := bson.M{"$set": bson.M{"password": password, "updated_at": time.Now()}}
+
+	_, err = repo.colUser.UpdateOne(ctx, queryFilter, update)
 	if err != nil {
-		return pack, err
+		return err
 	}
 
-	return pack, nil
+	return nil
 }
 
-func (repo *MongoDBRepository) GenerateTokenAuth(id, email string) (*utils.Token, error) {
-	exp, token, err := utils.GenerateAccessTokenUser(id, email, repo.conf.Secrettoken.Token)
+// GenerateMFAChallenge mints the token a password login gets while the second
+// factor is outstanding, its id is kept in redis until it is traded for tokens.
+func (repo *MongoDBRepository) GenerateMFAChallenge(id, email string) (string, error) {
+	jti := primitive.NewObjectID().Hex()
+	exp, token, err := utils.GenerateMFAChallengeToken(id, email, jti, repo.keys)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	exprefresh, refreshtoken, err := utils.GenerateRefreshTokenUser(id, email, repo.conf.Secrettoken.Token)
+
+	err = repo.Set(fmt.Sprintf("apptinder:mfachallenge:%s", jti), id, time.Duration(exp)*time.Second)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	var restoken = utils.Token{
-		AccessToken:         token,
-		AccessTokenExpired:  exp,
-		RefreshToken:        refreshtoken,
-		RefreshTokenExpired: exprefresh,
+
+	return token, nil
+}
+
+func (repo *MongoDBRepository) SetMFAPendingSecret(id, secret string) error {
+	return repo.updateUser(id, bson.M{"$set": bson.M{"mfa_pending_secret": secret}})
+}
+
+// EnableMFA turns two-factor on with the confirmed secret and replaces the
+// recovery codes, codes are stored hashed.
+func (repo *MongoDBRepository) EnableMFA(id, secret string, recoveryCodes []string) error {
+	return repo.updateUser(id, bson.M{
+		"$set": bson.M{
+			"mfa_enabled":        true,
+			"mfa_secret":         secret,
+			"mfa_recovery_codes": recoveryCodes,
+			"updated_at":         time.Now(),
+		},
+		"$unset": bson.M{"mfa_pending_secret": ""},
+	})
+}
+
+func (repo *MongoDBRepository) SetRecoveryCodes(id string, recoveryCodes []string) error {
+	return repo.updateUser(id, bson.M{"$set": bson.M{"mfa_recovery_codes": recoveryCodes}})
+}
+
+// UseRecoveryCode removes the hashed code from the user and reports whether it
+// was there, the filter and pull happen in one update so a code works once.
+func (repo *MongoDBRepository) UseRecoveryCode(id, hashedCode string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errors.New("invalid id")
 	}
 
-	return &restoken, nil
+	filter := bson.M{"_id": objID, "mfa_enabled": true, "mfa_recovery_codes": hashedCode}
+	update := bson.M{"$pull": bson.M{"mfa_recovery_codes": hashedCode}}
+
+	res, err := repo.colUser.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount == 1, nil
+}
+
+func (repo *MongoDBRepository) DisableMFA(id string) error {
+	return repo.updateUser(id, bson.M{
+		"$set": bson.M{"mfa_enabled": false, "updated_at": time.Now()},
+		"$unset": bson.M{
+			"mfa_secret":         "",
+			"mfa_pending_secret": "",
+			"mfa_recovery_codes": "",
+		},
+	})
+}
+
+func (repo *MongoDBRepository) updateUser(id string, update bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	queryFilter := repository.NewFilterQuery()
+	queryFilter.SetID(objID)
+
+	_, err = repo.colUser.UpdateOne(ctx, queryFilter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// LinkIdentity adds the identity to the user, the unique index refuses an
+// identity already linked to someone else.
+func (repo *MongoDBRepository) LinkIdentity(id string, identity businessUser.Identity, at time.Time) error {
+	return repo.updateUser(id, bson.M{"$push": bson.M{"identities": repository.Identity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: at,
+	}}})
+}
+
+// CreateOAuthUser creates the account of a first social login. It has no
+// password and its profile is completed separately.
+func (repo *MongoDBRepository) CreateOAuthUser(identity businessUser.Identity) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	profileCompleted := false
+	insUser := repository.RegisterUser{
+		ID:               primitive.NewObjectID(),
+		Fullname:         identity.FullName,
+		Email:            identity.Email,
+		Type:             "free",
+		PhotoUrl:         identity.PhotoUrl,
+		EmailVerified:    true,
+		ProfileCompleted: &profileCompleted,
+		Roles:            []string{utils.RoleUser},
+		Identities: []repository.Identity{{
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+			LinkedAt: now,
+		}},
+		CreatedAt: now,
+	}
+
+	_, err := repo.colUser.InsertOne(ctx, insUser)
+	if err != nil {
+		return "", err
+	}
+
+	return insUser.ID.Hex(), nil
+}
+
+func (repo *MongoDBRepository) CompleteProfile(id, fullName, photoUrl string) error {
+	return repo.updateUser(id, bson.M{"$set": bson.M{
+		"fullname":          fullName,
+		"photo_url":         photoUrl,
+		"profile_completed": true,
+		"updated_at":        time.Now(),
+	}})
+}
+
+func (repo *MongoDBRepository) SetRoles(id string, roles []string) error {
+	return repo.updateUser(id, bson.M{"$set": bson.M{"roles": roles, "updated_at": time.Now()}})
+}
+
+func (repo *MongoDBRepository) CountUsersByRole(role string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return repo.colUser.CountDocuments(ctx, repository.NewFilterQuery().SetRole(role))
 }
@@ -23,9 +23,9 @@ func (m *UserMock) FindUserByEmail(email string) (businessUser.User, error) {
 	return args.Get(0).(businessUser.User), args.Error(1)
 }
 
-func (m *UserMock) CreateUser(data businessUser.Register) error {
+func (m *UserMock) CreateUser(data businessUser.Register) (string, error) {
 	args := m.Called(data)
-	return args.Error(0)
+	return args.String(0), args.Error(1)
 }
 
 func (m *UserMock) GetRandomUser(id []string) (businessUser.ResponseRandomUser, error) {
@@ -38,11 +38,6 @@ func (m *UserMock) UploadImageS3(file *multipart.FileHeader) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
-func (m *UserMock) PurchasePackage(id string, packages []string) error {
-	args := m.Called(id, packages)
-	return args.Error(0)
-}
-
 func (m *UserMock) GetListPackage() ([]businessUser.Package, error) {
 	args := m.Called()
 	return args.Get(0).([]businessUser.Package), args.Error(1)
@@ -53,8 +48,28 @@ func (m *UserMock) GetMe(id string) (businessUser.User, error) {
 	return args.Get(0).(businessUser.User), args.Error(1)
 }
 
-func (m *UserMock) UpdatePackageUser(id string, idPackage []string) error {
-	args := m.Called(id, idPackage)
+func (m *UserMock) GetAllPackages() ([]businessUser.Package, error) {
+	args := m.Called()
+	return args.Get(0).([]businessUser.Package), args.Error(1)
+}
+
+func (m *UserMock) CreatePackage(data businessUser.PackageInput) (businessUser.Package, error) {
+	args := m.Called(data)
+	return args.Get(0).(businessUser.Package), args.Error(1)
+}
+
+func (m *UserMock) UpdatePackage(id string, data businessUser.PackageInput) error {
+	args := m.Called(id, data)
+	return args.Error(0)
+}
+
+func (m *UserMock) ArchivePackage(id string, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+func (m *UserMock) ReorderPackages(ids []string) error {
+	args := m.Called(ids)
 	return args.Error(0)
 }
 
@@ -73,6 +88,11 @@ func (m *UserMock) Get(key string) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
+func (m *UserMock) GetDel(key string) (string, error) {
+	args := m.Called(key)
+	return args.String(0), args.Error(1)
+}
+
 func (m *UserMock) Del(keys string) error {
 	args := m.Called(keys)
 	return args.Error(0)
@@ -82,3 +102,103 @@ func (m *UserMock) GenerateTokenAuth(id, email string) (*utils.Token, error) {
 	args := m.Called(id, email)
 	return args.Get(0).(*utils.Token), args.Error(1)
 }
+
+func (m *UserMock) GenerateTokenFamily(id, email, family string, roles []string) (*utils.Token, error) {
+	args := m.Called(id, email, family, roles)
+	return args.Get(0).(*utils.Token), args.Error(1)
+}
+
+func (m *UserMock) GetTokenVersion(id string) (int64, error) {
+	args := m.Called(id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *UserMock) IncrTokenVersion(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *UserMock) GenerateVerifyEmailToken(id, email string) (string, error) {
+	args := m.Called(id, email)
+	return args.String(0), args.Error(1)
+}
+
+func (m *UserMock) SetEmailVerified(id string, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+func (m *UserMock) UpdatePassword(id, password string) error {
+	args := m.Called(id, password)
+	return args.Error(0)
+}
+
+func (m *UserMock) Incr(key string, expiration time.Duration) (int64, error) {
+	args := m.Called(key, expiration)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *UserMock) IncrBy(key string, value int64, expiration time.Duration) (int64, error) {
+	args := m.Called(key, value, expiration)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *UserMock) GenerateMFAChallenge(id, email string) (string, error) {
+	args := m.Called(id, email)
+	return args.String(0), args.Error(1)
+}
+
+func (m *UserMock) SetMFAPendingSecret(id, secret string) error {
+	args := m.Called(id, secret)
+	return args.Error(0)
+}
+
+func (m *UserMock) EnableMFA(id, secret string, recoveryCodes []string) error {
+	args := m.Called(id, secret, recoveryCodes)
+	return args.Error(0)
+}
+
+func (m *UserMock) SetRecoveryCodes(id string, recoveryCodes []string) error {
+	args := m.Called(id, recoveryCodes)
+	return args.Error(0)
+}
+
+func (m *UserMock) UseRecoveryCode(id, hashedCode string) (bool, error) {
+	args := m.Called(id, hashedCode)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *UserMock) DisableMFA(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *UserMock) FindUserByIdentity(provider, subject string) (businessUser.User, error) {
+	args := m.Called(provider, subject)
+	return args.Get(0).(businessUser.User), args.Error(1)
+}
+
+func (m *UserMock) LinkIdentity(id string, identity businessUser.Identity, at time.Time) error {
+	args := m.Called(id, identity, at)
+	return args.Error(0)
+}
+
+func (m *UserMock) CreateOAuthUser(identity businessUser.Identity) (string, error) {
+	args := m.Called(identity)
+	return args.String(0), args.Error(1)
+}
+
+func (m *UserMock) CompleteProfile(id, fullName, photoUrl string) error {
+	args := m.Called(id, fullName, photoUrl)
+	return args.Error(0)
+}
+
+func (m *UserMock) SetRoles(id string, roles []string) error {
+	args := m.Called(id, roles)
+	return args.Error(0)
+}
+
+func (m *UserMock) CountUsersByRole(role string) (int64, error) {
+	args := m.Called(role)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,148 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"roby-backend-golang/business/entitlement"
+	businessUser "roby-backend-golang/business/user"
+	"roby-backend-golang/repository"
+	"roby-backend-golang/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestDatabase connects to the mongodb at MONGO_TEST_URI and hands out a
+// database of its own that is dropped after the test.
+func newTestDatabase(t *testing.T) *mongo.Database {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := client.Database(fmt.Sprintf("user_test_%s", primitive.NewObjectID().Hex()))
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+	return db
+}
+
+func TestBackfillPackageFeatures(t *testing.T) {
+	t.Run("Legacy Premium Package Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		db := newTestDatabase(t)
+		repo := &MongoDBRepository{colPack: db.Collection("package")}
+		ctx := context.Background()
+
+		legacy := primitive.NewObjectID()
+		other := primitive.NewObjectID()
+		created := primitive.NewObjectID()
+		_, err := repo.colPack.InsertMany(ctx, []interface{}{
+			repository.Package{ID: legacy, PackageName: "premium", Description: "unlimited swipes"},
+			repository.Package{ID: other, PackageName: "verified", Description: "badge"},
+			// created since, without features on purpose
+			repository.Package{ID: created, PackageName: "premium", Description: "trial", BillingPeriod: "monthly"},
+		})
+		asserting.NoError(err)
+
+		repo.backfillPackageFeatures()
+		// running again changes nothing
+		repo.backfillPackageFeatures()
+
+		features := func(id primitive.ObjectID) []string {
+			var pack repository.Package
+			asserting.NoError(repo.colPack.FindOne(ctx, bson.M{"_id": id}).Decode(&pack))
+			return pack.Features
+		}
+		asserting.Equal([]string{entitlement.FeatureUnlimitedSwipes}, features(legacy))
+		asserting.Empty(features(other))
+		asserting.Empty(features(created))
+	})
+}
+
+func TestCreatePackage(t *testing.T) {
+	t.Run("Concurrent Create Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		db := newTestDatabase(t)
+		repo := &MongoDBRepository{colPack: db.Collection("package"), colSeq: db.Collection("counter")}
+
+		// created before the counter existed
+		_, err := repo.colPack.InsertOne(context.Background(), repository.Package{ID: primitive.NewObjectID(), PackageName: "premium", Position: 4})
+		asserting.NoError(err)
+		repo.seedPackagePosition()
+
+		const requests = 20
+		positions := make(chan int, requests)
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pack, err := repo.CreatePackage(businessUser.PackageInput{PackageName: "gold"})
+				asserting.NoError(err)
+				positions <- pack.Position
+			}()
+		}
+		wg.Wait()
+		close(positions)
+
+		seen := map[int]bool{}
+		for position := range positions {
+			asserting.Greater(position, 4)
+			asserting.False(seen[position])
+			seen[position] = true
+		}
+		asserting.Len(seen, requests)
+	})
+}
+
+func TestReorderPackages(t *testing.T) {
+	t.Run("Archived Package Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		db := newTestDatabase(t)
+		repo := &MongoDBRepository{colPack: db.Collection("package"), colSeq: db.Collection("counter")}
+		ctx := context.Background()
+
+		now := time.Now()
+		first := primitive.NewObjectID()
+		second := primitive.NewObjectID()
+		archived := primitive.NewObjectID()
+		_, err := repo.colPack.InsertMany(ctx, []interface{}{
+			repository.Package{ID: first, PackageName: "gold", Position: 0},
+			repository.Package{ID: second, PackageName: "platinum", Position: 1},
+			repository.Package{ID: archived, PackageName: "premium", Position: 2, ArchivedAt: &now},
+		})
+		asserting.NoError(err)
+
+		err = repo.ReorderPackages([]string{second.Hex(), archived.Hex(), first.Hex()})
+		asserting.Equal(400, utils.GetStatusCode(err))
+
+		// nothing moved
+		packages, err := repo.GetListPackage()
+		asserting.NoError(err)
+		asserting.Len(packages, 2)
+		asserting.Equal(first.Hex(), packages[0].ID)
+		asserting.Equal(second.Hex(), packages[1].ID)
+
+		asserting.NoError(repo.ReorderPackages([]string{second.Hex(), first.Hex()}))
+		packages, err = repo.GetListPackage()
+		asserting.NoError(err)
+		asserting.Equal(second.Hex(), packages[0].ID)
+		asserting.Equal(first.Hex(), packages[1].ID)
+	})
+}
@@ -0,0 +1,103 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncr(t *testing.T) {
+	t.Run("Window Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		defer client.Close()
+		repo := &MongoDBRepository{redis: client}
+
+		count, err := repo.Incr("counter", time.Hour)
+		asserting.NoError(err)
+		asserting.Equal(int64(1), count)
+		asserting.Equal(time.Hour, server.TTL("counter"))
+
+		// the window does not move with later increments
+		server.FastForward(30 * time.Minute)
+		count, err = repo.Incr("counter", time.Hour)
+		asserting.NoError(err)
+		asserting.Equal(int64(2), count)
+		asserting.Equal(30*time.Minute, server.TTL("counter"))
+
+		server.FastForward(30 * time.Minute)
+		asserting.False(server.Exists("counter"))
+	})
+
+	t.Run("Counter Without Expiration Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		defer client.Close()
+		repo := &MongoDBRepository{redis: client}
+		// left behind by a failed expire
+		asserting.NoError(client.Set(context.Background(), "counter", 4, 0).Err())
+
+		count, err := repo.Incr("counter", time.Hour)
+		asserting.NoError(err)
+		asserting.Equal(int64(5), count)
+		asserting.Equal(time.Hour, server.TTL("counter"))
+	})
+
+	t.Run("IncrBy Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		defer client.Close()
+		repo := &MongoDBRepository{redis: client}
+
+		count, err := repo.IncrBy("counter", 3, time.Hour)
+		asserting.NoError(err)
+		asserting.Equal(int64(3), count)
+		count, err = repo.IncrBy("counter", -1, time.Hour)
+		asserting.NoError(err)
+		asserting.Equal(int64(2), count)
+	})
+}
+
+func TestTokenVersionCache(t *testing.T) {
+	t.Run("Cached Version Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		defer client.Close()
+		// a cached version is served without asking mongodb
+		repo := &MongoDBRepository{redis: client}
+		asserting.NoError(client.Set(context.Background(), tokenVersionKey("123"), 3, 0).Err())
+
+		version, err := repo.GetTokenVersion("123")
+		asserting.NoError(err)
+		asserting.Equal(int64(3), version)
+	})
+
+	t.Run("Stale Version Not Cached Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		defer client.Close()
+		ctx := context.Background()
+		key := tokenVersionKey("123")
+
+		version, err := cacheTokenVersionScript.Run(ctx, client, []string{key}, 2, time.Hour.Milliseconds()).Int64()
+		asserting.NoError(err)
+		asserting.Equal(int64(2), version)
+		asserting.Equal(time.Hour, server.TTL(key))
+
+		// read before a revocation, written back after it
+		version, err = cacheTokenVersionScript.Run(ctx, client, []string{key}, 1, time.Hour.Milliseconds()).Int64()
+		asserting.NoError(err)
+		asserting.Equal(int64(2), version)
+		cached, _ := server.Get(key)
+		asserting.Equal("2", cached)
+	})
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"golang.org/x/exp/slices"
@@ -37,6 +39,63 @@ func HandleErrorValidator(err error) error {
 	return HandleError(400, errMessage)
 }
 
+// HandleErrorValidatorParams is HandleErrorValidator for the package and
+// promo code inputs, whose limits differ per field: it reports every failed
+// field and reads the limits from the tag.
+func HandleErrorValidatorParams(err error) error {
+	var errMessages []string
+	if castedObject, ok := err.(validator.ValidationErrors); ok {
+		for _, err := range castedObject {
+			errMessages = append(errMessages, validationMessage(err))
+		}
+	}
+
+	return HandleError(400, strings.Join(errMessages, ", "))
+}
+
+func validationMessage(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", err.Field())
+	case "required_if":
+		field, value, _ := strings.Cut(err.Param(), " ")
+		return fmt.Sprintf("%s is required when %s is %s", err.Field(), field, value)
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", err.Field(), validationSize(err))
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", err.Field(), validationSize(err))
+	case "numeric":
+		return fmt.Sprintf("%s must be numeric", err.Field())
+	case "alphanum":
+		return fmt.Sprintf("%s must only contain letters and digits", err.Field())
+	case "iso4217":
+		return fmt.Sprintf("%s is not a valid currency code", err.Field())
+	case "bcp47_language_tag":
+		return fmt.Sprintf("%s is not a valid language tag", err.Field())
+	case "unique":
+		if err.Param() != "" {
+			return fmt.Sprintf("%s must not repeat a %s", err.Field(), err.Param())
+		}
+		return fmt.Sprintf("%s must not contain duplicates", err.Field())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", err.Field(), strings.Join(strings.Fields(err.Param()), ", "))
+	}
+	// email, url and the other format tags
+	return fmt.Sprintf("%s is not valid", err.Field())
+}
+
+// validationSize reads the param of min and max, a length for strings and
+// lists and a value for numbers.
+func validationSize(err validator.FieldError) string {
+	switch err.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("%s characters", err.Param())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("%s items", err.Param())
+	}
+	return err.Param()
+}
+
 // handler error message and status code
 type Error struct {
 	Code    int    `json:"code"`
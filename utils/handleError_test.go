@@ -0,0 +1,97 @@
+package utils_test
+
+import (
+	"roby-backend-golang/utils"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+type validatedInput struct {
+	Code         string            `validate:"required,alphanum,min=3,max=32"`
+	Password     string            `validate:"omitempty,min=8"`
+	Percent      int               `validate:"min=0,max=100"`
+	Type         string            `validate:"omitempty,oneof=percent fixed"`
+	AmountOff    int64             `validate:"required_if=Type fixed"`
+	Currency     string            `validate:"omitempty,iso4217"`
+	IDs          []string          `validate:"omitempty,min=1,unique"`
+	DisplayNames map[string]string `validate:"dive,keys,bcp47_language_tag,endkeys,max=5"`
+}
+
+func validationMessage(input validatedInput) string {
+	err := utils.HandleErrorValidatorParams(validator.New().Struct(&input))
+	return err.Error()
+}
+
+func TestHandleErrorValidatorParams(t *testing.T) {
+	valid := validatedInput{Code: "SAVE20", Percent: 20}
+
+	t.Run("Status Code Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		err := utils.HandleErrorValidatorParams(validator.New().Struct(&validatedInput{}))
+		asserting.Equal(400, utils.GetStatusCode(err))
+	})
+
+	t.Run("Length Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		input := valid
+		input.Code = "AB"
+		asserting.Equal("Code must be at least 3 characters", validationMessage(input))
+
+		input = valid
+		input.Password = "short"
+		asserting.Equal("Password must be at least 8 characters", validationMessage(input))
+	})
+
+	t.Run("Number Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		input := valid
+		input.Percent = 150
+		asserting.Equal("Percent must be at most 100", validationMessage(input))
+	})
+
+	t.Run("Tags Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		input := valid
+		input.Code = "SAVE-20"
+		asserting.Equal("Code must only contain letters and digits", validationMessage(input))
+
+		input = valid
+		input.Type = "free"
+		asserting.Equal("Type must be one of percent, fixed", validationMessage(input))
+
+		input = valid
+		input.Type = "fixed"
+		asserting.Equal("AmountOff is required when Type is fixed", validationMessage(input))
+
+		input = valid
+		input.Currency = "XYZ"
+		asserting.Equal("Currency is not a valid currency code", validationMessage(input))
+
+		input = valid
+		input.IDs = []string{"1", "1"}
+		asserting.Equal("IDs must not contain duplicates", validationMessage(input))
+
+		input = valid
+		input.DisplayNames = map[string]string{"not a tag": "x"}
+		asserting.Equal("DisplayNames[not a tag] is not a valid language tag", validationMessage(input))
+	})
+
+	t.Run("Every Field Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		input := valid
+		input.Code = ""
+		input.Percent = -1
+		asserting.Equal("Code is required, Percent must be at least 0", validationMessage(input))
+	})
+}
+
+func TestHandleErrorValidator(t *testing.T) {
+	t.Run("Message Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		err := utils.HandleErrorValidator(validator.New().Struct(&validatedInput{Code: "SAVE20", Password: "short"}))
+		asserting.Equal(400, utils.GetStatusCode(err))
+		asserting.Equal("Password min 8 character", err.Error())
+	})
+}
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	jose "github.com/dvsekhvalnov/jose2go"
+	"github.com/dvsekhvalnov/jose2go/keys/ecc"
+	Rsa "github.com/dvsekhvalnov/jose2go/keys/rsa"
+)
+
+// KeyFile is the manifest of the asymmetric keys, it is read from the file in
+// JWT_KEYS_FILE. Key paths are relative to the manifest.
+//
+// Rotating a key means adding the next key with not_before in the future, it
+// is published in the jwks right away and signs tokens once not_before passed.
+// The previous key should keep a not_after of at least its last signing time
+// plus the refresh token lifetime so the tokens it signed stay verifiable.
+type KeyFile struct {
+	Keys []KeyFileEntry `json:"keys"`
+}
+
+type KeyFileEntry struct {
+	Kid        string    `json:"kid"`
+	Alg        string    `json:"alg"`
+	PrivateKey string    `json:"private_key"`
+	PublicKey  string    `json:"public_key"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+}
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+type signingKey struct {
+	kid       string
+	alg       string
+	private   interface{}
+	public    interface{}
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+func (k signingKey) retired(at time.Time) bool {
+	return !k.notAfter.IsZero() && !at.Before(k.notAfter)
+}
+
+// KeySet signs and verifies tokens. Tokens are signed with the newest active
+// asymmetric key, the HS256 secret is only used to sign when no asymmetric key
+// is active and is always accepted for tokens signed before the switch.
+type KeySet struct {
+	secret []byte
+	keys   []signingKey
+	now    func() time.Time
+}
+
+func NewKeySet(secret, keysFile string) (*KeySet, error) {
+	keySet := &KeySet{now: time.Now}
+
+	if secret != "" {
+		key, err := Decode(secret)
+		if err != nil {
+			return nil, err
+		}
+		keySet.secret = key
+	}
+
+	if keysFile != "" {
+		err := keySet.load(keysFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if keySet.secret == nil && len(keySet.keys) == 0 {
+		return nil, errors.New("no jwt key configured")
+	}
+
+	return keySet, nil
+}
+
+func (k *KeySet) load(keysFile string) error {
+	raw, err := os.ReadFile(keysFile)
+	if err != nil {
+		return err
+	}
+
+	var manifest KeyFile
+	err = json.Unmarshal(raw, &manifest)
+	if err != nil {
+		return fmt.Errorf("invalid jwt keys file: %w", err)
+	}
+
+	dir := filepath.Dir(keysFile)
+	seen := map[string]bool{}
+	for _, entry := range manifest.Keys {
+		if entry.Kid == "" || seen[entry.Kid] {
+			return fmt.Errorf("jwt key needs a unique kid, got %q", entry.Kid)
+		}
+		seen[entry.Kid] = true
+
+		key, err := loadSigningKey(dir, entry)
+		if err != nil {
+			return fmt.Errorf("jwt key %s: %w", entry.Kid, err)
+		}
+		k.keys = append(k.keys, key)
+	}
+
+	return nil
+}
+
+func loadSigningKey(dir string, entry KeyFileEntry) (signingKey, error) {
+	key := signingKey{
+		kid:       entry.Kid,
+		alg:       entry.Alg,
+		notBefore: entry.NotBefore,
+		notAfter:  entry.NotAfter,
+	}
+
+	path := entry.PrivateKey
+	if path == "" {
+		path = entry.PublicKey
+	}
+	if path == "" {
+		return key, errors.New("private_key or public_key is required")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return key, err
+	}
+
+	switch entry.Alg {
+	case jose.RS256:
+		if entry.PrivateKey != "" {
+			private, err := Rsa.ReadPrivate(raw)
+			if err != nil {
+				return key, err
+			}
+			key.private, key.public = private, &private.PublicKey
+		} else {
+			key.public, err = Rsa.ReadPublic(raw)
+			if err != nil {
+				return key, err
+			}
+		}
+	case jose.ES256:
+		var public *ecdsa.PublicKey
+		if entry.PrivateKey != "" {
+			private, err := ecc.ReadPrivate(raw)
+			if err != nil {
+				return key, err
+			}
+			key.private, public = private, &private.PublicKey
+		} else {
+			public, err = ecc.ReadPublic(raw)
+			if err != nil {
+				return key, err
+			}
+		}
+		if public.Curve.Params().Name != elliptic.P256().Params().Name {
+			return key, errors.New("ES256 needs a P-256 key")
+		}
+		key.public = public
+	default:
+		return key, fmt.Errorf("unsupported alg %q", entry.Alg)
+	}
+
+	return key, nil
+}
+
+// signer is the newest key with a private part whose not_before passed.
+func (k *KeySet) signer(at time.Time) (signingKey, bool) {
+	var current signingKey
+	found := false
+	for _, key := range k.keys {
+		if key.private == nil || at.Before(key.notBefore) || key.retired(at) {
+			continue
+		}
+		if !found || key.notBefore.After(current.notBefore) {
+			current, found = key, true
+		}
+	}
+	return current, found
+}
+
+func (k *KeySet) Sign(payload []byte) (string, error) {
+	key, ok := k.signer(k.now())
+	if ok {
+		return jose.SignBytes(payload, key.alg, key.private, jose.Header("typ", "JWT"), jose.Header("kid", key.kid))
+	}
+	if k.secret == nil {
+		return "", errors.New("no active jwt signing key")
+	}
+	return jose.SignBytes(payload, jose.HS256, k.secret, jose.Header("typ", "JWT"))
+}
+
+// Verify checks the signature of the token and returns its payload. The key
+// is picked from the kid header and must match the alg header so a token can
+// not choose how it is verified.
+func (k *KeySet) Verify(token string) (string, error) {
+	payload, _, err := jose.Decode(token, func(headers map[string]interface{}, payload string) interface{} {
+		alg, _ := headers["alg"].(string)
+		if alg == jose.HS256 {
+			if k.secret == nil {
+				return errors.New("HS256 tokens are not accepted")
+			}
+			return k.secret
+		}
+
+		kid, _ := headers["kid"].(string)
+		at := k.now()
+		for _, key := range k.keys {
+			if key.kid == kid && key.alg == alg && !key.retired(at) {
+				return key.public
+			}
+		}
+		return errors.New("unknown signing key")
+	})
+	if err != nil {
+		return "", err
+	}
+	return payload, nil
+}
+
+// JWKS lists the public keys that verify tokens right now, including keys
+// scheduled to sign later so verifiers can cache them before the rotation.
+func (k *KeySet) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	at := k.now()
+	for _, key := range k.keys {
+		if key.retired(at) {
+			continue
+		}
+		jwk := JSONWebKey{Kid: key.kid, Use: "sig", Alg: key.alg}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = public.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, size)))
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,189 @@
+package utils_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"roby-backend-golang/utils"
+	"strings"
+	"testing"
+	"time"
+
+	jose "github.com/dvsekhvalnov/jose2go"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "secret-token-for-test"
+
+func writeKeys(t *testing.T, entries []utils.KeyFileEntry) string {
+	dir := t.TempDir()
+	for i, entry := range entries {
+		var block *pem.Block
+		switch entry.Alg {
+		case jose.RS256:
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+		case jose.ES256:
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := x509.MarshalECPrivateKey(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: raw}
+		}
+		entries[i].PrivateKey = entry.Kid + ".pem"
+		err := os.WriteFile(filepath.Join(dir, entries[i].PrivateKey), pem.EncodeToMemory(block), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raw, err := json.Marshal(utils.KeyFile{Keys: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "keys.json")
+	err = os.WriteFile(path, raw, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func tokenHeader(t *testing.T, token string) map[string]interface{} {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := map[string]interface{}{}
+	err = json.Unmarshal(raw, &headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return headers
+}
+
+func TestKeySet(t *testing.T) {
+	now := time.Now()
+
+	t.Run("Sign With Newest Active Key Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		path := writeKeys(t, []utils.KeyFileEntry{
+			{Kid: "old", Alg: jose.RS256, NotBefore: now.Add(-48 * time.Hour)},
+			{Kid: "current", Alg: jose.ES256, NotBefore: now.Add(-time.Hour)},
+			{Kid: "next", Alg: jose.RS256, NotBefore: now.Add(24 * time.Hour)},
+		})
+		keys, err := utils.NewKeySet("", path)
+		asserting.NoError(err)
+
+		token, err := keys.Sign([]byte(`{"sub":"123"}`))
+		asserting.NoError(err)
+		headers := tokenHeader(t, token)
+		asserting.Equal("current", headers["kid"])
+		asserting.Equal(jose.ES256, headers["alg"])
+
+		payload, err := keys.Verify(token)
+		asserting.NoError(err)
+		asserting.Equal(`{"sub":"123"}`, payload)
+
+		jwks := keys.JWKS()
+		asserting.Len(jwks.Keys, 3)
+		for _, jwk := range jwks.Keys {
+			if jwk.Kid == "current" {
+				asserting.Equal("EC", jwk.Kty)
+				asserting.Equal("P-256", jwk.Crv)
+				asserting.NotEmpty(jwk.X)
+			} else {
+				asserting.Equal("RSA", jwk.Kty)
+				asserting.Equal("AQAB", jwk.E)
+			}
+		}
+	})
+
+	t.Run("Retired Key Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		path := writeKeys(t, []utils.KeyFileEntry{
+			{Kid: "retired", Alg: jose.RS256, NotBefore: now.Add(-48 * time.Hour), NotAfter: now.Add(-time.Hour)},
+		})
+		keys, err := utils.NewKeySet(testSecret, path)
+		asserting.NoError(err)
+
+		// without an active asymmetric key the secret signs again
+		token, err := keys.Sign([]byte(`{"sub":"123"}`))
+		asserting.NoError(err)
+		asserting.Equal(jose.HS256, tokenHeader(t, token)["alg"])
+		asserting.Empty(keys.JWKS().Keys)
+	})
+
+	t.Run("Legacy HS256 Token Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		legacy, err := utils.NewKeySet(testSecret, "")
+		asserting.NoError(err)
+		token, err := legacy.Sign([]byte(`{"sub":"123"}`))
+		asserting.NoError(err)
+
+		path := writeKeys(t, []utils.KeyFileEntry{
+			{Kid: "current", Alg: jose.RS256, NotBefore: now.Add(-time.Hour)},
+		})
+		keys, err := utils.NewKeySet(testSecret, path)
+		asserting.NoError(err)
+		_, err = keys.Verify(token)
+		asserting.NoError(err)
+
+		withoutSecret, err := utils.NewKeySet("", path)
+		asserting.NoError(err)
+		_, err = withoutSecret.Verify(token)
+		asserting.Error(err)
+	})
+
+	t.Run("Unknown Kid Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		signer, err := utils.NewKeySet("", writeKeys(t, []utils.KeyFileEntry{
+			{Kid: "current", Alg: jose.RS256, NotBefore: now.Add(-time.Hour)},
+		}))
+		asserting.NoError(err)
+		token, err := signer.Sign([]byte(`{"sub":"123"}`))
+		asserting.NoError(err)
+
+		other, err := utils.NewKeySet("", writeKeys(t, []utils.KeyFileEntry{
+			{Kid: "other", Alg: jose.RS256, NotBefore: now.Add(-time.Hour)},
+		}))
+		asserting.NoError(err)
+		_, err = other.Verify(token)
+		asserting.Error(err)
+	})
+
+	t.Run("Tampered Token Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		keys, err := utils.NewKeySet("", writeKeys(t, []utils.KeyFileEntry{
+			{Kid: "current", Alg: jose.ES256, NotBefore: now.Add(-time.Hour)},
+		}))
+		asserting.NoError(err)
+		token, err := keys.Sign([]byte(`{"sub":"123"}`))
+		asserting.NoError(err)
+		parts := strings.Split(token, ".")
+		parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"456"}`))
+
+		_, err = keys.Verify(strings.Join(parts, "."))
+		asserting.Error(err)
+	})
+
+	t.Run("No Key Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		_, err := utils.NewKeySet("", "")
+		asserting.Error(err)
+	})
+}
@@ -0,0 +1,17 @@
+package utils_test
+
+import (
+	"roby-backend-golang/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKCEChallenge(t *testing.T) {
+	t.Run("S256 Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		// base64url of the sha256 without padding
+		challenge := utils.PKCEChallenge("dBjftJeZ4CVP-mJ92IZD1cB1oZx5Ly-KyTP4D0aL6BQ")
+		asserting.Equal("xa3bN5T-97xwoy9UWUYjXPBXKhYPEWyDOijjh65RmaE", challenge)
+	})
+}
@@ -0,0 +1,58 @@
+package utils
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+
+	PermissionManageUsers    = "users:manage"
+	PermissionManagePackages = "packages:manage"
+	PermissionManagePayments = "payments:manage"
+	PermissionManagePromos   = "promos:manage"
+)
+
+// rolePermissions grants the permissions of every role, routes check
+// permissions so a role can be split later without touching them.
+var rolePermissions = map[string][]string{
+	RoleUser: {},
+	RoleAdmin: {
+		PermissionManageUsers,
+		PermissionManagePackages,
+		PermissionManagePayments,
+		PermissionManagePromos,
+	},
+}
+
+// IsRole reports whether the role exists.
+func IsRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// NormalizeRoles is the roles of a user, users stored before roles existed and
+// tokens minted before them have none and are plain users.
+func NormalizeRoles(roles []string) []string {
+	if len(roles) == 0 {
+		return []string{RoleUser}
+	}
+	return roles
+}
+
+func HasRole(roles []string, role string) bool {
+	for _, r := range NormalizeRoles(roles) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func HasPermission(roles []string, permission string) bool {
+	for _, role := range NormalizeRoles(roles) {
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
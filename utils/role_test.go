@@ -0,0 +1,31 @@
+package utils_test
+
+import (
+	"roby-backend-golang/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoles(t *testing.T) {
+	t.Run("Default Role Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		asserting.True(utils.HasRole(nil, utils.RoleUser))
+		asserting.False(utils.HasRole(nil, utils.RoleAdmin))
+		asserting.False(utils.HasPermission(nil, utils.PermissionManagePackages))
+	})
+
+	t.Run("Admin Permission Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		roles := []string{utils.RoleUser, utils.RoleAdmin}
+		asserting.True(utils.HasRole(roles, utils.RoleAdmin))
+		asserting.True(utils.HasPermission(roles, utils.PermissionManageUsers))
+		asserting.False(utils.HasPermission(roles, "unknown:permission"))
+	})
+
+	t.Run("Unknown Role Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		asserting.False(utils.IsRole("superuser"))
+		asserting.False(utils.HasPermission([]string{"superuser"}, utils.PermissionManageUsers))
+	})
+}
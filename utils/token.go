@@ -5,11 +5,8 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 	"time"
-
-	jose "github.com/dvsekhvalnov/jose2go"
 )
 
 type JwtTokenClaims struct {
@@ -26,64 +23,93 @@ type Token struct {
 	RefreshTokenExpired int    `json:"refresh_token_expired"`
 }
 type JwtTokenClaimsUser struct {
-	Sub           string `json:"sub"`
-	Email         string `json:"email"`
-	Exp           int64  `json:"exp"`
-	Role          string `json:"role"`
-	Authorization bool   `json:"authorization"`
+	Sub                  string   `json:"sub"`
+	Email                string   `json:"email"`
+	Exp                  int64    `json:"exp"`
+	Roles                []string `json:"roles,omitempty"`
+	Authorization        bool     `json:"authorization"`
+	AuthorizationRefresh bool     `json:"authorization_refresh,omitempty"`
+	Jti                  string   `json:"jti"`
+	Family               string   `json:"family"`
+	Ver                  int64    `json:"ver"`
 }
 
 type RefreshJwtTokenClaimsUser struct {
-	Sub                  string `json:"sub"`
-	Email                string `json:"email"`
-	Exp                  int64  `json:"exp"`
-	Role                 string `json:"role"`
-	AuthorizationRefresh bool   `json:"authorization_refresh"`
+	Sub                  string   `json:"sub"`
+	Email                string   `json:"email"`
+	Exp                  int64    `json:"exp"`
+	Roles                []string `json:"roles,omitempty"`
+	AuthorizationRefresh bool     `json:"authorization_refresh"`
+	Jti                  string   `json:"jti"`
+	Family               string   `json:"family"`
+	Ver                  int64    `json:"ver"`
+}
+
+// PurposeClaims are the claims of single use tokens such as the verify email
+// token, the purpose keeps a token from being accepted by another endpoint.
+type PurposeClaims struct {
+	Sub     string `json:"sub"`
+	Email   string `json:"email"`
+	Exp     int64  `json:"exp"`
+	Jti     string `json:"jti"`
+	Purpose string `json:"purpose"`
 }
 
-func GenerateAccessTokenUser(id, email string, token string) (int, string, error) {
-	secret1 := token
-	expired := 7200
+const (
+	AccessTokenExpired  = 7200
+	RefreshTokenExpired = 14400
+	VerifyEmailExpired  = 86400
+	MFAChallengeExpired = 300
+
+	purposeVerifyEmail  = "verify_email"
+	purposeMFAChallenge = "mfa_challenge"
+)
+
+// GenerateAccessTokenUser mints an access token identified by jti so it can be
+// revoked alone, version is the token version of the user at login. Changing
+// the roles of a user bumps the version so roles in a token are never stale.
+func GenerateAccessTokenUser(id, email, family, jti string, version int64, roles []string, keys *KeySet) (int, string, error) {
+	expired := AccessTokenExpired
 	claims := &JwtTokenClaimsUser{
-		Sub:   id,
-		Email: email,
-		Exp:   time.Now().Add(time.Duration(expired) * time.Second).Unix(),
-		Role:  "User",
-	}
-	key, err := Decode(secret1)
-	if err != nil {
-		return 0, "", err
+		Sub:           id,
+		Email:         email,
+		Exp:           time.Now().Add(time.Duration(expired) * time.Second).Unix(),
+		Roles:         NormalizeRoles(roles),
+		Authorization: true,
+		Jti:           jti,
+		Family:        family,
+		Ver:           version,
 	}
 	e, err := json.Marshal(claims)
 	if err != nil {
 		return 0, "", err
 	}
-	str, err := jose.Sign(string(e), jose.HS256, key, jose.Header("typ", "JWT"))
+	str, err := keys.Sign(e)
 	if err != nil {
 		return 0, "", err
 	}
 	return expired, str, err
 }
 
-func GenerateRefreshTokenUser(id, email string, token string) (int, string, error) {
-	secret1 := token
-	expired := 14400
+// GenerateRefreshTokenUser mints a refresh token identified by jti, every
+// token rotated from the same login shares the family.
+func GenerateRefreshTokenUser(id, email, family, jti string, version int64, roles []string, keys *KeySet) (int, string, error) {
+	expired := RefreshTokenExpired
 	claims := &RefreshJwtTokenClaimsUser{
 		Sub:                  id,
 		Email:                email,
 		Exp:                  time.Now().Add(time.Duration(expired) * time.Second).Unix(),
-		Role:                 "User",
+		Roles:                NormalizeRoles(roles),
 		AuthorizationRefresh: true,
-	}
-	key, err := Decode(secret1)
-	if err != nil {
-		return 0, "", err
+		Jti:                  jti,
+		Family:               family,
+		Ver:                  version,
 	}
 	e, err := json.Marshal(claims)
 	if err != nil {
 		return 0, "", err
 	}
-	str, err := jose.Sign(string(e), jose.HS256, key, jose.Header("typ", "JWT"))
+	str, err := keys.Sign(e)
 	if err != nil {
 		return 0, "", err
 	}
@@ -117,14 +143,8 @@ func Encode(data []byte) string {
 	return result
 }
 
-func DecodeToken(token string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	key, err := Decode(secret)
-	if err != nil {
-		return "", err
-	}
-
-	payload, _, err := jose.Decode(token, key)
+func DecodeToken(token string, keys *KeySet) (string, error) {
+	payload, err := keys.Verify(token)
 	if err != nil {
 		return "", err
 	}
@@ -138,3 +158,80 @@ func DecodeToken(token string) (string, error) {
 	}
 	return claims.Sub, nil
 }
+
+func ParseRefreshToken(tokenString string, keys *KeySet) (*RefreshJwtTokenClaimsUser, error) {
+	payload, err := keys.Verify(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	claims := &RefreshJwtTokenClaimsUser{}
+	err = json.Unmarshal([]byte(payload), claims)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.AuthorizationRefresh || claims.Jti == "" {
+		return nil, fmt.Errorf("Not a refresh token")
+	}
+	if claims.Exp < time.Now().Unix() {
+		return nil, fmt.Errorf("Token expired")
+	}
+	return claims, nil
+}
+
+// GenerateVerifyEmailToken mints the token sent in the verification email, the
+// purpose claim keeps it from being accepted anywhere else.
+func GenerateVerifyEmailToken(id, email, jti string, keys *KeySet) (int, string, error) {
+	return generatePurposeToken(id, email, jti, purposeVerifyEmail, VerifyEmailExpired, keys)
+}
+
+func ParseVerifyEmailToken(tokenString string, keys *KeySet) (*PurposeClaims, error) {
+	return parsePurposeToken(tokenString, purposeVerifyEmail, keys)
+}
+
+// GenerateMFAChallengeToken mints the token a login with two-factor enabled
+// gets instead of a token pair, it is only traded for tokens with a code.
+func GenerateMFAChallengeToken(id, email, jti string, keys *KeySet) (int, string, error) {
+	return generatePurposeToken(id, email, jti, purposeMFAChallenge, MFAChallengeExpired, keys)
+}
+
+func ParseMFAChallengeToken(tokenString string, keys *KeySet) (*PurposeClaims, error) {
+	return parsePurposeToken(tokenString, purposeMFAChallenge, keys)
+}
+
+func generatePurposeToken(id, email, jti, purpose string, expired int, keys *KeySet) (int, string, error) {
+	claims := &PurposeClaims{
+		Sub:     id,
+		Email:   email,
+		Exp:     time.Now().Add(time.Duration(expired) * time.Second).Unix(),
+		Jti:     jti,
+		Purpose: purpose,
+	}
+	e, err := json.Marshal(claims)
+	if err != nil {
+		return 0, "", err
+	}
+	str, err := keys.Sign(e)
+	if err != nil {
+		return 0, "", err
+	}
+	return expired, str, nil
+}
+
+func parsePurposeToken(tokenString, purpose string, keys *KeySet) (*PurposeClaims, error) {
+	payload, err := keys.Verify(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	claims := &PurposeClaims{}
+	err = json.Unmarshal([]byte(payload), claims)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose || claims.Jti == "" {
+		return nil, fmt.Errorf("Not a %s token", strings.Replace(purpose, "_", " ", -1))
+	}
+	if claims.Exp < time.Now().Unix() {
+		return nil, fmt.Errorf("Token expired")
+	}
+	return claims, nil
+}
@@ -0,0 +1,52 @@
+package utils_test
+
+import (
+	"roby-backend-golang/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 test secret "12345678901234567890" in base32
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	t.Run("RFC Vector Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		code, err := utils.TOTPCode(secret, time.Unix(59, 0))
+		asserting.NoError(err)
+		asserting.Equal("287082", code)
+
+		code, err = utils.TOTPCode(secret, time.Unix(1111111109, 0))
+		asserting.NoError(err)
+		asserting.Equal("081804", code)
+	})
+
+	t.Run("Skew Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		at := time.Unix(1111111109, 0)
+		step, ok := utils.VerifyTOTP(secret, "081804", at.Add(30*time.Second))
+		asserting.True(ok)
+		asserting.Equal(at.Unix()/30, step)
+
+		_, ok = utils.VerifyTOTP(secret, "081804", at.Add(90*time.Second))
+		asserting.False(ok)
+	})
+
+	t.Run("Generated Secret Test", func(t *testing.T) {
+		asserting := assert.New(t)
+		secret, err := utils.GenerateTOTPSecret()
+		asserting.NoError(err)
+		now := time.Now()
+		code, err := utils.TOTPCode(secret, now)
+		asserting.NoError(err)
+		_, ok := utils.VerifyTOTP(secret, code, now)
+		asserting.True(ok)
+
+		uri := utils.TOTPURI("AppTinder", "test@mail.com", secret)
+		asserting.True(strings.HasPrefix(uri, "otpauth://totp/AppTinder:test@mail.com?"))
+		asserting.Contains(uri, "secret="+secret)
+	})
+}